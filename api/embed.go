@@ -0,0 +1,14 @@
+// Package api holds the OpenAPI specification of the service and its docs page
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI specification of the meals API
+//
+//go:embed meals_api.yaml
+var Spec []byte
+
+// Docs is a self-contained page that renders the spec served at /openapi.json
+//
+//go:embed docs.html
+var Docs []byte
@@ -0,0 +1,712 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"meals/api"
+	"meals/internal"
+	"meals/internal/config"
+	"meals/internal/managers"
+	"meals/internal/repositories"
+	"meals/internal/utils"
+	"meals/pkg/blob"
+	"meals/pkg/database"
+	"meals/pkg/logging"
+	"meals/pkg/openapi"
+	"meals/pkg/tracing"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var databaseTest = "/amc_contract_test.db"
+
+var pathParamRegexp = regexp.MustCompile(`:([a-z_]+)`)
+
+// ContractTestSuite runs the service with the OpenAPI validation enforced, so every request or
+// response of a handler that does not match api/meals_api.yaml makes the tests fail
+type ContractTestSuite struct {
+	suite.Suite
+	db  *database.Database
+	e   *echo.Echo
+	doc *openapi3.T
+}
+
+func TestContractTestSuite(t *testing.T) {
+	suite.Run(t, new(ContractTestSuite))
+}
+
+func (s *ContractTestSuite) SetupTest() {
+	httpMock := &internal.EndpointsMock{}
+	httpMock.On("GetCalendar", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	managers.Microservices = httpMock
+	managers.Images = blob.NewLocal(s.T().TempDir())
+
+	_ = database.RemoveDB(databaseTest)
+	db, err := database.InitDB(databaseTest)
+	s.Require().NoError(err)
+	s.db = db
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000001", "01FN3EEB2NVFJAHAPU00000001", "pizza", "", "", "ocasional", "Tomate|Queso|Pollo", 130, "invierno,verano")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000002", "01FN3EEB2NVFJAHAPU00000001", "ensalada", "", "", "semanal", "Tomate|Lechuga|Cebolla|Aguacate", 100, "general")
+
+	config.Config.Host, config.Config.Port = "0.0.0.0", "3200"
+	config.Config.OpenAPIValidation = openapi.ModeEnforce
+	s.e = setUpServer(s.db)
+
+	doc, err := openapi.Load(api.Spec)
+	s.Require().NoError(err)
+	s.doc = doc
+}
+
+func (s *ContractTestSuite) TearDownTest() {
+	s.db = nil
+	_ = database.RemoveDB(databaseTest)
+}
+
+func (s *ContractTestSuite) TestRoutesMatchSpec() {
+	routes := map[string]bool{}
+	for _, route := range s.e.Routes() {
+		path := pathParamRegexp.ReplaceAllString(route.Path, "{$1}")
+		routes[route.Method+" "+path] = true
+
+		pathItem := s.doc.Paths.Value(path)
+		s.NotNil(pathItem, "route %s %s is not documented", route.Method, route.Path)
+		if pathItem != nil {
+			s.NotNil(pathItem.GetOperation(route.Method), "route %s %s is not documented", route.Method, route.Path)
+		}
+	}
+	for path, pathItem := range s.doc.Paths.Map() {
+		for method := range pathItem.Operations() {
+			s.True(routes[method+" "+path], "operation %s %s of the spec is not served", method, path)
+		}
+	}
+}
+
+func (s *ContractTestSuite) TestHandlersMatchSpec() {
+	const (
+		user = "/user/01FN3EEB2NVFJAHAPU00000001"
+		meal = user + "/meal/01FN3EEB2NVFJAHAPM00000001"
+	)
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		headers            map[string]string
+		body               string
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name:               "[001] List meals",
+			method:             http.MethodGet,
+			target:             user + "/meal?healthy=true",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[002] List meals filtered by season[]",
+			method:             http.MethodGet,
+			target:             user + "/meal?season[]=invierno",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[003] List meals filtered by the legacy []season",
+			method:             http.MethodGet,
+			target:             user + "/meal?[]season=invierno",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[004] Query parameter of the wrong type is rejected",
+			method:             http.MethodGet,
+			target:             user + "/meal?healthy=mucho",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    internal.ErrRequestNotValid.Error(),
+		},
+		{
+			name:               "[005] Get meal",
+			method:             http.MethodGet,
+			target:             meal,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[006] Get meal as a schema.org Recipe",
+			method:             http.MethodGet,
+			target:             meal,
+			headers:            map[string]string{echo.HeaderAccept: internal.MIMEApplicationLDJSON},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[007] Get meal not modified",
+			method:             http.MethodGet,
+			target:             meal,
+			headers:            map[string]string{internal.HeaderIfNoneMatch: `"1"`},
+			expectedStatusCode: http.StatusNotModified,
+		},
+		{
+			name:               "[008] Get meal that does not exist",
+			method:             http.MethodGet,
+			target:             user + "/meal/01FN3EEB2NVFJAHAPM00000099",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "[009] Create meal",
+			method:             http.MethodPost,
+			target:             user + "/meal",
+			body:               `{"name":"gazpacho","type":"normal","ingredients":["Tomates","Pepino"],"seasons":["verano"]}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[010] Create meal that already exists",
+			method:             http.MethodPost,
+			target:             user + "/meal",
+			body:               `{"name":"pizza","type":"normal","ingredients":["Tomates"],"seasons":["general"]}`,
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "[011] Create meal with a body that does not match the spec is rejected",
+			method:             http.MethodPost,
+			target:             user + "/meal",
+			body:               `{"name":"gazpacho","type":"diario","seasons":["verano"]}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    internal.ErrRequestNotValid.Error(),
+		},
+		{
+			name:               "[012] Replace meal without If-Match",
+			method:             http.MethodPut,
+			target:             meal,
+			body:               `{"name":"pizza","type":"ocasional","ingredients":["Tomates"],"seasons":["general"]}`,
+			expectedStatusCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:               "[013] Replace meal with an outdated version",
+			method:             http.MethodPut,
+			target:             meal,
+			headers:            map[string]string{internal.HeaderIfMatch: `"9"`},
+			body:               `{"name":"pizza","type":"ocasional","ingredients":["Tomates"],"seasons":["general"]}`,
+			expectedStatusCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:               "[014] Replace meal",
+			method:             http.MethodPut,
+			target:             meal,
+			headers:            map[string]string{internal.HeaderIfMatch: `"1"`},
+			body:               `{"name":"pizza margarita","type":"ocasional","ingredients":["Tomates"],"seasons":["general"]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[015] Merge patch of a meal",
+			method:             http.MethodPatch,
+			target:             meal,
+			headers:            map[string]string{echo.HeaderContentType: "application/merge-patch+json", internal.HeaderIfMatch: `"2"`},
+			body:               `{"description":"Con mucho queso"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[016] JSON patch of a meal",
+			method:             http.MethodPatch,
+			target:             meal,
+			headers:            map[string]string{echo.HeaderContentType: "application/json-patch+json", internal.HeaderIfMatch: `"3"`},
+			body:               `[{"op":"replace","path":"/image","value":"pizza.jpg"}]`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[017] Batch of operations",
+			method:             http.MethodPost,
+			target:             user + "/meal/batch",
+			body:               `{"mode":"best_effort","operations":[{"op":"create","meal":{"name":"salmorejo","type":"normal","ingredients":["Tomates"],"seasons":["verano"]}},{"op":"delete","id":"01FN3EEB2NVFJAHAPM00000002"}]}`,
+			expectedStatusCode: http.StatusMultiStatus,
+		},
+		{
+			name:               "[018] Export meals as JSON",
+			method:             http.MethodGet,
+			target:             user + "/meal/export",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[019] Export meals as CSV",
+			method:             http.MethodGet,
+			target:             user + "/meal/export?format=csv",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[020] Import meals from CSV",
+			method:             http.MethodPost,
+			target:             user + "/meal/import?on_conflict=rename",
+			headers:            map[string]string{echo.HeaderContentType: "text/csv"},
+			body:               "name,type,ingredients,seasons\npizza,ocasional,Tomates|Queso,general\n",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[021] Delete meal without If-Match",
+			method:             http.MethodDelete,
+			target:             user + "/meal/01FN3EEB2NVFJAHAPM00000002",
+			expectedStatusCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:               "[022] Delete meal",
+			method:             http.MethodDelete,
+			target:             user + "/meal/01FN3EEB2NVFJAHAPM00000002",
+			headers:            map[string]string{internal.HeaderIfMatch: `"1"`},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "[023] Catalog of ingredients",
+			method:             http.MethodGet,
+			target:             "/ingredients",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[024] Generate a meal plan",
+			method:             http.MethodPost,
+			target:             user + "/plan/generate",
+			body:               `{"from":"2024-01-08","to":"2024-01-14","daily_kcal":2000,"seed":42}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[025] Shopping list",
+			method:             http.MethodPost,
+			target:             user + "/shopping-list",
+			body:               `{"meal_ids":["01FN3EEB2NVFJAHAPM00000001","01FN3EEB2NVFJAHAPM00000001"]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[026] Shopping list in Markdown",
+			method:             http.MethodPost,
+			target:             user + "/shopping-list?format=markdown",
+			body:               `{"meal_ids":["01FN3EEB2NVFJAHAPM00000001"]}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[027] Add an ingredient to the pantry",
+			method:             http.MethodPost,
+			target:             user + "/pantry",
+			body:               `{"ingredient":"tomate","quantity":"1 kg","expires_on":"2099-01-01"}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[028] Pantry item not found",
+			method:             http.MethodGet,
+			target:             user + "/pantry/01FN3EEB2NVFJAHAPI00000099",
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessage:    internal.ErrPantryItemNotFound.Error(),
+		},
+		{
+			name:               "[029] Meals cookable with the pantry",
+			method:             http.MethodGet,
+			target:             user + "/meal/cookable?expiring_within=3",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[030] List meals by ingredients and kcal",
+			method:             http.MethodGet,
+			target:             user + "/meal?ingredients_any=Tomates&ingredients_any=Pollo&ingredients_none=Queso&kcal_max=500",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[031] Search meals",
+			method:             http.MethodGet,
+			target:             user + "/meal?q=tomates+pizza",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[032] List meals by allergens and diet",
+			method:             http.MethodGet,
+			target:             user + "/meal?allergen_free[]=gluten&allergen_free[]=lacteos&diet=vegana",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[033] Diet profile not found",
+			method:             http.MethodGet,
+			target:             user + "/profile/diet",
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessage:    internal.ErrDietProfileNotFound.Error(),
+		},
+		{
+			name:               "[034] Save the diet profile",
+			method:             http.MethodPut,
+			target:             user + "/profile/diet",
+			body:               `{"excluded_allergens":["gluten"],"diets":["vegetariana"],"disliked_ingredients":["Cebolla"],"daily_kcal":2000}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[035] List meals ignoring the diet profile",
+			method:             http.MethodGet,
+			target:             user + "/meal?ignore_profile=true",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[036] Delete the diet profile",
+			method:             http.MethodDelete,
+			target:             user + "/profile/diet",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "[037] Get meal scaled to other servings",
+			method:             http.MethodGet,
+			target:             meal + "?servings=4",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[038] Create meal with cooking steps",
+			method:             http.MethodPost,
+			target:             user + "/meal",
+			body:               `{"name":"lasaña","type":"normal","ingredients":["Pasta de sémola"],"seasons":["general"],"steps":[{"text":"Cocer la pasta","timer_minutes":10},{"text":"Gratinar"}],"prep_time":20,"cook_time":40,"difficulty":"media","equipment":["Horno"]}`,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[039] List meals by max total time",
+			method:             http.MethodGet,
+			target:             user + "/meal?max_total_time=60",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[040] Get the image of a meal without it",
+			method:             http.MethodGet,
+			target:             meal + "/image",
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessage:    internal.ErrImageNotFound.Error(),
+		},
+		{
+			name:               "[041] Get a thumbnail of a size that does not exist",
+			method:             http.MethodGet,
+			target:             meal + "/image/huge",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    internal.ErrRequestNotValid.Error(),
+		},
+		{
+			name:               "[042] Route that does not exist",
+			method:             http.MethodGet,
+			target:             user + "/recipes",
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessage:    internal.ErrRouteNotFound.Error(),
+		},
+		{
+			name:               "[043] Method not allowed on the route",
+			method:             http.MethodDelete,
+			target:             "/ingredients",
+			expectedStatusCode: http.StatusMethodNotAllowed,
+			expectedMessage:    internal.ErrMethodNotAllowed.Error(),
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			req := httptest.NewRequest(t.method, t.target, strings.NewReader(t.body))
+			if t.body != "" {
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			for key, value := range t.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+
+			s.e.ServeHTTP(rec, req)
+
+			s.Equal(t.expectedStatusCode, rec.Code, rec.Body.String())
+			if t.expectedMessage != "" {
+				problem := new(internal.ErrorResponse)
+				s.NoError(jsoniter.Unmarshal(rec.Body.Bytes(), problem))
+				s.Contains(rec.Header().Get(echo.HeaderContentType), internal.MIMEApplicationProblemJSON)
+				s.Equal(t.expectedMessage, problem.Title)
+				s.NotEmpty(problem.Detail)
+			}
+		})
+	}
+}
+
+func (s *ContractTestSuite) TestMealImage() {
+	meal := "/user/01FN3EEB2NVFJAHAPU00000001/meal/01FN3EEB2NVFJAHAPM00000001"
+	img := image.NewNRGBA(image.Rect(0, 0, 500, 250))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 128})
+	content := new(bytes.Buffer)
+	s.Require().NoError(png.Encode(content, img))
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("image", "meal.png")
+	s.Require().NoError(err)
+	_, _ = part.Write(content.Bytes())
+	s.Require().NoError(form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, meal+"/image", body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	s.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, meal+"/image/small", nil))
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal("image/png", rec.Header().Get(echo.HeaderContentType))
+
+	req = httptest.NewRequest(http.MethodGet, meal+"/image/small", nil)
+	req.Header.Set(internal.HeaderIfNoneMatch, rec.Header().Get(internal.HeaderETag))
+	rec = httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	s.Equal(http.StatusNotModified, rec.Code)
+}
+
+func (s *ContractTestSuite) TestPanicRecovered() {
+	s.e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	s.Equal(http.StatusInternalServerError, rec.Code)
+	s.Equal(internal.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+	problem := new(internal.ErrorResponse)
+	s.NoError(jsoniter.Unmarshal(rec.Body.Bytes(), problem))
+	s.Equal("INTERNAL_ERROR", problem.Code)
+	s.NotContains(rec.Body.String(), "boom")
+}
+
+func (s *ContractTestSuite) TestServedSpec() {
+	tests := []struct {
+		name                string
+		target              string
+		expectedContentType string
+	}{
+		{
+			name:                "[001] Spec in YAML",
+			target:              internal.RouteOpenAPIYAML,
+			expectedContentType: openapi.MIMEApplicationYAML,
+		},
+		{
+			name:                "[002] Spec in JSON",
+			target:              internal.RouteOpenAPIJSON,
+			expectedContentType: echo.MIMEApplicationJSON,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, t.target, nil))
+
+			s.Equal(http.StatusOK, rec.Code)
+			s.Contains(rec.Header().Get(echo.HeaderContentType), t.expectedContentType)
+			served, err := openapi.Load(rec.Body.Bytes())
+			s.Require().NoError(err)
+			s.Require().Len(served.Servers, 1)
+			s.Equal("http://localhost:3200", served.Servers[0].URL)
+			s.Equal(s.doc.Paths.Len(), served.Paths.Len())
+		})
+	}
+
+	s.Run("[003] Docs page", func() {
+		rec := httptest.NewRecorder()
+		s.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, internal.RouteDocs, nil))
+
+		s.Equal(http.StatusOK, rec.Code)
+		s.Contains(rec.Header().Get(echo.HeaderContentType), echo.MIMETextHTML)
+		s.Contains(rec.Body.String(), internal.RouteOpenAPIJSON)
+		s.NotContains(rec.Body.String(), "<script src=")
+	})
+}
+
+func (s *ContractTestSuite) TestRequestID() {
+	var calendarRequestIDs []string
+	calendars := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calendarRequestIDs = append(calendarRequestIDs, r.Header.Get(echo.HeaderXRequestID))
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer calendars.Close()
+
+	defaultLogger, calendarsURL := slog.Default(), config.Config.CalendarsURL
+	defer func() {
+		slog.SetDefault(defaultLogger)
+		config.Config.CalendarsURL = calendarsURL
+	}()
+	var logs bytes.Buffer
+	slog.SetDefault(logging.New(&logs, "info"))
+	config.Config.CalendarsURL = calendars.URL + "/"
+	managers.Microservices = &utils.Endpoints{}
+	e := setUpServer(s.db)
+
+	s.Run("[001] Id sent by the client is propagated to the logs and the calendars", func() {
+		req := httptest.NewRequest(http.MethodDelete, "/user/01FN3EEB2NVFJAHAPU00000001/meal/01FN3EEB2NVFJAHAPM00000002", nil)
+		req.Header.Set(internal.HeaderIfMatch, `"1"`)
+		req.Header.Set(echo.HeaderXRequestID, "test-request-1")
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		s.Equal(http.StatusNoContent, rec.Code)
+		s.Equal("test-request-1", rec.Header().Get(echo.HeaderXRequestID))
+		s.Equal([]string{"test-request-1"}, calendarRequestIDs)
+
+		var access map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			entry := map[string]interface{}{}
+			s.Require().NoError(json.Unmarshal([]byte(line), &entry), line)
+			if entry["msg"] == "request" && entry["request_id"] == "test-request-1" {
+				access = entry
+			}
+		}
+		s.Require().NotNil(access, logs.String())
+		s.Equal(internal.RouteMealID, access["route"])
+		s.Equal("01FN3EEB2NVFJAHAPU00000001", access["user_id"])
+		s.Equal(float64(http.StatusNoContent), access["status"])
+		s.Contains(access, "latency_ms")
+	})
+
+	s.Run("[002] Id generated when the client does not send a valid one", func() {
+		req := httptest.NewRequest(http.MethodGet, "/user/01FN3EEB2NVFJAHAPU00000001/meal/01FN3EEB2NVFJAHAPM00000099", nil)
+		req.Header.Set(echo.HeaderXRequestID, strings.Repeat("x", 200))
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		s.Equal(http.StatusNotFound, rec.Code)
+		id := rec.Header().Get(echo.HeaderXRequestID)
+		s.Len(id, 26)
+		problem := new(internal.ErrorResponse)
+		s.NoError(jsoniter.Unmarshal(rec.Body.Bytes(), problem))
+		s.Equal(id, problem.CorrelationID)
+	})
+}
+
+func (s *ContractTestSuite) TestMetrics() {
+	calendars := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"title":"no disponible"}`))
+	}))
+	defer calendars.Close()
+	calendarsURL := config.Config.CalendarsURL
+	defer func() { config.Config.CalendarsURL = calendarsURL }()
+	config.Config.CalendarsURL = calendars.URL + "/"
+	managers.Microservices = &utils.Endpoints{}
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/user/01FN3EEB2NVFJAHAPU00000001/meal", nil),
+		httptest.NewRequest(http.MethodGet, "/user/01FN3EEB2NVFJAHAPU00000001/meal/01FN3EEB2NVFJAHAPM00000099", nil),
+		httptest.NewRequest(http.MethodDelete, "/user/01FN3EEB2NVFJAHAPU00000001/meal/01FN3EEB2NVFJAHAPM00000002", nil),
+		httptest.NewRequest(http.MethodGet, "/no/existe", nil),
+	}
+	requests[2].Header.Set(internal.HeaderIfMatch, `"1"`)
+	for _, req := range requests {
+		s.e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, internal.RouteMetrics, nil))
+
+	s.Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Header().Get(echo.HeaderContentType), echo.MIMETextPlain)
+	for _, series := range []string{
+		`meals_http_requests_total{method="GET",route="/user/:user_id/meal",status="200"}`,
+		`meals_http_requests_total{method="GET",route="/user/:user_id/meal/:id",status="404"}`,
+		`meals_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`meals_http_request_duration_seconds_count{method="DELETE",route="/user/:user_id/meal/:id"}`,
+		`meals_db_query_duration_seconds_count{method="ListMeals"}`,
+		`meals_db_query_duration_seconds_count{method="DeleteMeal"}`,
+		`meals_outbound_request_duration_seconds_count{method="GET",service="calendars",status="503"}`,
+		`meals_outbound_errors_total{method="GET",service="calendars"}`,
+		`go_sql_open_connections{db_name="meals"}`,
+	} {
+		s.Contains(rec.Body.String(), series)
+	}
+}
+
+func (s *ContractTestSuite) TestTracing() {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var calendarTraceParents []string
+	calendars := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calendarTraceParents = append(calendarTraceParents, r.Header.Get("traceparent"))
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer calendars.Close()
+	calendarsURL := config.Config.CalendarsURL
+	defer func() { config.Config.CalendarsURL = calendarsURL }()
+	config.Config.CalendarsURL = calendars.URL + "/"
+	managers.Microservices = &utils.Endpoints{}
+
+	// The global tracer provider and propagator are restored, so the tracing does not leak to other tests
+	tracerProvider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(tracerProvider)
+		otel.SetTextMapPropagator(propagator)
+	}()
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+	s.Require().NoError(err)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider("meals", sdktrace.WithSyncer(exporter))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	otel.SetTracerProvider(provider)
+
+	req := httptest.NewRequest(http.MethodPut, "/user/01FN3EEB2NVFJAHAPU00000001/meal/01FN3EEB2NVFJAHAPM00000001",
+		strings.NewReader(`{"name":"pizza cuatro quesos","type":"ocasional","ingredients":["Tomates"],"seasons":["general"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(internal.HeaderIfMatch, `"1"`)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+
+	s.e.ServeHTTP(rec, req)
+
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		s.Equal(traceID, span.SpanContext.TraceID().String(), span.Name)
+		spans[span.Name] = span
+	}
+	server, ok := spans["PUT "+internal.RouteMealID]
+	s.Require().True(ok, "server span not found in %v", spans)
+	s.Equal(trace.SpanKindServer, server.SpanKind)
+	s.Equal("00f067aa0ba902b7", server.Parent.SpanID().String())
+	for _, name := range []string{"SQLiteMealRepository.GetMeal", "SQLiteMealRepository.UpdateMeal", "calendars GET"} {
+		span, ok := spans[name]
+		s.Require().True(ok, "span %s not found", name)
+		s.Equal(server.SpanContext.SpanID(), span.Parent.SpanID(), name)
+	}
+	s.Equal(trace.SpanKindClient, spans["calendars GET"].SpanKind)
+	s.Require().Len(calendarTraceParents, 1)
+	s.Contains(calendarTraceParents[0], traceID)
+
+	// The credentials of the recipes API are not recorded along with the URL of its calls
+	appID, appKey := config.Config.EdamamAppID, config.Config.EdamamAppKey
+	defer func() { config.Config.EdamamAppID, config.Config.EdamamAppKey = appID, appKey }()
+	config.Config.EdamamAppID, config.Config.EdamamAppKey = "tracing-app-id", "tracing-app-key"
+	exporter.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // The call fails before reaching the API, its span being recorded anyway
+	_, err = managers.NewExternalMealsManager().ListMeals(ctx, "pollo", nil)
+	s.Error(err)
+	recipes := exporter.GetSpans()
+	s.Require().Len(recipes, 1)
+	s.Equal("edamam GET", recipes[0].Name)
+	for _, attribute := range recipes[0].Attributes {
+		s.NotContains(attribute.Value.Emit(), "tracing-app-id", attribute.Key)
+		s.NotContains(attribute.Value.Emit(), "tracing-app-key", attribute.Key)
+	}
+
+	// The secrets still reach the service called
+	var appKeys []string
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appKeys = append(appKeys, r.URL.Query().Get("app_key"))
+	}))
+	defer service.Close()
+	exporter.Reset()
+	client := &http.Client{Transport: tracing.Transport("recipes", nil, "app_key")}
+	resp, err := client.Get(service.URL + "?q=pollo&app_key=tracing-app-key")
+	s.Require().NoError(err)
+	_ = resp.Body.Close()
+	s.Equal([]string{"tracing-app-key"}, appKeys)
+	s.Require().Len(exporter.GetSpans(), 1)
+	for _, attribute := range exporter.GetSpans()[0].Attributes {
+		s.NotContains(attribute.Value.Emit(), "tracing-app-key", attribute.Key)
+	}
+}
@@ -1,19 +1,42 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"github.com/labstack/gommon/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"meals/api"
 	"meals/internal"
 	"meals/internal/config"
 	"meals/internal/handlers"
 	"meals/internal/managers"
+	"meals/internal/metrics"
+	"meals/pkg/blob"
 	"meals/pkg/database"
+	"meals/pkg/lifecycle"
+	"meals/pkg/logging"
+	"meals/pkg/openapi"
+	"meals/pkg/tracing"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const (
+	serviceName = "meals"
+
+	commandHealthcheck = "healthcheck"
+	commandConfigPrint = "config print"
+
 	banner = `
    ___    __  ___  _____        __  ___              __     
   / _ |  /  |/  / / ___/       /  |/  / ___  ___ _  / /  ___
@@ -25,25 +48,117 @@ AMC Users Service
 )
 
 func main() {
-	if err := config.LoadConfiguration(); err != nil {
-		log.Fatal(err)
+	command, args := splitCommand(os.Args[1:])
+	if err := config.LoadConfiguration(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		fatal(err)
+	}
+	switch command {
+	case "":
+	case commandHealthcheck:
+		os.Exit(healthcheck())
+	case commandConfigPrint:
+		if err := config.Print(os.Stdout, &config.Config); err != nil {
+			fatal(err)
+		}
+		return
+	default:
+		fatal(fmt.Errorf("command %q not supported, use %s or %s", command, commandHealthcheck, commandConfigPrint))
+	}
+	slog.SetDefault(logging.New(os.Stdout, config.Config.LogLevel))
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: serviceName,
+		Exporter:    config.Config.TracingExporter,
+		Endpoint:    config.Config.TracingEndpoint,
+		Insecure:    config.Config.TracingInsecure,
+	})
+	if err != nil {
+		fatal(err)
 	}
 
-	db := database.InitDB(config.Config.DBName)
+	db, err := database.InitDB(config.Config.DBName)
+	if err != nil {
+		fatal(err)
+	}
+	managers.Images = imageStore()
+	app := lifecycle.New()
+	app.OnStop("tracing", shutdownTracing)
+	app.OnStop("database", func(context.Context) error { return db.Close() })
+	app.Go("meal tags", func(ctx context.Context) error {
+		tagged, err := managers.NewMealManager(*db).TagMeals(ctx)
+		if tagged > 0 {
+			slog.Info("Meals tagged", "meals", tagged)
+		}
+		return err
+	})
+
 	e := setUpServer(db)
-	e.Logger.Fatal(e.Start(config.Config.Host + ":" + config.Config.Port))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err = serve(ctx, e, net.JoinHostPort(config.Config.Host, config.Config.Port), app, config.Config.ShutdownTimeout); err != nil {
+		slog.Error("The service did not stop cleanly", "error", err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the server until ctx is done, e.g. on SIGTERM. The requests in flight are drained before
+// stopping the workers and releasing the resources of app, all within the timeout
+func serve(ctx context.Context, e *echo.Echo, address string, app *lifecycle.Manager, timeout time.Duration) error {
+	started := make(chan error, 1)
+	go func() {
+		started <- e.Start(address)
+	}()
 
+	var err error
+	select {
+	case err = <-started:
+	case <-ctx.Done():
+		slog.Info("Shutting down", "timeout", timeout.String())
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if shutdownErr := e.Shutdown(shutdownCtx); shutdownErr != nil {
+		err = errors.Join(err, fmt.Errorf("draining the requests: %w", shutdownErr))
+	}
+	if stopErr := app.Shutdown(shutdownCtx); stopErr != nil {
+		err = errors.Join(err, stopErr)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 func setUpServer(db *database.Database) *echo.Echo {
 	e := echo.New()
-	e.Use(middleware.Logger())
+	e.HTTPErrorHandler = internal.HTTPErrorHandler
+	e.Use(logging.RequestIDMiddleware(slog.Default()))
+	e.Use(tracing.Middleware())
+	e.Use(logging.AccessLogMiddleware())
+	e.Use(metrics.Middleware())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
+		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
 	}))
 
+	validator, err := openapi.NewValidator(api.Spec, openapi.Config{
+		Mode: config.Config.OpenAPIValidation,
+		RequestError: func(c echo.Context, err error) error {
+			return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+		},
+		ResponseError: func(c echo.Context, err error) error {
+			return internal.NewErrorResponse(c, internal.ErrSomethingWentWrong)
+		},
+	})
+	if err != nil {
+		fatal(err)
+	}
+	e.Use(validator.Middleware())
+
 	addRoutes(e, *db)
 	e.HideBanner = true
 	fmt.Printf(banner)
@@ -61,9 +176,108 @@ func addRoutes(e *echo.Echo, db database.Database) {
 	e.GET(internal.RouteMeal, mealAPI.ListMealsHandler)
 	e.GET(internal.RouteMealID, mealAPI.GetMealHandler)
 	e.PUT(internal.RouteMealID, mealAPI.PutMealHandler)
+	e.PATCH(internal.RouteMealID, mealAPI.PatchMealHandler)
 	e.DELETE(internal.RouteMealID, mealAPI.DeleteMealHandler)
+	e.POST(internal.RouteMealBatch, mealAPI.BatchMealsHandler)
+	e.GET(internal.RouteMealExport, mealAPI.ExportMealsHandler)
+	e.POST(internal.RoutePlanGenerate, mealAPI.GeneratePlanHandler)
+	e.POST(internal.RouteShoppingList, mealAPI.ShoppingListHandler)
+	e.POST(internal.RouteMealImport, mealAPI.ImportMealsHandler)
+	e.GET(internal.RouteMealCookable, mealAPI.CookableMealsHandler)
+	e.GET(internal.RoutePantry, mealAPI.ListPantryHandler)
+	e.POST(internal.RoutePantry, mealAPI.PostPantryItemHandler)
+	e.GET(internal.RoutePantryItem, mealAPI.GetPantryItemHandler)
+	e.PUT(internal.RoutePantryItem, mealAPI.PutPantryItemHandler)
+	e.DELETE(internal.RoutePantryItem, mealAPI.DeletePantryItemHandler)
+	e.GET(internal.RouteDietProfile, mealAPI.GetDietProfileHandler)
+	e.PUT(internal.RouteDietProfile, mealAPI.PutDietProfileHandler)
+	e.DELETE(internal.RouteDietProfile, mealAPI.DeleteDietProfileHandler)
+	e.POST(internal.RouteMealImage, mealAPI.PostMealImageHandler)
+	e.GET(internal.RouteMealImage, mealAPI.GetMealImageHandler)
+	e.GET(internal.RouteMealImageSize, mealAPI.GetMealImageHandler)
 
 	e.GET(internal.RouteExternalMeals, mealAPI.GetAPIMealsHandler)
 
 	e.GET(internal.RouteIngredients, mealAPI.GetIngredients)
+	e.GET(internal.RouteCatalog, mealAPI.GetCatalogHandler)
+
+	spec, err := openapi.NewDocuments(api.Spec, openapi3.Servers{
+		{URL: serverURL(config.Config.Host, config.Config.Port), Description: "This service"},
+	})
+	if err != nil {
+		fatal(err)
+	}
+	docsAPI := handlers.DocsAPI{Spec: spec}
+	e.GET(internal.RouteOpenAPIYAML, docsAPI.GetSpecYAMLHandler)
+	e.GET(internal.RouteOpenAPIJSON, docsAPI.GetSpecJSONHandler)
+	e.GET(internal.RouteDocs, docsAPI.GetDocsHandler)
+
+	healthManager := managers.NewHealthManager(&db, []managers.Dependency{
+		{Name: managers.DependencyCalendars, URL: config.Config.CalendarsURL},
+		{Name: managers.DependencyUsers, URL: config.Config.UsersURL},
+		{Name: managers.DependencyRecipes, URL: managers.ROUTE},
+	})
+	healthAPI := handlers.HealthAPI{Manager: healthManager}
+	e.GET(internal.RouteHealthz, healthAPI.GetHealthzHandler)
+	e.GET(internal.RouteReadyz, healthAPI.GetReadyzHandler)
+	e.GET(internal.RouteStatus, healthAPI.GetStatusHandler)
+
+	registry := metrics.NewRegistry(db.Conn.DB)
+	e.GET(internal.RouteMetrics, echo.WrapHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+}
+
+// imageStore returns the store of the images of the meals configured
+func imageStore() blob.Store {
+	if config.Config.ImageStore != "s3" {
+		return blob.NewLocal(config.Config.ImageDir)
+	}
+	client := &http.Client{Transport: tracing.Transport(metrics.ServiceImages, metrics.Transport(metrics.ServiceImages, nil))}
+	return blob.NewS3(blob.S3Config{
+		Endpoint:  config.Config.S3Endpoint,
+		Bucket:    config.Config.S3Bucket,
+		Region:    config.Config.S3Region,
+		AccessKey: config.Config.S3AccessKey,
+		SecretKey: config.Config.S3SecretKey,
+	}, client)
+}
+
+// splitCommand splits the words of the command from its flags, e.g. config print --port 3201
+func splitCommand(args []string) (string, []string) {
+	i := 0
+	for i < len(args) && !strings.HasPrefix(args[i], "-") {
+		i++
+	}
+	return strings.Join(args[:i], " "), args[i:]
+}
+
+// healthcheck asks the liveness of the service listening in the port configured, for the images without a shell
+func healthcheck() int {
+	client := &http.Client{Timeout: 2 * time.Second}
+	res, err := client.Get(serverURL("", config.Config.Port) + internal.RouteHealthz)
+	if err != nil {
+		return 1
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 1
+	}
+	return 0
+}
+
+// fatal logs an error that prevents the service from starting and exits
+func fatal(err error) {
+	slog.Error("The service could not start", "error", err)
+	os.Exit(1)
+}
+
+// serverURL is the URL of the service announced in the served spec. A service listening on every
+// interface is announced as localhost
+func serverURL(host, port string) string {
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "localhost"
+	}
+	if port == "" {
+		return "http://" + host
+	}
+	return "http://" + net.JoinHostPort(host, port)
 }
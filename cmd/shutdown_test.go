@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/pkg/lifecycle"
+	"net"
+	"net/http"
+	"time"
+)
+
+func (s *ContractTestSuite) TestGracefulShutdown() {
+	const inFlight = 5
+	started := make(chan struct{}, inFlight)
+	release := make(chan struct{})
+	s.e.GET("/test/slow", func(c echo.Context) error {
+		started <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.e.Listener = listener
+	url := "http://" + listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+
+	app := lifecycle.New()
+	workerStopped := make(chan struct{})
+	app.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(workerStopped)
+		return nil
+	})
+	app.OnStop("database", func(context.Context) error { return s.db.Close() })
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, s.e, "", app, 5*time.Second)
+	}()
+	s.Require().Eventually(func() bool {
+		res, err := client.Get(url + internal.RouteHealthz)
+		if err != nil {
+			return false
+		}
+		_ = res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+
+	statuses := make(chan int, inFlight)
+	for i := 0; i < inFlight; i++ {
+		go func() {
+			res, err := client.Get(url + "/test/slow")
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			_ = res.Body.Close()
+			statuses <- res.StatusCode
+		}()
+	}
+	for i := 0; i < inFlight; i++ {
+		<-started
+	}
+
+	stop()
+	// New connections are refused while the requests in flight are drained
+	s.Require().Eventually(func() bool {
+		res, err := client.Get(url + internal.RouteHealthz)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		return err != nil
+	}, 2*time.Second, 10*time.Millisecond)
+	select {
+	case <-workerStopped:
+		s.Fail("the workers were stopped before draining the requests")
+	default:
+	}
+	s.NoError(s.db.Ping(context.Background()))
+
+	close(release)
+	for i := 0; i < inFlight; i++ {
+		s.Equal(http.StatusOK, <-statuses)
+	}
+	s.NoError(<-served)
+	select {
+	case <-workerStopped:
+	case <-time.After(time.Second):
+		s.Fail("the worker was not stopped")
+	}
+	s.Error(s.db.Ping(context.Background()))
+}
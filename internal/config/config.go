@@ -1,36 +1,331 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var Config Configuration
 
-// Configuration settings of the service.
+// Configuration settings of the service. Every setting is read, from lower to higher precedence, from the
+// default tag, the configuration file, the environment variable named by the mapstructure tag and the
+// command-line flag with the same name in kebab case, e.g. --calendars-url
 type Configuration struct {
 	// Host --> Default 0.0.0.0
 	Host string `mapstructure:"HOST" json:"host" default:"0.0.0.0"`
-	// Port --> Default 49100
+	// Port --> Default 3200
 	Port string `mapstructure:"PORT" json:"port" default:"3200"`
-	// DBName --> Name of the database. Default "amc.db"
-	DBName string `mapstructure:"DB_NAME" json:"DBName" default:"amc.db"`
+	// DBName --> Name of the database, relative to the parent of the working directory. Default "/amc.db"
+	DBName string `mapstructure:"DB_NAME" json:"DBName" default:"/amc.db"`
 	// UsersURL --> URL of the users microservice
-	UsersURL string `mapstructure:"USERS_URL" json:"UsersURL" default:"0.0.0.0:3100"`
-	// CalendarsURL --> URL of the users microservice
-	CalendarsURL string `mapstructure:"CALENDARS_URL" json:"CalendarsURL" default:"0.0.0.0:3300"`
+	UsersURL string `mapstructure:"USERS_URL" json:"UsersURL" default:"http://localhost:3100/" url:"true"`
+	// CalendarsURL --> URL of the calendars microservice
+	CalendarsURL string `mapstructure:"CALENDARS_URL" json:"CalendarsURL" default:"http://localhost:3300/" url:"true"`
+	// EdamamAppID --> Id of the application in the Edamam recipes API
+	EdamamAppID string `mapstructure:"EDAMAM_APP_ID" json:"EdamamAppID"`
+	// EdamamAppKey --> Key of the application in the Edamam recipes API
+	EdamamAppKey string `mapstructure:"EDAMAM_APP_KEY" json:"EdamamAppKey" secret:"true"`
+	// OpenAPIValidation --> Validation of requests and responses against the spec: off, log or enforce. Default "log"
+	OpenAPIValidation string `mapstructure:"OPENAPI_VALIDATION" json:"OpenAPIValidation" default:"log" oneof:"off log enforce"`
+	// LogLevel --> Minimum level of the logs: debug, info, warn or error. Default "info"
+	LogLevel string `mapstructure:"LOG_LEVEL" json:"LogLevel" default:"info" oneof:"debug info warn error"`
+	// TracingExporter --> Exporter of the OpenTelemetry spans: none, stdout or otlp. Default "none"
+	TracingExporter string `mapstructure:"TRACING_EXPORTER" json:"TracingExporter" default:"none" oneof:"none stdout otlp"`
+	// TracingEndpoint --> host:port of the OTLP collector, by default the OTEL_EXPORTER_OTLP_* variables are used
+	TracingEndpoint string `mapstructure:"TRACING_ENDPOINT" json:"TracingEndpoint"`
+	// TracingInsecure --> Sends the spans to the OTLP collector over plain HTTP. Default false
+	TracingInsecure bool `mapstructure:"TRACING_INSECURE" json:"TracingInsecure" default:"false"`
+	// ShutdownTimeout --> Time given to the requests in flight and the workers to finish on SIGTERM. Default "10s"
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT" json:"ShutdownTimeout" default:"10s"`
+	// ImageStore --> Store of the images of the meals: local or s3. Default "local"
+	ImageStore string `mapstructure:"IMAGE_STORE" json:"ImageStore" default:"local" oneof:"local s3"`
+	// ImageDir --> Directory of the images of the meals in the local store. Default "./images"
+	ImageDir string `mapstructure:"IMAGE_DIR" json:"ImageDir" default:"./images"`
+	// S3Endpoint --> Base URL of the S3 compatible store of the images, e.g. https://s3.eu-west-1.amazonaws.com
+	S3Endpoint string `mapstructure:"S3_ENDPOINT" json:"S3Endpoint"`
+	// S3Bucket --> Bucket of the images in the S3 compatible store
+	S3Bucket string `mapstructure:"S3_BUCKET" json:"S3Bucket"`
+	// S3Region --> Region the requests to the S3 compatible store are signed for. Default "us-east-1"
+	S3Region string `mapstructure:"S3_REGION" json:"S3Region" default:"us-east-1"`
+	// S3AccessKey --> Access key of the S3 compatible store
+	S3AccessKey string `mapstructure:"S3_ACCESS_KEY" json:"S3AccessKey"`
+	// S3SecretKey --> Secret key of the S3 compatible store
+	S3SecretKey string `mapstructure:"S3_SECRET_KEY" json:"S3SecretKey" secret:"true"`
 }
 
-func LoadConfiguration() error {
+const (
+	// DefaultFile is read when no configuration file is indicated and it exists
+	DefaultFile = "./internal/config/.env"
+	// FileEnv and fileFlag indicate the configuration file, .env or YAML
+	FileEnv  = "CONFIG_FILE"
+	fileFlag = "config"
 
-	err := godotenv.Load("./internal/config/.env")
+	redacted = "******"
+)
+
+// LoadConfiguration loads the configuration of the service from all the sources, with the flags in args
+func LoadConfiguration(args []string) error {
+	configuration, err := Load(args, os.LookupEnv)
 	if err != nil {
 		return err
 	}
-	Config.Host = os.Getenv("HOST")
-	Config.Port = os.Getenv("PORT")
-	Config.DBName = os.Getenv("DB_NAME")
-	Config.UsersURL = os.Getenv("USERS_URL")
-	Config.CalendarsURL = os.Getenv("CALENDARS_URL")
+	Config = *configuration
 	return nil
 }
+
+// Load returns the configuration from the defaults, the file, the environment read with lookupEnv and
+// the flags in args, in that order of precedence, once validated
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Configuration, error) {
+	configuration := new(Configuration)
+	fields := settings(configuration)
+
+	flags := flag.NewFlagSet("meals", flag.ContinueOnError)
+	file := flags.String(fileFlag, "", "Configuration file, .env or YAML. Default "+DefaultFile+" when it exists")
+	flagValues := make(map[string]*string, len(fields))
+	for _, field := range fields {
+		flagValues[field.name] = flags.String(flagName(field.name), "", field.name)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		if value, ok := field.tag.Lookup("default"); ok {
+			values[field.name] = value
+		}
+	}
+
+	fileValues, err := readFile(*file, lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range fileValues {
+		values[name] = value
+	}
+	for _, field := range fields {
+		if value, ok := lookupEnv(field.name); ok {
+			values[field.name] = value
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != fileFlag {
+			values[envName(f.Name)] = f.Value.String()
+		}
+	})
+
+	var errs []error
+	for _, field := range fields {
+		if err := field.set(values[field.name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		errs = configuration.validate(fields)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("configuration not valid: %w", errors.Join(errs...))
+	}
+	return configuration, nil
+}
+
+// Print writes the configuration in the .env format, with the secrets and the passwords of the URLs redacted
+func Print(w io.Writer, configuration *Configuration) error {
+	for _, field := range settings(configuration) {
+		value := field.String()
+		switch {
+		case value == "":
+		case field.tag.Get("secret") == "true":
+			value = redacted
+		case field.tag.Get("url") == "true":
+			if u, err := url.Parse(value); err == nil {
+				value = u.Redacted()
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", field.name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile returns the settings of the file indicated by the flag or the environment. The default file
+// is optional, so the binary can run from any directory
+func readFile(name string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	if name == "" {
+		name, _ = lookupEnv(FileEnv)
+	}
+	if name == "" {
+		if _, err := os.Stat(DefaultFile); err != nil {
+			return nil, nil
+		}
+		name = DefaultFile
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration file: %w", err)
+	}
+
+	values := map[string]string{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		var document map[string]interface{}
+		if err = yaml.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("reading configuration file %s: %w", name, err)
+		}
+		for key, value := range document {
+			values[envName(key)] = fmt.Sprint(value)
+		}
+	default:
+		env, err := godotenv.Unmarshal(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("reading configuration file %s: %w", name, err)
+		}
+		for key, value := range env {
+			values[envName(key)] = value
+		}
+	}
+	return values, nil
+}
+
+func (c *Configuration) validate(fields []setting) []error {
+	var errs []error
+	for _, field := range fields {
+		value := field.String()
+		if options, ok := field.tag.Lookup("oneof"); ok && !contains(strings.Fields(options), value) {
+			errs = append(errs, fmt.Errorf("%s: %q must be one of: %s", field.name, value, strings.Join(strings.Fields(options), ", ")))
+		}
+		if field.tag.Get("url") == "true" {
+			normalized, err := baseURL(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", field.name, err))
+				continue
+			}
+			field.value.SetString(normalized)
+		}
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q must be a number between 1 and 65535", c.Port))
+	}
+	if c.Host != "" && net.ParseIP(c.Host) == nil && strings.ContainsAny(c.Host, ":/ ") {
+		errs = append(errs, fmt.Errorf("HOST: %q must be an IP or a host name", c.Host))
+	}
+	if c.DBName == "" {
+		errs = append(errs, errors.New("DB_NAME: must not be empty"))
+	}
+	if c.TracingEndpoint != "" {
+		if _, _, err := net.SplitHostPort(c.TracingEndpoint); err != nil {
+			errs = append(errs, fmt.Errorf("TRACING_ENDPOINT: %q must be host:port", c.TracingEndpoint))
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_TIMEOUT: %s must be positive", c.ShutdownTimeout))
+	}
+	switch c.ImageStore {
+	case "local":
+		if c.ImageDir == "" {
+			errs = append(errs, errors.New("IMAGE_DIR: must not be empty"))
+		}
+	case "s3":
+		if _, err := baseURL(c.S3Endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("S3_ENDPOINT: %w", err))
+		}
+		if c.S3Bucket == "" {
+			errs = append(errs, errors.New("S3_BUCKET: must not be empty"))
+		}
+	}
+	return errs
+}
+
+// baseURL checks the URL of a service is absolute and ends with a slash, as the paths are appended to it
+func baseURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q must be an absolute http or https URL", value)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
+
+// setting is a field of the configuration along with the name of its environment variable
+type setting struct {
+	name  string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+func settings(configuration *Configuration) []setting {
+	value := reflect.ValueOf(configuration).Elem()
+	fields := make([]setting, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fields = append(fields, setting{name: field.Tag.Get("mapstructure"), tag: field.Tag, value: value.Field(i)})
+	}
+	return fields
+}
+
+func (s setting) set(value string) error {
+	var err error
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(value)
+	case bool:
+		var b bool
+		if value != "" {
+			b, err = strconv.ParseBool(value)
+		}
+		s.value.SetBool(b)
+	case time.Duration:
+		var d time.Duration
+		if value != "" {
+			d, err = time.ParseDuration(value)
+		}
+		s.value.SetInt(int64(d))
+	default:
+		err = fmt.Errorf("type %s not supported", s.value.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %q is not valid: %w", s.name, value, err)
+	}
+	return nil
+}
+
+func (s setting) String() string {
+	return fmt.Sprint(s.value.Interface())
+}
+
+// flagName returns the flag of an environment variable, e.g. --calendars-url for CALENDARS_URL
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// envName returns the environment variable of a flag or a key of a file, e.g. CALENDARS_URL for calendars_url
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+func contains(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
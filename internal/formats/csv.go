@@ -0,0 +1,209 @@
+package formats
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"meals/internal/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	CSV = "csv"
+
+	// csvListSeparator separates the items of the list columns (ingredient names may contain commas), escaped
+	// with a backslash in the items
+	csvListSeparator = "|"
+
+	// csvFormulaPrefixes are the first characters of the cells that the spreadsheets run as formulas
+	csvFormulaPrefixes = "=+-@\t\r"
+)
+
+var csvHeader = []string{"id", "name", "description", "image", "type", "ingredients", "kcal", "seasons", "servings", "prep_time", "cook_time", "difficulty", "equipment", "steps"}
+
+func init() {
+	register(Format{
+		Name:        CSV,
+		ContentType: "text/csv",
+		Extension:   ".csv",
+		NewEncoder: func(w io.Writer) Encoder {
+			return &csvEncoder{w: csv.NewWriter(w)}
+		},
+		Decode: decodeCSV,
+	})
+}
+
+// csvEncoder writes a header and a row per meal
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) Encode(meal *models.Meal) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	steps := make([]string, 0, len(meal.Steps))
+	for _, step := range meal.Steps {
+		steps = append(steps, stepText(step))
+	}
+	err := e.w.Write([]string{
+		meal.Id,
+		csvCell(meal.Name),
+		csvCell(meal.Description),
+		csvCell(meal.Image),
+		csvCell(meal.Type),
+		csvCell(joinList(meal.Ingredients)),
+		strconv.Itoa(meal.Kcal),
+		csvCell(joinList(meal.Seasons)),
+		strconv.Itoa(meal.Servings),
+		strconv.Itoa(meal.PrepTime),
+		strconv.Itoa(meal.CookTime),
+		csvCell(meal.Difficulty),
+		csvCell(joinList(meal.Equipment)),
+		csvCell(joinList(steps)),
+	})
+	if err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// decodeCSV reads the columns by the name of the header, so they can come in any order
+func decodeCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("csv without name column")
+	}
+
+	var rows []Row
+	for line := 1; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows = append(rows, Row{Row: line, Err: err})
+			continue
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(values) {
+				return strings.TrimSpace(csvValue(values[i]))
+			}
+			return ""
+		}
+		row := Row{Row: line, Meal: models.Meal{
+			Name:        get("name"),
+			Description: get("description"),
+			Image:       get("image"),
+			Type:        get("type"),
+			Ingredients: splitList(get("ingredients")),
+			Seasons:     splitList(get("seasons")),
+			Difficulty:  get("difficulty"),
+			Equipment:   splitList(get("equipment")),
+		}}
+		for _, step := range splitList(get("steps")) {
+			row.Meal.Steps = append(row.Meal.Steps, stepFromText(step))
+		}
+		if kcal := get("kcal"); kcal != "" {
+			if row.Meal.Kcal, err = strconv.Atoi(kcal); err != nil {
+				row.Err = fmt.Errorf("invalid kcal %q", kcal)
+			}
+		}
+		if servings := get("servings"); servings != "" {
+			if row.Meal.Servings, err = strconv.Atoi(servings); err != nil {
+				row.Err = fmt.Errorf("invalid servings %q", servings)
+			}
+		}
+		if prepTime := get("prep_time"); prepTime != "" {
+			if row.Meal.PrepTime, err = strconv.Atoi(prepTime); err != nil {
+				row.Err = fmt.Errorf("invalid prep_time %q", prepTime)
+			}
+		}
+		if cookTime := get("cook_time"); cookTime != "" {
+			if row.Meal.CookTime, err = strconv.Atoi(cookTime); err != nil {
+				row.Err = fmt.Errorf("invalid cook_time %q", cookTime)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// csvCell prefixes the text cells that a spreadsheet would run as a formula with a quote, so they are shown
+// as they are
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvValue returns the value of a cell exported by csvCell
+func csvValue(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// csvListEscaper escapes the separator in the items of the list columns, so "Mezclar | batir" is a single step
+var csvListEscaper = strings.NewReplacer(`\`, `\\`, csvListSeparator, `\`+csvListSeparator)
+
+func joinList(items []string) string {
+	escaped := make([]string, 0, len(items))
+	for _, item := range items {
+		escaped = append(escaped, csvListEscaper.Replace(item))
+	}
+	return strings.Join(escaped, csvListSeparator)
+}
+
+// splitList splits the items of a list column written by joinList. Any other backslash is kept as it is
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var items []string
+	var item strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && (value[i+1] == '\\' || value[i+1] == csvListSeparator[0]):
+			i++
+			item.WriteByte(value[i])
+		case value[i] == csvListSeparator[0]:
+			items = append(items, strings.TrimSpace(item.String()))
+			item.Reset()
+		default:
+			item.WriteByte(value[i])
+		}
+	}
+	return append(items, strings.TrimSpace(item.String()))
+}
@@ -0,0 +1,257 @@
+package formats
+
+import (
+	"fmt"
+	"io"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Encoder writes meals one by one, so big recipe books can be streamed
+type Encoder interface {
+	Encode(meal *models.Meal) error
+	// Close writes whatever the format needs after the last meal
+	Close() error
+}
+
+// Row is a meal read from an imported file. Rows that could not be read carry the error instead.
+// Recipes from other apps have free text ingredients ("200 g de calabacín") to be matched to the catalog
+type Row struct {
+	Row              int
+	Meal             models.Meal
+	MatchIngredients bool
+	Err              error
+}
+
+// Format is a file format meals can be exported to and imported from
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	NewEncoder  func(w io.Writer) Encoder
+	Decode      func(r io.Reader) ([]Row, error)
+}
+
+var registry = map[string]Format{}
+
+func register(format Format) {
+	registry[format.Name] = format
+}
+
+// Get returns the format with the name indicated
+func Get(name string) (Format, bool) {
+	format, ok := registry[strings.ToLower(name)]
+	return format, ok
+}
+
+// FromContentType returns the format of the media type indicated
+func FromContentType(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Format{}, false
+	}
+	for _, format := range registry {
+		if format.ContentType == mediaType {
+			return format, true
+		}
+	}
+	// Aliases commonly used by clients
+	switch mediaType {
+	case "application/x-yaml", "text/yaml":
+		return Get(YAML)
+	case "application/csv":
+		return Get(CSV)
+	}
+	return Format{}, false
+}
+
+// FromFilename returns the format of the file indicated by its extension
+func FromFilename(filename string) (Format, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case ".yml":
+		ext = ".yaml"
+	case ".paprikarecipe":
+		ext = ".paprikarecipes"
+	}
+	for _, format := range registry {
+		if format.Extension == ext {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// record is the portable representation of a meal shared by the tabular formats
+type record struct {
+	Id          string       `json:"id,omitempty" yaml:"id,omitempty"`
+	Name        string       `json:"name" yaml:"name"`
+	Description string       `json:"description" yaml:"description"`
+	Image       string       `json:"image" yaml:"image"`
+	Type        string       `json:"type" yaml:"type"`
+	Ingredients []string     `json:"ingredients" yaml:"ingredients"`
+	Kcal        int          `json:"kcal" yaml:"kcal"`
+	Seasons     []string     `json:"seasons" yaml:"seasons"`
+	Servings    int          `json:"servings,omitempty" yaml:"servings,omitempty"`
+	PrepTime    int          `json:"prep_time,omitempty" yaml:"prep_time,omitempty"`
+	CookTime    int          `json:"cook_time,omitempty" yaml:"cook_time,omitempty"`
+	Difficulty  string       `json:"difficulty,omitempty" yaml:"difficulty,omitempty"`
+	Equipment   []string     `json:"equipment,omitempty" yaml:"equipment,omitempty"`
+	Steps       []stepRecord `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+type stepRecord struct {
+	Text         string `json:"text" yaml:"text"`
+	TimerMinutes int    `json:"timer_minutes,omitempty" yaml:"timer_minutes,omitempty"`
+}
+
+func toRecord(meal *models.Meal) record {
+	var steps []stepRecord
+	for _, step := range meal.Steps {
+		steps = append(steps, stepRecord{Text: step.Text, TimerMinutes: step.TimerMinutes})
+	}
+	return record{
+		Id:          meal.Id,
+		Name:        meal.Name,
+		Description: meal.Description,
+		Image:       meal.Image,
+		Type:        meal.Type,
+		Ingredients: meal.Ingredients,
+		Kcal:        meal.Kcal,
+		Seasons:     meal.Seasons,
+		Servings:    meal.Servings,
+		PrepTime:    meal.PrepTime,
+		CookTime:    meal.CookTime,
+		Difficulty:  meal.Difficulty,
+		Equipment:   meal.Equipment,
+		Steps:       steps,
+	}
+}
+
+// toMeal returns the meal of an imported record. Ids are not kept, every imported meal gets a new one
+func (r record) toMeal() models.Meal {
+	var steps []models.Step
+	for _, step := range r.Steps {
+		steps = append(steps, models.Step{Text: step.Text, TimerMinutes: step.TimerMinutes})
+	}
+	return models.Meal{
+		Name:        r.Name,
+		Description: r.Description,
+		Image:       r.Image,
+		Type:        r.Type,
+		Ingredients: r.Ingredients,
+		Kcal:        r.Kcal,
+		Seasons:     r.Seasons,
+		Servings:    r.Servings,
+		PrepTime:    r.PrepTime,
+		CookTime:    r.CookTime,
+		Difficulty:  r.Difficulty,
+		Equipment:   r.Equipment,
+		Steps:       steps,
+	}
+}
+
+func recordsToRows(records []record) []Row {
+	rows := make([]Row, 0, len(records))
+	for i, r := range records {
+		rows = append(rows, Row{Row: i + 1, Meal: r.toMeal()})
+	}
+	return rows
+}
+
+var mealTypes = []string{"semanal", "ocasional", "normal"}
+
+var seasons = map[string]string{
+	"primavera": "primavera",
+	"verano":    "verano",
+	"otono":     "otoño",
+	"invierno":  "invierno",
+	"general":   "general",
+}
+
+var servingsRegexp = regexp.MustCompile(`\d+`)
+
+// servingsFromYield returns the servings of the yield of a recipe, e.g. 4 for "4 raciones", 0 when it has none
+func servingsFromYield(yield string) int {
+	servings, _ := strconv.Atoi(servingsRegexp.FindString(yield))
+	if servings > models.MaxServings {
+		return 0
+	}
+	return servings
+}
+
+var (
+	stepTimerRegexp = regexp.MustCompile(`^(.*?)\s*\((\d+) min\)$`)
+	hoursRegexp     = regexp.MustCompile(`(?i)(\d+)\s*(?:h|hrs?|horas?|hours?)\b`)
+	minutesRegexp   = regexp.MustCompile(`(?i)(\d+)\s*(?:m|mins?|minutos?|minutes?)\b`)
+)
+
+// difficulties are the difficulties of the meals by the names other apps give them
+var difficulties = map[string]string{
+	"facil": "facil", "easy": "facil",
+	"media": "media", "medium": "media",
+	"dificil": "dificil", "hard": "dificil", "difficult": "dificil",
+}
+
+// stepText returns the step as a line of text, with its timer at the end, e.g. "Hornear (20 min)"
+func stepText(step models.Step) string {
+	if step.TimerMinutes > 0 {
+		return fmt.Sprintf("%s (%d min)", step.Text, step.TimerMinutes)
+	}
+	return step.Text
+}
+
+// stepFromText returns the step of a line of text written by stepText
+func stepFromText(line string) models.Step {
+	line = strings.TrimSpace(line)
+	if match := stepTimerRegexp.FindStringSubmatch(line); match != nil {
+		timer, _ := strconv.Atoi(match[2])
+		return models.Step{Text: match[1], TimerMinutes: timer}
+	}
+	return models.Step{Text: line}
+}
+
+// minutesFromText returns the minutes of a time written as text, e.g. 90 for "1 h 30 min". A number alone
+// is taken as minutes
+func minutesFromText(value string) int {
+	if minutes, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return minutes
+	}
+	total := 0
+	for _, match := range hoursRegexp.FindAllStringSubmatch(value, -1) {
+		hours, _ := strconv.Atoi(match[1])
+		total += hours * 60
+	}
+	for _, match := range minutesRegexp.FindAllStringSubmatch(value, -1) {
+		minutes, _ := strconv.Atoi(match[1])
+		total += minutes
+	}
+	return total
+}
+
+func isMealType(value string) bool {
+	for _, t := range mealTypes {
+		if t == value {
+			return true
+		}
+	}
+	return false
+}
+
+// seasonsFromKeywords returns the seasons among the keywords (or categories) of a recipe, "general" when none
+func seasonsFromKeywords(keywords []string) (result []string) {
+	for _, keyword := range keywords {
+		if season, ok := seasons[text.Fold(keyword)]; ok {
+			result = append(result, season)
+		}
+	}
+	if len(result) == 0 {
+		result = []string{"general"}
+	}
+	return
+}
@@ -0,0 +1,59 @@
+package formats
+
+import (
+	"encoding/json"
+	"io"
+	"meals/internal/models"
+)
+
+const JSON = "json"
+
+func init() {
+	register(Format{
+		Name:        JSON,
+		ContentType: "application/json",
+		Extension:   ".json",
+		NewEncoder:  func(w io.Writer) Encoder { return &jsonEncoder{w: w} },
+		Decode:      decodeJSON,
+	})
+}
+
+// jsonEncoder writes the meals as a JSON array
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) Encode(meal *models.Meal) error {
+	content, err := json.Marshal(toRecord(meal))
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if e.count == 0 {
+		separator = "[\n"
+	}
+	e.count++
+	if _, err = io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(content)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	closing := "\n]\n"
+	if e.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(e.w, closing)
+	return err
+}
+
+func decodeJSON(r io.Reader) ([]Row, error) {
+	var records []record
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+	return recordsToRows(records), nil
+}
@@ -0,0 +1,314 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	JSONLD = "jsonld"
+
+	schemaContext = "https://schema.org"
+)
+
+var (
+	ldScriptRegexp   = regexp.MustCompile(`(?is)<script[^>]+application/ld\+json[^>]*>(.*?)</script>`)
+	durationRegexp   = regexp.MustCompile(`(?i)^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:[\d.]+S)?)?$`)
+	caloriesRegexp   = regexp.MustCompile(`\d+(\.\d+)?`)
+	errRecipeMissing = errors.New("no schema.org Recipe found")
+)
+
+func init() {
+	register(Format{
+		Name:        JSONLD,
+		ContentType: "application/ld+json",
+		Extension:   ".jsonld",
+		NewEncoder:  func(w io.Writer) Encoder { return &jsonLDEncoder{w: w} },
+		Decode:      decodeJSONLD,
+	})
+}
+
+// Recipe is a meal as a schema.org Recipe (https://schema.org/Recipe)
+type Recipe struct {
+	Context            string      `json:"@context,omitempty"`
+	Type               string      `json:"@type"`
+	Identifier         string      `json:"identifier,omitempty"`
+	Name               string      `json:"name"`
+	Description        string      `json:"description,omitempty"`
+	Image              string      `json:"image,omitempty"`
+	RecipeCategory     string      `json:"recipeCategory,omitempty"`
+	Keywords           string      `json:"keywords,omitempty"`
+	RecipeIngredient   []string    `json:"recipeIngredient"`
+	RecipeYield        string      `json:"recipeYield,omitempty"`
+	RecipeInstructions []HowToStep `json:"recipeInstructions,omitempty"`
+	PrepTime           string      `json:"prepTime,omitempty"`
+	CookTime           string      `json:"cookTime,omitempty"`
+	TotalTime          string      `json:"totalTime,omitempty"`
+	Tool               []string    `json:"tool,omitempty"`
+	Nutrition          *Nutrition  `json:"nutrition,omitempty"`
+}
+
+// HowToStep is a step of the instructions of a recipe, the time required being the one of its timer
+type HowToStep struct {
+	Type         string `json:"@type"`
+	Position     int    `json:"position"`
+	Text         string `json:"text"`
+	TimeRequired string `json:"timeRequired,omitempty"`
+}
+
+type Nutrition struct {
+	Type     string `json:"@type"`
+	Calories string `json:"calories"`
+}
+
+// ToRecipe returns the schema.org Recipe of the meal. The type of the meal is the category
+// of the recipe and its seasons the keywords. The calories are the ones of a serving, as in schema.org.
+// The difficulty is left out, schema.org has no property for it
+func ToRecipe(meal *models.Meal) Recipe {
+	recipe := Recipe{
+		Context:          schemaContext,
+		Type:             "Recipe",
+		Identifier:       meal.Id,
+		Name:             meal.Name,
+		Description:      meal.Description,
+		Image:            meal.Image,
+		RecipeCategory:   meal.Type,
+		Keywords:         strings.Join(meal.Seasons, ", "),
+		RecipeIngredient: meal.Ingredients,
+		PrepTime:         isoDuration(meal.PrepTime),
+		CookTime:         isoDuration(meal.CookTime),
+		TotalTime:        isoDuration(meal.PrepTime + meal.CookTime),
+		Tool:             meal.Equipment,
+	}
+	if meal.Servings > 0 {
+		recipe.RecipeYield = strconv.Itoa(meal.Servings)
+	}
+	for i, step := range meal.Steps {
+		recipe.RecipeInstructions = append(recipe.RecipeInstructions, HowToStep{
+			Type:         "HowToStep",
+			Position:     i + 1,
+			Text:         step.Text,
+			TimeRequired: isoDuration(step.TimerMinutes),
+		})
+	}
+	if meal.Kcal > 0 {
+		recipe.Nutrition = &Nutrition{Type: "NutritionInformation", Calories: strconv.Itoa(meal.Kcal) + " kcal"}
+	}
+	return recipe
+}
+
+// jsonLDEncoder writes the meals as the @graph of a single JSON-LD document
+type jsonLDEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonLDEncoder) Encode(meal *models.Meal) error {
+	recipe := ToRecipe(meal)
+	recipe.Context = ""
+	content, err := json.Marshal(recipe)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if e.count == 0 {
+		separator = `{"@context":"` + schemaContext + `","@graph":[` + "\n"
+	}
+	e.count++
+	if _, err = io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(content)
+	return err
+}
+
+func (e *jsonLDEncoder) Close() error {
+	closing := "\n]}\n"
+	if e.count == 0 {
+		closing = `{"@context":"` + schemaContext + `","@graph":[]}` + "\n"
+	}
+	_, err := io.WriteString(e.w, closing)
+	return err
+}
+
+// decodeJSONLD reads the recipes of a JSON-LD document, which may be a single recipe, a list, a @graph
+// or even the HTML of a recipe page with the JSON-LD in its script tags
+func decodeJSONLD(r io.Reader) ([]Row, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	blocks := [][]byte{content}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '<' {
+		blocks = nil
+		for _, match := range ldScriptRegexp.FindAllSubmatch(content, -1) {
+			blocks = append(blocks, match[1])
+		}
+	}
+
+	var rows []Row
+	for _, block := range blocks {
+		var document interface{}
+		if err = json.Unmarshal(block, &document); err != nil {
+			return nil, err
+		}
+		for _, node := range findRecipes(document) {
+			rows = append(rows, Row{Row: len(rows) + 1, Meal: recipeToMeal(node), MatchIngredients: true})
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errRecipeMissing
+	}
+	return rows, nil
+}
+
+// findRecipes walks the JSON-LD document looking for the nodes of type Recipe
+func findRecipes(node interface{}) (recipes []map[string]interface{}) {
+	switch value := node.(type) {
+	case []interface{}:
+		for _, item := range value {
+			recipes = append(recipes, findRecipes(item)...)
+		}
+	case map[string]interface{}:
+		for _, t := range ldStrings(value["@type"]) {
+			if t == "Recipe" || t == "schema:Recipe" {
+				return []map[string]interface{}{value}
+			}
+		}
+		recipes = append(recipes, findRecipes(value["@graph"])...)
+	}
+	return
+}
+
+func recipeToMeal(node map[string]interface{}) models.Meal {
+	meal := models.Meal{
+		Name:        ldString(node["name"]),
+		Description: ldString(node["description"]),
+		Image:       ldString(node["image"]),
+		Type:        "normal",
+		Ingredients: ldStrings(node["recipeIngredient"]),
+	}
+	if len(meal.Ingredients) == 0 {
+		// Older recipes use the deprecated ingredients property
+		meal.Ingredients = ldStrings(node["ingredients"])
+	}
+	if category := text.Fold(ldString(node["recipeCategory"])); isMealType(category) {
+		meal.Type = category
+	}
+	var keywords []string
+	for _, keyword := range ldStrings(node["keywords"]) {
+		keywords = append(keywords, strings.Split(keyword, ",")...)
+	}
+	meal.Seasons = seasonsFromKeywords(keywords)
+	meal.Servings = servingsFromYield(ldString(node["recipeYield"]))
+	meal.Steps = ldSteps(node["recipeInstructions"])
+	meal.PrepTime = durationMinutes(ldString(node["prepTime"]))
+	meal.CookTime = durationMinutes(ldString(node["cookTime"]))
+	if meal.PrepTime+meal.CookTime == 0 {
+		// Some recipes only have the total time
+		meal.CookTime = durationMinutes(ldString(node["totalTime"]))
+	}
+	meal.Equipment = ldStrings(node["tool"])
+	if nutrition, ok := node["nutrition"].(map[string]interface{}); ok {
+		if calories := caloriesRegexp.FindString(ldString(nutrition["calories"])); calories != "" {
+			kcal, _ := strconv.ParseFloat(calories, 64)
+			meal.Kcal = int(kcal)
+		}
+	}
+	return meal
+}
+
+// ldString returns the text of a JSON-LD value, which may be a string, a list or an object with url
+func ldString(value interface{}) string {
+	values := ldStrings(value)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func ldStrings(value interface{}) (values []string) {
+	switch v := value.(type) {
+	case string:
+		if s := strings.TrimSpace(v); s != "" {
+			values = append(values, s)
+		}
+	case float64:
+		values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+	case []interface{}:
+		for _, item := range v {
+			if s := ldString(item); s != "" {
+				values = append(values, s)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"url", "@id", "name", "text"} {
+			if s, ok := v[key].(string); ok {
+				return []string{s}
+			}
+		}
+	}
+	return
+}
+
+// ldSteps returns the steps of the instructions of a recipe, which may be a text with a step per line, a list
+// of texts or of HowToStep, or HowToSection grouping them
+func ldSteps(value interface{}) (steps []models.Step) {
+	switch v := value.(type) {
+	case string:
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				steps = append(steps, models.Step{Text: line})
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			steps = append(steps, ldSteps(item)...)
+		}
+	case map[string]interface{}:
+		if elements, ok := v["itemListElement"]; ok {
+			return ldSteps(elements)
+		}
+		text := ldString(v["text"])
+		if text == "" {
+			text = ldString(v["name"])
+		}
+		if text != "" {
+			steps = append(steps, models.Step{Text: text, TimerMinutes: durationMinutes(ldString(v["timeRequired"]))})
+		}
+	}
+	return
+}
+
+// isoDuration returns the ISO 8601 duration of the minutes, e.g. PT1H30M, or nothing for 0
+func isoDuration(minutes int) string {
+	switch {
+	case minutes <= 0:
+		return ""
+	case minutes < 60:
+		return fmt.Sprintf("PT%dM", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("PT%dH", minutes/60)
+	}
+	return fmt.Sprintf("PT%dH%dM", minutes/60, minutes%60)
+}
+
+// durationMinutes returns the minutes of an ISO 8601 duration, 0 when it is not one
+func durationMinutes(duration string) int {
+	match := durationRegexp.FindStringSubmatch(strings.TrimSpace(duration))
+	if match == nil {
+		return 0
+	}
+	days, _ := strconv.Atoi(match[1])
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	return (days*24+hours)*60 + minutes
+}
@@ -0,0 +1,241 @@
+package formats
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PAPRIKA is the export format of the Paprika recipe manager: a zip archive (.paprikarecipes)
+// with a gzip compressed JSON file (.paprikarecipe) per recipe
+const PAPRIKA = "paprika"
+
+// paprikaEquipment starts the line of the notes with the equipment, as Paprika has no field for it
+const paprikaEquipment = "Utensilios: "
+
+// paprikaCaloriesRegexp finds the calories in the free text of the nutritional info, e.g. "Fat: 12 g\nCalories: 450",
+// as the number after the calories or before kcal
+var paprikaCaloriesRegexp = regexp.MustCompile(`(?i)calor\p{L}*\s*:?\s*(\d+)|(\d+)\s*kcal`)
+
+func init() {
+	register(Format{
+		Name:        PAPRIKA,
+		ContentType: "application/zip",
+		Extension:   ".paprikarecipes",
+		NewEncoder:  func(w io.Writer) Encoder { return &paprikaEncoder{zip: zip.NewWriter(w), names: map[string]int{}} },
+		Decode:      decodePaprika,
+	})
+}
+
+type paprikaRecipe struct {
+	UID         string   `json:"uid"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Ingredients string   `json:"ingredients"`
+	Directions  string   `json:"directions"`
+	Notes       string   `json:"notes"`
+	ImageURL    string   `json:"image_url"`
+	SourceURL   string   `json:"source_url"`
+	Categories  []string `json:"categories"`
+	Servings    string   `json:"servings"`
+	PrepTime    string   `json:"prep_time"`
+	CookTime    string   `json:"cook_time"`
+	TotalTime   string   `json:"total_time"`
+	Difficulty  string   `json:"difficulty"`
+	Nutrition   string   `json:"nutritional_info"`
+	Hash        string   `json:"hash"`
+}
+
+type paprikaEncoder struct {
+	zip   *zip.Writer
+	names map[string]int
+}
+
+func (e *paprikaEncoder) Encode(meal *models.Meal) error {
+	recipe := paprikaRecipe{
+		UID:         meal.Id,
+		Name:        meal.Name,
+		Description: meal.Description,
+		Ingredients: strings.Join(meal.Ingredients, "\n"),
+		ImageURL:    meal.Image,
+		Categories:  append([]string{meal.Type}, meal.Seasons...),
+	}
+	if meal.Servings > 0 {
+		recipe.Servings = strconv.Itoa(meal.Servings)
+	}
+	steps := make([]string, 0, len(meal.Steps))
+	for _, step := range meal.Steps {
+		steps = append(steps, stepText(step))
+	}
+	recipe.Directions = strings.Join(steps, "\n")
+	if meal.PrepTime > 0 {
+		recipe.PrepTime = fmt.Sprintf("%d min", meal.PrepTime)
+	}
+	if meal.CookTime > 0 {
+		recipe.CookTime = fmt.Sprintf("%d min", meal.CookTime)
+	}
+	if total := meal.PrepTime + meal.CookTime; total > 0 {
+		recipe.TotalTime = fmt.Sprintf("%d min", total)
+	}
+	recipe.Difficulty = meal.Difficulty
+	if len(meal.Equipment) > 0 {
+		recipe.Notes = paprikaEquipment + strings.Join(meal.Equipment, ", ")
+	}
+	if meal.Kcal > 0 {
+		recipe.Nutrition = fmt.Sprintf("Calories: %d kcal", meal.Kcal)
+	}
+	content, err := json.Marshal(recipe)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(content)
+	recipe.Hash = hex.EncodeToString(hash[:])
+	if content, err = json.Marshal(recipe); err != nil {
+		return err
+	}
+
+	file, err := e.zip.Create(e.fileName(meal.Name))
+	if err != nil {
+		return err
+	}
+	compressed := gzip.NewWriter(file)
+	if _, err = compressed.Write(content); err != nil {
+		return err
+	}
+	if err = compressed.Close(); err != nil {
+		return err
+	}
+	return e.zip.Flush()
+}
+
+// fileName returns a file name for the recipe not used yet in the archive
+func (e *paprikaEncoder) fileName(name string) string {
+	name = strings.NewReplacer("/", "-", "\\", "-").Replace(name)
+	e.names[name]++
+	if count := e.names[name]; count > 1 {
+		name = fmt.Sprintf("%s (%d)", name, count)
+	}
+	return name + ".paprikarecipe"
+}
+
+func (e *paprikaEncoder) Close() error {
+	return e.zip.Close()
+}
+
+// decodePaprika reads a .paprikarecipes archive, a single .paprikarecipe file or its plain JSON
+func decodePaprika(r io.Reader) ([]Row, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(content, []byte("PK")) {
+		recipe, err := readPaprikaRecipe(content)
+		if err != nil {
+			return nil, err
+		}
+		return []Row{{Row: 1, Meal: recipe.toMeal(), MatchIngredients: true}}, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for i, file := range archive.File {
+		row := Row{Row: i + 1, MatchIngredients: true}
+		recipe, err := readPaprikaFile(file)
+		if err != nil {
+			row.Meal.Name, row.Err = strings.TrimSuffix(file.Name, ".paprikarecipe"), err
+		} else {
+			row.Meal = recipe.toMeal()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readPaprikaFile(file *zip.File) (*paprikaRecipe, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return readPaprikaRecipe(content)
+}
+
+// readPaprikaRecipe reads a recipe, gzip compressed or not
+func readPaprikaRecipe(content []byte) (*paprikaRecipe, error) {
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		if content, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+	recipe := &paprikaRecipe{}
+	if err := json.Unmarshal(content, recipe); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+func (p *paprikaRecipe) toMeal() models.Meal {
+	meal := models.Meal{
+		Name:        strings.TrimSpace(p.Name),
+		Description: strings.TrimSpace(p.Description),
+		Image:       p.ImageURL,
+		Type:        "normal",
+		Seasons:     seasonsFromKeywords(p.Categories),
+		Servings:    servingsFromYield(p.Servings),
+		PrepTime:    minutesFromText(p.PrepTime),
+		CookTime:    minutesFromText(p.CookTime),
+		Difficulty:  difficulties[text.Fold(p.Difficulty)],
+	}
+	if meal.PrepTime+meal.CookTime == 0 {
+		meal.CookTime = minutesFromText(p.TotalTime)
+	}
+	for _, line := range strings.Split(p.Directions, "\n") {
+		if strings.TrimSpace(line) != "" {
+			meal.Steps = append(meal.Steps, stepFromText(line))
+		}
+	}
+	for _, line := range strings.Split(p.Notes, "\n") {
+		if equipment, ok := strings.CutPrefix(strings.TrimSpace(line), paprikaEquipment); ok {
+			for _, item := range strings.Split(equipment, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					meal.Equipment = append(meal.Equipment, item)
+				}
+			}
+		}
+	}
+	for _, category := range p.Categories {
+		if isMealType(strings.ToLower(category)) {
+			meal.Type = strings.ToLower(category)
+		}
+	}
+	for _, line := range strings.Split(p.Ingredients, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			meal.Ingredients = append(meal.Ingredients, line)
+		}
+	}
+	if calories := paprikaCaloriesRegexp.FindStringSubmatch(p.Nutrition); calories != nil {
+		meal.Kcal, _ = strconv.Atoi(calories[1] + calories[2])
+	}
+	return meal
+}
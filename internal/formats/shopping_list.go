@@ -0,0 +1,119 @@
+package formats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"meals/internal/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	Text     = "text"
+	Markdown = "markdown"
+)
+
+// ShoppingListFormat is a format a shopping list can be exported to
+type ShoppingListFormat struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Write       func(w io.Writer, list *models.ShoppingList) error
+}
+
+var shoppingListFormats = map[string]ShoppingListFormat{
+	Text:     {Name: Text, ContentType: "text/plain; charset=utf-8", Extension: ".txt", Write: writeShoppingListText},
+	Markdown: {Name: Markdown, ContentType: "text/markdown; charset=utf-8", Extension: ".md", Write: writeShoppingListMarkdown},
+	CSV:      {Name: CSV, ContentType: "text/csv; charset=utf-8", Extension: ".csv", Write: writeShoppingListCSV},
+}
+
+// GetShoppingListFormat returns the format of shopping lists with the name indicated
+func GetShoppingListFormat(name string) (ShoppingListFormat, bool) {
+	format, ok := shoppingListFormats[strings.ToLower(name)]
+	return format, ok
+}
+
+// writeShoppingListText writes a line per ingredient under the name of its category
+func writeShoppingListText(w io.Writer, list *models.ShoppingList) error {
+	for i, category := range list.Categories {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", category.Name); err != nil {
+			return err
+		}
+		for _, item := range category.Items {
+			if _, err := fmt.Fprintf(w, "- %s (%s)\n", shoppingItemLine(item), strings.Join(item.Meals, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeShoppingListMarkdown writes a checklist per category
+func writeShoppingListMarkdown(w io.Writer, list *models.ShoppingList) error {
+	for i, category := range list.Categories {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "## %s\n\n", category.Name); err != nil {
+			return err
+		}
+		for _, item := range category.Items {
+			if _, err := fmt.Fprintf(w, "- [ ] %s _(%s)_\n", shoppingItemLine(item), strings.Join(item.Meals, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeShoppingListCSV writes a header and a row per ingredient
+func writeShoppingListCSV(w io.Writer, list *models.ShoppingList) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"category", "ingredient", "quantity", "times", "meals"}); err != nil {
+		return err
+	}
+	for _, category := range list.Categories {
+		for _, item := range category.Items {
+			err := writer.Write([]string{
+				csvCell(category.Name),
+				csvCell(item.Name),
+				csvCell(shoppingQuantities(item.Quantities)),
+				strconv.Itoa(item.Times),
+				csvCell(strings.Join(item.Meals, csvListSeparator)),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// shoppingItemLine returns the name of the ingredient along with its quantities, e.g. "Tomates: 400 g"
+func shoppingItemLine(item models.ShoppingListItem) string {
+	if len(item.Quantities) == 0 {
+		return item.Name
+	}
+	return item.Name + ": " + shoppingQuantities(item.Quantities)
+}
+
+func shoppingQuantities(quantities []models.ShoppingQuantity) string {
+	parts := make([]string, 0, len(quantities))
+	for _, quantity := range quantities {
+		part := strconv.FormatFloat(quantity.Amount, 'f', -1, 64)
+		if quantity.Unit != "" {
+			part += " " + quantity.Unit
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " + ")
+}
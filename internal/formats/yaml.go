@@ -0,0 +1,52 @@
+package formats
+
+import (
+	"gopkg.in/yaml.v3"
+	"io"
+	"meals/internal/models"
+)
+
+const YAML = "yaml"
+
+func init() {
+	register(Format{
+		Name:        YAML,
+		ContentType: "application/yaml",
+		Extension:   ".yaml",
+		NewEncoder:  func(w io.Writer) Encoder { return &yamlEncoder{w: w} },
+		Decode:      decodeYAML,
+	})
+}
+
+// yamlEncoder writes the meals as a YAML sequence, one item at a time
+type yamlEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *yamlEncoder) Encode(meal *models.Meal) error {
+	// A one item sequence is a valid chunk of the whole sequence
+	content, err := yaml.Marshal([]record{toRecord(meal)})
+	if err != nil {
+		return err
+	}
+	e.count++
+	_, err = e.w.Write(content)
+	return err
+}
+
+func (e *yamlEncoder) Close() error {
+	if e.count == 0 {
+		_, err := io.WriteString(e.w, "[]\n")
+		return err
+	}
+	return nil
+}
+
+func decodeYAML(r io.Reader) ([]Row, error) {
+	var records []record
+	if err := yaml.NewDecoder(r).Decode(&records); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return recordsToRows(records), nil
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/url"
+	"net/http"
+)
+
+func (a *MealAPI) GetDietProfileHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	profile, err := a.Manager.GetDietProfile(c.Request().Context(), userID)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (a *MealAPI) PutDietProfileHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	profileFront := &models.DietProfile{}
+	if err := c.Bind(profileFront); err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	profile, err := a.Manager.PutDietProfile(c.Request().Context(), userID, *profileFront)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (a *MealAPI) DeleteDietProfileHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	if err := a.Manager.DeleteDietProfile(c.Request().Context(), userID); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/i18n"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+const dietProfileUserID = "01FN3EEB2NVFJAHAPU00000001"
+
+// putDietProfile replaces the diet profile of the user and returns it
+func (s *MealAPITestSuite) putDietProfile(profile models.DietProfile) *models.DietProfile {
+	resp, err := s.request(s.newAPI().PutDietProfileHandler, testRequest{method: http.MethodPut, target: internal.RouteDietProfile, params: []string{dietProfileUserID}, body: profile})
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	saved := new(models.DietProfile)
+	s.Require().NoError(jsoniter.Unmarshal(resp.Body.Bytes(), saved))
+	return saved
+}
+
+// externalMealsStub keeps the diet profile the recipes are searched with
+type externalMealsStub struct {
+	profile *models.DietProfile
+}
+
+func (e *externalMealsStub) ListMeals(_ context.Context, _ string, profile *models.DietProfile) ([]models.Meal, error) {
+	e.profile = profile
+	return []models.Meal{}, nil
+}
+
+func (s *MealAPITestSuite) TestDietProfileHandlers() {
+	api := s.newAPI()
+	notFound := &internal.ErrorResponse{Status: http.StatusNotFound, Code: "DIET_PROFILE_NOT_FOUND", Title: internal.ErrDietProfileNotFound.Error()}
+	wrongBody := &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "WRONG_BODY", Title: internal.ErrWrongBody.Error()}
+
+	tests := []struct {
+		name               string
+		method             string
+		reqBody            interface{}
+		handler            echo.HandlerFunc
+		expectedBody       string
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "[001] Get the profile of a user without it (ko)",
+			method:             http.MethodGet,
+			handler:            api.GetDietProfileHandler,
+			expectedResp:       notFound,
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:   "[002] Save the profile, in the order of the catalog and without repeated ingredients (ok)",
+			method: http.MethodPut,
+			reqBody: models.DietProfile{
+				ExcludedAllergens:   []string{"lacteos", "gluten", "lacteos"},
+				Diets:               []string{"vegetariana"},
+				DislikedIngredients: []string{" Cebolla", "cebolla", "Brócoli"},
+				DailyKcal:           1800,
+			},
+			handler:            api.PutDietProfileHandler,
+			expectedBody:       `{"excluded_allergens":["gluten","lacteos"],"diets":["vegetariana"],"disliked_ingredients":["Cebolla","Brócoli"],"daily_kcal":1800}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[003] Get the profile saved (ok)",
+			method:             http.MethodGet,
+			handler:            api.GetDietProfileHandler,
+			expectedBody:       `{"excluded_allergens":["gluten","lacteos"],"diets":["vegetariana"],"disliked_ingredients":["Cebolla","Brócoli"],"daily_kcal":1800}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[004] Save an empty profile (ok)",
+			method:             http.MethodPut,
+			reqBody:            models.DietProfile{},
+			handler:            api.PutDietProfileHandler,
+			expectedBody:       `{"excluded_allergens":[],"diets":[],"disliked_ingredients":[],"daily_kcal":0}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[005] Allergen not in the catalog (ko)",
+			method:             http.MethodPut,
+			reqBody:            models.DietProfile{ExcludedAllergens: []string{"marisco"}},
+			handler:            api.PutDietProfileHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[006] Diet not in the catalog (ko)",
+			method:             http.MethodPut,
+			reqBody:            models.DietProfile{Diets: []string{"carnivora"}},
+			handler:            api.PutDietProfileHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[007] Disliked ingredient with a comma (ko)",
+			method:             http.MethodPut,
+			reqBody:            models.DietProfile{DislikedIngredients: []string{"Sal, pimienta"}},
+			handler:            api.PutDietProfileHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[008] Negative kcal (ko)",
+			method:             http.MethodPut,
+			reqBody:            models.DietProfile{DailyKcal: -1},
+			handler:            api.PutDietProfileHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[009] Delete the profile (ok)",
+			method:             http.MethodDelete,
+			handler:            api.DeleteDietProfileHandler,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "[010] Delete the profile of a user without it (ko)",
+			method:             http.MethodDelete,
+			handler:            api.DeleteDietProfileHandler,
+			expectedResp:       notFound,
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(t.handler, testRequest{method: t.method, target: internal.RouteDietProfile, params: []string{dietProfileUserID}, body: t.reqBody})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			if t.expectedBody != "" {
+				s.JSONEq(t.expectedBody, resp.Body.String())
+			}
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestDietProfileApplied() {
+	api := s.newAPI()
+	external := &externalMealsStub{}
+	externalAPI := &MealAPI{DB: *s.db, Manager: api.Manager, ExternalManager: external}
+	_, err := managers.NewMealManager(*s.db).TagMeals(context.Background())
+	s.Require().NoError(err)
+	_, err = api.Manager.CreateMeal(context.Background(), dietProfileUserID, models.Meal{Name: "gazpacho", Type: "normal", Seasons: []string{"general"}, Ingredients: []string{"Tomates", "Pepino"}, Kcal: 90}, i18n.Default)
+	s.Require().NoError(err)
+	s.putDietProfile(models.DietProfile{ExcludedAllergens: []string{"lacteos"}, DislikedIngredients: []string{"Cebolla"}, DailyKcal: 1500})
+
+	names := func(expected ...string) func(resp *httptest.ResponseRecorder) {
+		return func(resp *httptest.ResponseRecorder) {
+			var meals []models.Meal
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &meals))
+			s.ElementsMatch(expected, mealNames(meals))
+		}
+	}
+	conflicts := func(expected ...string) func(resp *httptest.ResponseRecorder) {
+		return func(resp *httptest.ResponseRecorder) {
+			meal := new(models.Meal)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), meal))
+			s.NotEmpty(meal.Id)
+			s.Equal(expected, meal.ProfileConflicts)
+		}
+	}
+	searched := func(expected *models.DietProfile) func(resp *httptest.ResponseRecorder) {
+		return func(*httptest.ResponseRecorder) {
+			s.Equal(expected, external.profile)
+		}
+	}
+	dairyFree := &models.DietProfile{ExcludedAllergens: []string{"lacteos"}, Diets: []string{}, DislikedIngredients: []string{}, DailyKcal: 1500}
+
+	tests := []struct {
+		name string
+		// profile replaces the diet profile of the user before the request, when indicated
+		profile            *models.DietProfile
+		method             string
+		target             string
+		params             []string
+		headers            map[string]string
+		reqBody            interface{}
+		handler            echo.HandlerFunc
+		expectedStatusCode int
+		// check checks the response, when there is no error
+		check func(resp *httptest.ResponseRecorder)
+	}{
+		{
+			name:               "[001] The list leaves out the meals that do not fit the profile (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal,
+			params:             []string{dietProfileUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              names("gazpacho"),
+		},
+		{
+			name:               "[002] The list ignores the profile when asked (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?ignore_profile=true",
+			params:             []string{dietProfileUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              names("pizza", "ensalada", "gazpacho"),
+		},
+		{
+			name:               "[003] The list ignores the profile but not the filters sent (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?ignore_profile=true&allergen_free[]=lacteos",
+			params:             []string{dietProfileUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              names("ensalada", "gazpacho"),
+		},
+		{
+			name:               "[004] The filters sent narrow the profile (ko)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?type=ocasional",
+			params:             []string{dietProfileUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "[005] The diets of the profile are applied (ok)",
+			profile:            &models.DietProfile{Diets: []string{"vegana"}},
+			method:             http.MethodGet,
+			target:             internal.RouteMeal,
+			params:             []string{dietProfileUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              names("gazpacho"),
+		},
+		{
+			name:               "[006] A new meal that does not fit the profile is created with the conflicts (ok)",
+			profile:            &models.DietProfile{ExcludedAllergens: []string{"lacteos"}, Diets: []string{"vegana"}, DislikedIngredients: []string{"tomates"}},
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{dietProfileUserID},
+			reqBody:            models.Meal{Name: "pasta", Type: "normal", Seasons: []string{"general"}, Ingredients: []string{"Pasta de sémola", "Tomates", "Queso parmesano"}},
+			handler:            api.PostMealHandler,
+			expectedStatusCode: http.StatusCreated,
+			check: conflicts(
+				"contiene el alérgeno excluido lacteos",
+				"no es apta para la dieta vegana",
+				"lleva el ingrediente tomates, que no gusta",
+			),
+		},
+		{
+			name:               "[007] A new meal that fits the profile is created without conflicts (ok)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{dietProfileUserID},
+			reqBody:            models.Meal{Name: "macedonia", Type: "normal", Seasons: []string{"general"}, Ingredients: []string{"Manzana"}},
+			handler:            api.PostMealHandler,
+			expectedStatusCode: http.StatusCreated,
+			check:              conflicts(),
+		},
+		{
+			name:               "[008] The conflicts are in the language of the request (ok)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{dietProfileUserID},
+			headers:            map[string]string{internal.HeaderAcceptLanguage: "en"},
+			reqBody:            models.Meal{Name: "pizza margarita", Type: "normal", Seasons: []string{"general"}, Ingredients: []string{"Tomates", "Queso parmesano"}},
+			handler:            api.PostMealHandler,
+			expectedStatusCode: http.StatusCreated,
+			check: conflicts(
+				"contains the excluded allergen lacteos",
+				"is not suitable for the vegana diet",
+				"has tomates, a disliked ingredient",
+			),
+		},
+		{
+			name:               "[009] The plan only has the meals that fit the profile, with its kcal goal (ok)",
+			profile:            &models.DietProfile{ExcludedAllergens: []string{"lacteos"}, DailyKcal: 1500},
+			method:             http.MethodPost,
+			target:             internal.RoutePlanGenerate,
+			params:             []string{dietProfileUserID},
+			reqBody:            &models.MealPlanRequest{From: "2024-01-08", To: "2024-01-09", SlotsPerDay: 1, NoRepeatDays: new(int)},
+			handler:            api.GeneratePlanHandler,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				plan := new(models.MealPlan)
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), plan))
+				s.Equal(1500, plan.DailyKcal)
+				for _, day := range plan.Days {
+					s.Require().NotNil(day.Slots[0].Meal)
+					s.NotContains([]string{"pizza", "pasta"}, day.Slots[0].Meal.Name)
+				}
+			},
+		},
+		{
+			name:               "[010] The plan ignores the profile when asked (ok)",
+			method:             http.MethodPost,
+			target:             internal.RoutePlanGenerate + "?ignore_profile=true",
+			params:             []string{dietProfileUserID},
+			reqBody:            &models.MealPlanRequest{From: "2024-01-08", To: "2024-01-08"},
+			handler:            api.GeneratePlanHandler,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				plan := new(models.MealPlan)
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), plan))
+				s.Zero(plan.DailyKcal)
+			},
+		},
+		{
+			name:               "[011] The external search applies the profile of the user sent (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteExternalMeals + "?q=pollo&user_id=" + dietProfileUserID,
+			handler:            externalAPI.GetAPIMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              searched(dairyFree),
+		},
+		{
+			name:               "[012] The external search ignores the profile when asked (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteExternalMeals + "?q=pollo&user_id=" + dietProfileUserID + "&ignore_profile=true",
+			handler:            externalAPI.GetAPIMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              searched(nil),
+		},
+		{
+			name:               "[013] The external search without user has no profile (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteExternalMeals + "?q=pollo",
+			handler:            externalAPI.GetAPIMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              searched(nil),
+		},
+		{
+			name:               "[014] A disliked ingredient without accents conflicts with the one with them (ok)",
+			profile:            &models.DietProfile{DislikedIngredients: []string{"platano"}},
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{dietProfileUserID},
+			reqBody:            models.Meal{Name: "batido", Type: "normal", Seasons: []string{"general"}, Ingredients: []string{"Plátano", "Yogur natural"}},
+			handler:            api.PostMealHandler,
+			expectedStatusCode: http.StatusCreated,
+			check:              conflicts("lleva el ingrediente platano, que no gusta"),
+		},
+		{
+			name:               "[015] The list leaves out the meals with the disliked ingredients that conflict (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?" + url.Values{"ingredients_any": {"Manzana", "Plátano"}}.Encode(),
+			params:             []string{dietProfileUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check:              names("macedonia"),
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			if t.profile != nil {
+				s.putDietProfile(*t.profile)
+			}
+			resp, err := s.request(t.handler, testRequest{method: t.method, target: t.target, params: t.params, headers: t.headers, body: t.reqBody})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.check == nil {
+				return
+			}
+			s.NoError(err)
+			t.check(resp)
+		})
+	}
+}
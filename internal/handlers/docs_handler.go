@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/api"
+	"meals/pkg/openapi"
+	"net/http"
+)
+
+type DocsAPI struct {
+	Spec *openapi.Documents
+}
+
+func (a *DocsAPI) GetSpecYAMLHandler(c echo.Context) error {
+	return c.Blob(http.StatusOK, openapi.MIMEApplicationYAML, a.Spec.YAML)
+}
+
+func (a *DocsAPI) GetSpecJSONHandler(c echo.Context) error {
+	return c.JSONBlob(http.StatusOK, a.Spec.JSON)
+}
+
+func (a *DocsAPI) GetDocsHandler(c echo.Context) error {
+	return c.HTMLBlob(http.StatusOK, api.Docs)
+}
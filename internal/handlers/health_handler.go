@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"net/http"
+)
+
+type HealthAPI struct {
+	Manager managers.IHealthManager
+}
+
+// GetHealthzHandler answers while the process is alive, without checking anything else
+func (a *HealthAPI) GetHealthzHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, &models.Health{Status: models.HealthOK})
+}
+
+// GetReadyzHandler answers 503 while the database can not serve the requests
+func (a *HealthAPI) GetReadyzHandler(c echo.Context) error {
+	health := a.Manager.Ready(c.Request().Context())
+	if health.Status != models.HealthOK {
+		return c.JSON(http.StatusServiceUnavailable, health)
+	}
+	return c.JSON(http.StatusOK, health)
+}
+
+// GetStatusHandler reports the reachability and latency of the database and the dependencies. The service
+// keeps serving while a dependency is down, so the status is always 200
+func (a *HealthAPI) GetStatusHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, a.Manager.Status(c.Request().Context()))
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"meals/pkg/database"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *MealAPITestSuite) TestHealthHandlers() {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer up.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	down.Close()
+
+	tests := []struct {
+		name               string
+		db                 *database.Database
+		dependencies       []managers.Dependency
+		route              string
+		expectedStatus     string
+		expectedChecks     map[string]string
+		expectedStatusCode int
+	}{
+		{
+			name:               "[001] Alive (ok)",
+			db:                 &database.Database{},
+			route:              internal.RouteHealthz,
+			expectedStatus:     models.HealthOK,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[002] Ready (ok)",
+			route:              internal.RouteReadyz,
+			expectedStatus:     models.HealthOK,
+			expectedChecks:     map[string]string{"database": models.HealthUp},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[003] Not ready without database (503)",
+			db:                 &database.Database{},
+			route:              internal.RouteReadyz,
+			expectedStatus:     models.HealthDegraded,
+			expectedChecks:     map[string]string{"database": models.HealthDown},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:               "[004] Status with every dependency up (ok)",
+			dependencies:       []managers.Dependency{{Name: managers.DependencyCalendars, URL: up.URL}},
+			route:              internal.RouteStatus,
+			expectedStatus:     models.HealthOK,
+			expectedChecks:     map[string]string{"database": models.HealthUp, managers.DependencyCalendars: models.HealthUp},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "[005] Status with dependencies down (ok)",
+			dependencies: []managers.Dependency{
+				{Name: managers.DependencyCalendars, URL: up.URL},
+				{Name: managers.DependencyUsers, URL: down.URL},
+				{Name: managers.DependencyRecipes, URL: failing.URL},
+			},
+			route:          internal.RouteStatus,
+			expectedStatus: models.HealthDegraded,
+			expectedChecks: map[string]string{
+				"database":                   models.HealthUp,
+				managers.DependencyCalendars: models.HealthUp,
+				managers.DependencyUsers:     models.HealthDown,
+				managers.DependencyRecipes:   models.HealthDown,
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			db := t.db
+			if db == nil {
+				db = s.db
+			}
+			api := HealthAPI{Manager: managers.NewHealthManager(db, t.dependencies)}
+			handler := map[string]echo.HandlerFunc{
+				internal.RouteHealthz: api.GetHealthzHandler,
+				internal.RouteReadyz:  api.GetReadyzHandler,
+				internal.RouteStatus:  api.GetStatusHandler,
+			}[t.route]
+			req := httptest.NewRequest(http.MethodGet, t.route, nil)
+			resp := httptest.NewRecorder()
+
+			s.NoError(handler(echo.New().NewContext(req, resp)))
+			s.Equal(t.expectedStatusCode, resp.Code)
+			health := new(models.Health)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), health))
+			s.Equal(t.expectedStatus, health.Status)
+			s.Len(health.Checks, len(t.expectedChecks))
+			for name, status := range t.expectedChecks {
+				s.Equal(status, health.Checks[name].Status, name)
+			}
+			if check, ok := health.Checks["database"]; ok && check.Status == models.HealthUp {
+				s.Positive(check.Version)
+			}
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestInitDBFails() {
+	db, err := database.InitDB("/missing/dir/amc_test.db")
+	s.Error(err)
+	s.Nil(db)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"meals/internal"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *MealAPITestSuite) TestBatchMealsHandler() {
+	newMeal := func(name string) *models.Meal {
+		return &models.Meal{
+			Name:        name,
+			Type:        "normal",
+			Ingredients: []string{"Lechuga", "Pepino"},
+			Seasons:     []string{"general"},
+		}
+	}
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000005", "01FN3EEB2NVFJAHAPU00000002", "tortilla", "", "", "normal", "Huevo entero|Patata", 250, "general")
+	tests := []struct {
+		name               string
+		userID             string
+		reqBody            interface{}
+		calendarErr        error
+		expectedStatuses   []int
+		expectedWarnings   []string
+		expectedCommitted  bool
+		expectedMeals      int
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:   "[001] Atomic batch with a failed operation is rolled back (207)",
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			reqBody: &models.MealBatch{
+				Operations: []models.MealBatchOperation{
+					{Op: models.BatchOpCreate, Meal: newMeal("gazpacho")},
+					{Op: models.BatchOpCreate, Meal: newMeal("Pizza")},
+				},
+			},
+			expectedStatuses:   []int{http.StatusFailedDependency, http.StatusConflict},
+			expectedCommitted:  false,
+			expectedMeals:      2,
+			expectedStatusCode: http.StatusMultiStatus,
+		},
+		{
+			name:   "[002] Best effort batch keeps the operations that succeeded (207)",
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			reqBody: &models.MealBatch{
+				Mode: models.BatchModeBestEffort,
+				Operations: []models.MealBatchOperation{
+					{Op: models.BatchOpCreate, Meal: newMeal("gazpacho")},
+					{Op: models.BatchOpCreate, Meal: newMeal("gazpacho")},
+					{Op: models.BatchOpDelete, Id: "01FN3EEB2NVFJAHAPM00000002"},
+				},
+			},
+			expectedStatuses:   []int{http.StatusCreated, http.StatusConflict, http.StatusPreconditionRequired},
+			expectedCommitted:  true,
+			expectedMeals:      3,
+			expectedStatusCode: http.StatusMultiStatus,
+		},
+		{
+			name:   "[003] Atomic batch of create, update and delete (ok)",
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			reqBody: &models.MealBatch{
+				Mode: models.BatchModeAtomic,
+				Operations: []models.MealBatchOperation{
+					{Op: models.BatchOpCreate, Meal: newMeal("salmorejo")},
+					{Op: models.BatchOpUpdate, Id: "01FN3EEB2NVFJAHAPM00000001", IfMatch: `"1"`, Meal: newMeal("pizza vegetal")},
+					{Op: models.BatchOpDelete, Id: "01FN3EEB2NVFJAHAPM00000002", IfMatch: `"1"`},
+				},
+			},
+			expectedStatuses:   []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+			expectedCommitted:  true,
+			expectedMeals:      3,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[004] Batch without operations (400)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			reqBody:            &models.MealBatch{Mode: models.BatchModeAtomic},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:   "[005] Calendar not updated after the commit is a warning of an operation that succeeded (ok)",
+			userID: "01FN3EEB2NVFJAHAPU00000002",
+			reqBody: &models.MealBatch{
+				Operations: []models.MealBatchOperation{
+					{Op: models.BatchOpCreate, Meal: newMeal("salmorejo")},
+					{Op: models.BatchOpDelete, Id: "01FN3EEB2NVFJAHAPM00000005", IfMatch: `"1"`},
+				},
+			},
+			calendarErr:        internal.ErrorWithExternalAPI,
+			expectedStatuses:   []int{http.StatusCreated, http.StatusNoContent},
+			expectedWarnings:   []string{"", "EXTERNAL_API_ERROR"},
+			expectedCommitted:  true,
+			expectedMeals:      1,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	getEchoContext := func(userId string, request interface{}) echo.Context {
+		body, err := jsoniter.Marshal(request)
+		s.NoError(err)
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, internal.RouteMealBatch, bytes.NewBuffer(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames(internal.ParamUserID)
+		c.SetParamValues(userId)
+		return c
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			mealManager := managers.NewMealManager(*s.db)
+			api := MealAPI{DB: *s.db, Manager: mealManager}
+			s.httpMock.On("GetCalendar", mock.Anything, t.userID, mock.Anything, mock.Anything).Return(t.calendarErr)
+
+			c := getEchoContext(t.userID, t.reqBody)
+			err := api.BatchMealsHandler(c)
+
+			resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
+			s.True(ok)
+			if t.wantErr {
+				s.Equal(t.wantErr, err != nil)
+			} else {
+				s.NoError(err)
+				result := new(models.MealBatchResult)
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), result))
+				s.Equal(t.expectedCommitted, result.Committed)
+				var statuses []int
+				var warnings []string
+				for _, item := range result.Results {
+					statuses = append(statuses, item.Status)
+					warning := ""
+					if item.Warning != nil {
+						warning = item.Warning.Code
+					}
+					warnings = append(warnings, warning)
+				}
+				s.Equal(t.expectedStatuses, statuses)
+				if t.expectedWarnings != nil {
+					s.Equal(t.expectedWarnings, warnings)
+				}
+
+				meals, err := mealManager.ListMeals(context.Background(), t.userID, &models.MealsFilters{})
+				s.NoError(err)
+				s.Len(meals, t.expectedMeals)
+			}
+
+			s.Equal(t.expectedStatusCode, c.Response().Status)
+		})
+	}
+}
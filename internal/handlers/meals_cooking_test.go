@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/formats"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+)
+
+const cookingUserID = "01FN3EEB2NVFJAHAPU00000001"
+
+// lasagna returns a meal with all the cooking details
+func lasagna() models.Meal {
+	return models.Meal{
+		Name:        "lasaña",
+		Type:        "normal",
+		Seasons:     []string{"general"},
+		Ingredients: []string{"Pasta de sémola", "Tomates"},
+		Steps:       []models.Step{{Text: " Cocer la pasta ", TimerMinutes: 10}, {Text: "Gratinar", TimerMinutes: 15}},
+		PrepTime:    20,
+		CookTime:    40,
+		Difficulty:  "media",
+		Equipment:   []string{"Horno", "horno", " Fuente "},
+	}
+}
+
+// cookingMeal returns a copy of the lasagna with the change indicated and another name, not to be repeated
+func cookingMeal(change func(meal *models.Meal)) models.Meal {
+	meal := lasagna()
+	meal.Name = "lasaña de verduras"
+	change(&meal)
+	return meal
+}
+
+func (s *MealAPITestSuite) TestMealCooking() {
+	api := s.newAPI()
+	created, err := api.Manager.CreateMeal(context.Background(), cookingUserID, lasagna(), i18n.Default)
+	s.Require().NoError(err)
+	update := lasagna()
+	update.Steps = []models.Step{{Text: "Hornear", TimerMinutes: 30}}
+	wrongBody := &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "WRONG_BODY", Title: internal.ErrWrongBody.Error()}
+
+	meal := func(check func(meal *models.Meal)) func(resp *httptest.ResponseRecorder) {
+		return func(resp *httptest.ResponseRecorder) {
+			meal := new(models.Meal)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), meal))
+			check(meal)
+		}
+	}
+
+	tests := []struct {
+		name               string
+		method             string
+		target             string
+		params             []string
+		headers            map[string]string
+		reqBody            interface{}
+		handler            echo.HandlerFunc
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+		// check checks the response, when there is no error
+		check func(resp *httptest.ResponseRecorder)
+	}{
+		{
+			name:               "[001] The steps are kept in order along with the times, difficulty and equipment (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMealID,
+			params:             []string{cookingUserID, created.Id},
+			handler:            api.GetMealHandler,
+			expectedStatusCode: http.StatusOK,
+			check: meal(func(meal *models.Meal) {
+				s.Equal([]models.Step{{Text: "Cocer la pasta", TimerMinutes: 10}, {Text: "Gratinar", TimerMinutes: 15}}, meal.Steps)
+				s.Equal(20, meal.PrepTime)
+				s.Equal(40, meal.CookTime)
+				s.Equal(60, meal.TotalTime)
+				s.Equal("media", meal.Difficulty)
+				s.Equal([]string{"Horno", "Fuente"}, meal.Equipment)
+			}),
+		},
+		{
+			name:               "[002] A meal without steps nor equipment has them empty (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMealID,
+			params:             []string{cookingUserID, "01FN3EEB2NVFJAHAPM00000001"},
+			handler:            api.GetMealHandler,
+			expectedStatusCode: http.StatusOK,
+			check: meal(func(meal *models.Meal) {
+				s.Equal([]models.Step{}, meal.Steps)
+				s.Equal([]string{}, meal.Equipment)
+			}),
+		},
+		{
+			name:               "[003] The steps are replaced on update (ok)",
+			method:             http.MethodPut,
+			target:             internal.RouteMealID,
+			params:             []string{cookingUserID, created.Id},
+			headers:            map[string]string{internal.HeaderIfMatch: `"1"`},
+			reqBody:            update,
+			handler:            api.PutMealHandler,
+			expectedStatusCode: http.StatusOK,
+			check: meal(func(meal *models.Meal) {
+				s.Equal([]models.Step{{Text: "Hornear", TimerMinutes: 30}}, meal.Steps)
+			}),
+		},
+		{
+			name:               "[004] Difficulty not valid (ko)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{cookingUserID},
+			reqBody:            cookingMeal(func(meal *models.Meal) { meal.Difficulty = "imposible" }),
+			handler:            api.PostMealHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[005] Step without text (ko)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{cookingUserID},
+			reqBody:            cookingMeal(func(meal *models.Meal) { meal.Steps = []models.Step{{Text: "  "}} }),
+			handler:            api.PostMealHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[006] Step timer longer than the total time (ko)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{cookingUserID},
+			reqBody:            cookingMeal(func(meal *models.Meal) { meal.Steps = []models.Step{{Text: "Reposar", TimerMinutes: 90}} }),
+			handler:            api.PostMealHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[007] Negative time (ko)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{cookingUserID},
+			reqBody:            cookingMeal(func(meal *models.Meal) { meal.PrepTime = -1 }),
+			handler:            api.PostMealHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[008] Equipment with a comma (ko)",
+			method:             http.MethodPost,
+			target:             internal.RouteMeal,
+			params:             []string{cookingUserID},
+			reqBody:            cookingMeal(func(meal *models.Meal) { meal.Equipment = []string{"Sartén, grande"} }),
+			handler:            api.PostMealHandler,
+			expectedResp:       wrongBody,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[009] Filter by the max total time, leaving out the meals without times (ok)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?max_total_time=60",
+			params:             []string{cookingUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				var meals []models.Meal
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &meals))
+				s.Equal([]string{"lasaña"}, mealNames(meals))
+			},
+		},
+		{
+			name:               "[010] No meal under the max total time (ko)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?max_total_time=59",
+			params:             []string{cookingUserID},
+			handler:            api.ListMealsHandler,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "[011] Negative max total time (ko)",
+			method:             http.MethodGet,
+			target:             internal.RouteMeal + "?max_total_time=-1",
+			params:             []string{cookingUserID},
+			handler:            api.ListMealsHandler,
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "REQUEST_NOT_VALID", Title: internal.ErrRequestNotValid.Error()},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(t.handler, testRequest{method: t.method, target: t.target, params: t.params, headers: t.headers, body: t.reqBody})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			if t.check != nil {
+				s.NoError(err)
+				t.check(resp)
+			}
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestMealCookingExports() {
+	api := s.newAPI()
+	_, err := api.Manager.CreateMeal(context.Background(), cookingUserID, lasagna(), i18n.Default)
+	s.Require().NoError(err)
+	name := lasagna().Name
+	// The steps may have the separator of the lists of the CSV
+	shake := cookingMeal(func(meal *models.Meal) {
+		meal.Name = "batido"
+		meal.Steps = []models.Step{{Text: `Mezclar | batir \ servir`}, {Text: "Enfriar"}}
+	})
+	_, err = api.Manager.CreateMeal(context.Background(), cookingUserID, shake, i18n.Default)
+	s.Require().NoError(err)
+
+	tests := []struct {
+		name   string
+		format string
+		// keepsDifficulty tells if the format has a property for the difficulty
+		keepsDifficulty bool
+	}{
+		{name: "[001] JSON (ok)", format: formats.JSON, keepsDifficulty: true},
+		{name: "[002] YAML (ok)", format: formats.YAML, keepsDifficulty: true},
+		{name: "[003] CSV (ok)", format: formats.CSV, keepsDifficulty: true},
+		{name: "[004] JSON-LD, schema.org has no property for the difficulty (ok)", format: formats.JSONLD},
+		{name: "[005] Paprika (ok)", format: formats.PAPRIKA, keepsDifficulty: true},
+	}
+	for i, t := range tests {
+		s.Run(t.name, func() {
+			format, _ := formats.Get(t.format)
+			importer := "01FN3EEB2NVFJAHAPU0000002" + strconv.Itoa(i)
+
+			exported, err := s.request(api.ExportMealsHandler, testRequest{method: http.MethodGet, target: internal.RouteMealExport + "?format=" + t.format, params: []string{cookingUserID}})
+			s.Require().NoError(err)
+			_, err = s.request(api.ImportMealsHandler, testRequest{
+				method:  http.MethodPost,
+				target:  internal.RouteMealImport + "?format=" + t.format,
+				params:  []string{importer},
+				headers: map[string]string{echo.HeaderContentType: format.ContentType},
+				body:    exported.Body.Bytes(),
+			})
+			s.Require().NoError(err)
+
+			meals, err := api.Manager.ListMeals(context.Background(), importer, &models.MealsFilters{Name: &name})
+			s.Require().NoError(err)
+			s.Equal([]models.Step{{Text: "Cocer la pasta", TimerMinutes: 10}, {Text: "Gratinar", TimerMinutes: 15}}, meals[0].Steps)
+			s.Equal(60, meals[0].TotalTime)
+			s.Equal([]string{"Horno", "Fuente"}, meals[0].Equipment)
+
+			shakes, err := api.Manager.ListMeals(context.Background(), importer, &models.MealsFilters{Name: &shake.Name})
+			s.Require().NoError(err)
+			s.Equal(shake.Steps, shakes[0].Steps)
+			if t.keepsDifficulty {
+				s.Equal("media", meals[0].Difficulty)
+			} else {
+				s.Empty(meals[0].Difficulty)
+			}
+		})
+	}
+}
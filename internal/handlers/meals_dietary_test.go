@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"github.com/json-iterator/go"
+	"meals/internal"
+	"meals/internal/i18n"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"net/http"
+	"net/url"
+)
+
+func (s *MealAPITestSuite) TestDietaryTags() {
+	const userID = "01FN3EEB2NVFJAHAPU00000001"
+	tests := []struct {
+		name              string
+		reqBody           models.Meal
+		expectedAllergens []string
+		expectedDiets     []string
+	}{
+		{
+			name:              "[001] Tags of a vegan meal (ok)",
+			reqBody:           models.Meal{Name: "Gazpacho", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Tomates", "Pepino", "Pimiento"}},
+			expectedAllergens: []string{},
+			expectedDiets:     []string{"vegetariana", "vegana", "pescetariana"},
+		},
+		{
+			name:              "[002] Allergens of every ingredient (ok)",
+			reqBody:           models.Meal{Name: "Tostada", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Pan de trigo blanco", "Mantequilla de cacahuete"}},
+			expectedAllergens: []string{"gluten", "cacahuetes", "lacteos"},
+			expectedDiets:     []string{},
+		},
+		{
+			name:              "[003] Diets that allow every ingredient (ok)",
+			reqBody:           models.Meal{Name: "Salmón al horno", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Salmón", "Huevo entero"}},
+			expectedAllergens: []string{"huevos", "pescado"},
+			expectedDiets:     []string{"pescetariana"},
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(s.newAPI().PostMealHandler, testRequest{method: http.MethodPost, target: internal.RouteMeal, params: []string{userID}, body: t.reqBody})
+			s.NoError(err)
+			s.Equal(http.StatusCreated, resp.Code, resp.Body.String())
+			meal := new(models.Meal)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), meal))
+			s.Equal(t.expectedAllergens, meal.Allergens)
+			s.Equal(t.expectedDiets, meal.Diets)
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestDietaryFilters() {
+	const userID = "01FN3EEB2NVFJAHAPU00000001"
+	manager := managers.NewMealManager(*s.db)
+	for _, meal := range []models.Meal{
+		{Name: "Gazpacho", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Tomates", "Pepino", "Pimiento"}},
+		{Name: "Tostada", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Pan de trigo blanco", "Mantequilla de cacahuete"}},
+		{Name: "Salmón al horno", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Salmón", "Huevo entero"}},
+	} {
+		_, err := manager.CreateMeal(context.Background(), userID, meal, i18n.Default)
+		s.Require().NoError(err)
+	}
+	notValid := &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "REQUEST_NOT_VALID", Title: internal.ErrRequestNotValid.Error()}
+
+	tests := []struct {
+		name               string
+		query              url.Values
+		expectedNames      []string
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "[001] Free of the allergens, leaving out the meals not tagged (ok)",
+			query:              url.Values{"allergen_free[]": {"gluten", "lacteos"}},
+			expectedNames:      []string{"Gazpacho", "Salmón al horno"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[002] Free of the allergens and suitable for the diet (ok)",
+			query:              url.Values{"allergen_free[]": {"huevos"}, "diet": {"pescetariana"}},
+			expectedNames:      []string{"Gazpacho"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[003] Suitable for the diet (ok)",
+			query:              url.Values{"diet": {"vegetariana"}},
+			expectedNames:      []string{"Gazpacho"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[004] Allergen not in the catalog (ko)",
+			query:              url.Values{"allergen_free[]": {"marisco"}},
+			expectedResp:       notValid,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[005] Diet not in the catalog (ko)",
+			query:              url.Values{"diet": {"carnivora"}},
+			expectedResp:       notValid,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(s.newAPI().ListMealsHandler, testRequest{method: http.MethodGet, target: internal.RouteMeal + "?" + t.query.Encode(), params: []string{userID}})
+			s.Equal(t.expectedStatusCode, resp.Code)
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			var meals []models.Meal
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &meals))
+			s.ElementsMatch(t.expectedNames, mealNames(meals))
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestTagMeals() {
+	const userID = "01FN3EEB2NVFJAHAPU00000001"
+	manager := managers.NewMealManager(*s.db)
+
+	s.Run("[001] The meals not tagged are tagged once (ok)", func() {
+		tagged, err := manager.TagMeals(context.Background())
+		s.NoError(err)
+		s.Equal(2, tagged)
+
+		pizza, err := manager.GetMeal(context.Background(), userID, "01FN3EEB2NVFJAHAPM00000001")
+		s.Require().NoError(err)
+		s.Equal([]string{"lacteos"}, pizza.Allergens)
+		s.Equal([]string{}, pizza.Diets)
+		s.Equal(2, pizza.Version, "the tags change the representation, so its ETag")
+
+		salad, err := manager.GetMeal(context.Background(), userID, "01FN3EEB2NVFJAHAPM00000002")
+		s.Require().NoError(err)
+		s.Equal([]string{}, salad.Allergens)
+		s.Equal([]string{}, salad.Diets, "Aguacate is not in the catalog")
+
+		tagged, err = manager.TagMeals(context.Background())
+		s.NoError(err)
+		s.Zero(tagged)
+	})
+
+	s.Run("[002] A meal updated while it was tagged keeps the tags of the update (ok)", func() {
+		s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000009", userID, "Tortilla", "", "", "semanal", "Patata|Cebolla", 200, "general")
+		repo := repositories.NewSQLiteMealRepository(s.db)
+		untagged, err := repo.UntaggedMeals(context.Background())
+		s.Require().NoError(err)
+		s.Require().Len(untagged, 1)
+
+		_, err = manager.UpdateMeal(context.Background(), userID, "01FN3EEB2NVFJAHAPM00000009", models.Meal{Name: "Tortilla", Type: "semanal", Seasons: []string{"general"}, Ingredients: []string{"Patata", "Huevo entero"}}, `"1"`)
+		s.Require().NoError(err)
+
+		tagged, err := repo.TagMeal(context.Background(), *untagged[0])
+		s.NoError(err)
+		s.False(tagged)
+
+		meal, err := manager.GetMeal(context.Background(), userID, "01FN3EEB2NVFJAHAPM00000009")
+		s.Require().NoError(err)
+		s.Equal([]string{"huevos"}, meal.Allergens)
+	})
+}
+
+func (s *MealAPITestSuite) TestDietaryCatalog() {
+	resp, err := s.request(s.newAPI().GetCatalogHandler, testRequest{
+		method:  http.MethodGet,
+		target:  internal.RouteCatalog,
+		headers: map[string]string{internal.HeaderAcceptLanguage: "en"},
+	})
+	s.NoError(err)
+	catalog := new(models.Catalog)
+	s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), catalog))
+	s.Len(catalog.Allergens, len(models.MealAllergens))
+	s.Contains(catalog.Allergens, models.CatalogEntry{Key: "huevos", Name: "Eggs"})
+	s.Contains(catalog.Diets, models.CatalogEntry{Key: "vegana", Name: "Vegan"})
+
+	for _, category := range catalog.Categories {
+		for _, ingredient := range category.Ingredients {
+			switch ingredient.Key {
+			case "Mayonesa":
+				s.Equal([]string{"huevos"}, ingredient.Allergens)
+				s.Equal([]string{"vegetariana", "pescetariana"}, ingredient.Diets)
+			case "Lechuga":
+				s.Equal([]string{}, ingredient.Allergens)
+				s.Equal([]string{"vegetariana", "vegana", "pescetariana"}, ingredient.Diets)
+			}
+		}
+	}
+}
@@ -2,12 +2,18 @@ package handlers
 
 import (
 	"github.com/labstack/echo/v4"
+	"io"
 	"meals/internal"
+	"meals/internal/formats"
 	"meals/internal/managers"
 	"meals/internal/models"
 	"meals/pkg/database"
+	"meals/pkg/etag"
 	"meals/pkg/url"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type MealAPI struct {
@@ -24,12 +30,36 @@ func (a *MealAPI) GetMealHandler(c echo.Context) error {
 	}); err != nil {
 		return internal.NewErrorResponse(c, err)
 	}
+	query := &models.MealQuery{}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, query); err != nil {
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+	}
 
-	meal, err := a.Manager.GetMeal(userID, mealID)
+	meal, err := a.Manager.GetMeal(c.Request().Context(), userID, mealID)
 	if err != nil {
 		return internal.NewErrorResponse(c, err)
 	}
+	if query.Servings != nil {
+		if err = managers.ScaleMeal(meal, *query.Servings); err != nil {
+			return internal.NewErrorResponse(c, err)
+		}
+	}
+	// Every representation has its own entity tag
+	mediaType := negotiate(c.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON, internal.MIMEApplicationLDJSON)
+	tag := etag.Version(meal.Version)
+	if mediaType == internal.MIMEApplicationLDJSON {
+		tag = etag.Variant(tag, "jsonld")
+	}
+	c.Response().Header().Set(internal.HeaderETag, tag)
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if etag.Match(c.Request().Header.Get(internal.HeaderIfNoneMatch), tag, true) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	cleanMeal(meal)
+	if mediaType == internal.MIMEApplicationLDJSON {
+		c.Response().Header().Set(echo.HeaderContentType, internal.MIMEApplicationLDJSON)
+		return c.JSON(http.StatusOK, formats.ToRecipe(meal))
+	}
 	return c.JSON(http.StatusOK, meal)
 }
 
@@ -44,16 +74,21 @@ func (a *MealAPI) ListMealsHandler(c echo.Context) error {
 
 	filters := &models.MealsFilters{}
 	if err := (&echo.DefaultBinder{}).BindQueryParams(c, filters); err != nil {
-		return internal.NewErrorResponse(c, err)
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
 	}
 
-	allMeals, err := a.Manager.ListMeals(userID, filters)
+	allMeals, err := a.Manager.ListMeals(c.Request().Context(), userID, filters)
 	if err != nil {
 		return internal.NewErrorResponse(c, err)
 	}
 	if allMeals == nil {
 		allMeals = []*models.Meal{}
 	}
+	tag := listETag(allMeals)
+	c.Response().Header().Set(internal.HeaderETag, tag)
+	if etag.Match(c.Request().Header.Get(internal.HeaderIfNoneMatch), tag, true) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	for _, meal := range allMeals {
 		cleanMeal(meal)
 	}
@@ -72,7 +107,7 @@ func (a *MealAPI) PostMealHandler(c echo.Context) error {
 	if err := c.Bind(mealFront); err != nil {
 		return internal.NewErrorResponse(c, internal.ErrWrongBody)
 	}
-	meal, err := a.Manager.CreateMeal(userID, *mealFront)
+	meal, err := a.Manager.CreateMeal(c.Request().Context(), userID, *mealFront, internal.Language(c))
 	if err != nil {
 		return internal.NewErrorResponse(c, err)
 	}
@@ -90,21 +125,61 @@ func (a *MealAPI) PutMealHandler(c echo.Context) error {
 		return internal.NewErrorResponse(c, err)
 	}
 
+	ifMatch := c.Request().Header.Get(internal.HeaderIfMatch)
+	if ifMatch == "" {
+		return internal.NewErrorResponse(c, internal.ErrIfMatchNotPresent)
+	}
+
 	mealFront := &models.Meal{}
 	if err := c.Bind(mealFront); err != nil {
 		return internal.NewErrorResponse(c, internal.ErrWrongBody)
 	}
 	mealFront.Id = mealID
-	meal, err := a.Manager.UpdateMeal(userID, mealID, *mealFront)
+	meal, err := a.Manager.UpdateMeal(c.Request().Context(), userID, mealID, *mealFront, ifMatch)
 	if err != nil {
 		return internal.NewErrorResponse(c, err)
 
 	}
+	c.Response().Header().Set(internal.HeaderETag, etag.Version(meal.Version))
 	cleanMeal(meal)
 	return c.JSON(http.StatusOK, meal)
 
 }
 
+func (a *MealAPI) PatchMealHandler(c echo.Context) error {
+	var userID, mealID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+		internal.ParamMealID: {Target: &mealID, Err: internal.ErrMealIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	ifMatch := c.Request().Header.Get(internal.HeaderIfMatch)
+	if ifMatch == "" {
+		return internal.NewErrorResponse(c, internal.ErrIfMatchNotPresent)
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil || len(body) == 0 {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	contentType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if contentType == echo.MIMEApplicationJSON {
+		// A plain JSON object is handled as a merge patch
+		contentType = models.MIMEMergePatch
+	}
+
+	patch := models.MealPatch{ContentType: contentType, Body: body}
+	meal, err := a.Manager.PatchMeal(c.Request().Context(), userID, mealID, patch, ifMatch)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	c.Response().Header().Set(internal.HeaderETag, etag.Version(meal.Version))
+	cleanMeal(meal)
+	return c.JSON(http.StatusOK, meal)
+}
+
 func (a *MealAPI) DeleteMealHandler(c echo.Context) error {
 	var userID, mealID string
 	if err := url.ParseURLPath(c, url.PathMap{
@@ -114,7 +189,12 @@ func (a *MealAPI) DeleteMealHandler(c echo.Context) error {
 		return internal.NewErrorResponse(c, err)
 	}
 
-	err := a.Manager.DeleteMeal(userID, mealID)
+	ifMatch := c.Request().Header.Get(internal.HeaderIfMatch)
+	if ifMatch == "" {
+		return internal.NewErrorResponse(c, internal.ErrIfMatchNotPresent)
+	}
+
+	err := a.Manager.DeleteMeal(c.Request().Context(), userID, mealID, ifMatch)
 	if err != nil {
 		return internal.NewErrorResponse(c, err)
 	}
@@ -122,17 +202,60 @@ func (a *MealAPI) DeleteMealHandler(c echo.Context) error {
 	return c.JSON(http.StatusNoContent, nil)
 }
 
+func (a *MealAPI) BatchMealsHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	batch := &models.MealBatch{}
+	if err := c.Bind(batch); err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	result, err := a.Manager.BatchMeals(c.Request().Context(), userID, *batch)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	status := http.StatusOK
+	for i := range result.Results {
+		item := &result.Results[i]
+		if item.Meal != nil {
+			cleanMeal(item.Meal)
+		}
+		if item.Failed() {
+			item.Error = internal.LocalizeItemError(c, item.Error)
+			status = http.StatusMultiStatus
+		}
+		item.Warning = internal.LocalizeItemError(c, item.Warning)
+	}
+	return c.JSON(status, result)
+}
+
 func (a *MealAPI) GetIngredients(c echo.Context) error {
 	return c.JSON(http.StatusOK, models.Ingredients)
 }
 
+func (a *MealAPI) GetCatalogHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, managers.Catalog(internal.Language(c)))
+}
+
 func (a *MealAPI) GetAPIMealsHandler(c echo.Context) error {
 	filters := &models.ExternalMealFilter{}
 	if err := (&echo.DefaultBinder{}).BindQueryParams(c, filters); err != nil {
-		return internal.NewErrorResponse(c, err)
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
 	}
 
-	meals, err := a.ExternalManager.ListMeals(filters.Q)
+	var profile *models.DietProfile
+	if filters.UserId != "" {
+		var err error
+		if profile, err = a.Manager.ActiveDietProfile(c.Request().Context(), filters.UserId, filters.ProfileOverride); err != nil {
+			return internal.NewErrorResponse(c, err)
+		}
+	}
+	meals, err := a.ExternalManager.ListMeals(c.Request().Context(), filters.Q, profile)
 	if err != nil {
 		return internal.NewErrorResponse(c, internal.ErrorWithExternalAPI)
 	}
@@ -142,3 +265,57 @@ func (a *MealAPI) GetAPIMealsHandler(c echo.Context) error {
 func cleanMeal(meal *models.Meal) {
 	meal.UserId = "" // Remove userID to prevent it from being serialized to JSON
 }
+
+// listETag builds the entity tag of a list of meals from the id and version of every meal
+func listETag(meals []*models.Meal) string {
+	parts := make([]string, 0, len(meals))
+	for _, meal := range meals {
+		parts = append(parts, meal.Id+":"+strconv.Itoa(meal.Version))
+	}
+	return etag.Weak(parts...)
+}
+
+// negotiate returns the media type offered that the Accept header prefers, by its quality and then by the
+// order of the offers. The first offer is returned when the header accepts none of them
+func negotiate(accept string, offers ...string) string {
+	best, bestQuality := offers[0], 0.0
+	for _, offer := range offers {
+		if quality := acceptQuality(accept, offer); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality the Accept header gives to the media type, the one of its most specific
+// range. Every media type is accepted when there is no header
+func acceptQuality(accept, mediaType string) float64 {
+	if strings.TrimSpace(accept) == "" {
+		return 1
+	}
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	quality, specificity := 0.0, -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		name, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		rangeSpecificity := -1
+		switch name {
+		case mediaType:
+			rangeSpecificity = 2
+		case mainType + "/*":
+			rangeSpecificity = 1
+		case "*/*":
+			rangeSpecificity = 0
+		}
+		if rangeSpecificity <= specificity {
+			continue
+		}
+		specificity, quality = rangeSpecificity, 1
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+	}
+	return quality
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *MealAPITestSuite) TestLocalizedErrors() {
+	tests := []struct {
+		name               string
+		acceptLanguage     string
+		mealID             string
+		reqBody            interface{}
+		expectedLanguage   string
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+	}{
+		{
+			name:             "[001] Error in Spanish when no language is asked (404)",
+			mealID:           "01FN3EEB2NVFJAHAPM00000099",
+			expectedLanguage: "es",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  "comida no encontrada",
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:             "[002] Error in English (404)",
+			acceptLanguage:   "en-GB,en;q=0.9,es;q=0.8",
+			mealID:           "01FN3EEB2NVFJAHAPM00000099",
+			expectedLanguage: "en",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  "meal not found",
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:             "[003] Error in the preferred supported language (404)",
+			acceptLanguage:   "fr-FR,fr;q=0.9,es;q=0.5,en;q=0.3",
+			mealID:           "01FN3EEB2NVFJAHAPM00000099",
+			expectedLanguage: "es",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  "comida no encontrada",
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:             "[004] Error in Spanish when no language is supported (404)",
+			acceptLanguage:   "de",
+			mealID:           "01FN3EEB2NVFJAHAPM00000099",
+			expectedLanguage: "es",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  "comida no encontrada",
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "[005] Fields that did not pass the validation in English, with the canonical keys (400)",
+			acceptLanguage: "en",
+			mealID:         "01FN3EEB2NVFJAHAPM00000001",
+			reqBody: &models.Meal{
+				Type:        "diario",
+				Ingredients: []string{"Tomate"},
+				Seasons:     []string{"invierno"},
+			},
+			expectedLanguage: "en",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  "the body sent is not valid",
+				Errors: []models.FieldError{
+					{Field: "name", Code: "required", Message: "is required"},
+					{Field: "type", Code: "oneof", Message: "must be one of: semanal, ocasional, normal"},
+				},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			api := MealAPI{DB: *s.db, Manager: managers.NewMealManager(*s.db)}
+
+			method, handler := http.MethodGet, api.GetMealHandler
+			var body []byte
+			if t.reqBody != nil {
+				method, handler = http.MethodPut, api.PutMealHandler
+				body, _ = jsoniter.Marshal(t.reqBody)
+			}
+			req := httptest.NewRequest(method, internal.RouteMealID, bytes.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(internal.HeaderIfMatch, `"1"`)
+			if t.acceptLanguage != "" {
+				req.Header.Set(internal.HeaderAcceptLanguage, t.acceptLanguage)
+			}
+			resp := httptest.NewRecorder()
+			c := echo.New().NewContext(req, resp)
+			c.SetParamNames(internal.ParamUserID, internal.ParamMealID)
+			c.SetParamValues("01FN3EEB2NVFJAHAPU00000001", t.mealID)
+
+			s.Error(handler(c))
+			s.Equal(t.expectedStatusCode, resp.Code)
+			s.Equal(t.expectedLanguage, resp.Header().Get(internal.HeaderContentLanguage))
+			s.Contains(resp.Header().Values(echo.HeaderVary), internal.HeaderAcceptLanguage)
+			s.assertProblem(resp, t.expectedResp)
+		})
+	}
+
+	s.Run("[006] Errors of the items of a batch in English", func() {
+		api := MealAPI{DB: *s.db, Manager: managers.NewMealManager(*s.db)}
+		body, _ := jsoniter.Marshal(&models.MealBatch{
+			Mode:       models.BatchModeBestEffort,
+			Operations: []models.MealBatchOperation{{Op: models.BatchOpDelete, Id: "01FN3EEB2NVFJAHAPM00000099", IfMatch: `"1"`}},
+		})
+		req := httptest.NewRequest(http.MethodPost, internal.RouteMealBatch, bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(internal.HeaderAcceptLanguage, "en")
+		resp := httptest.NewRecorder()
+		c := echo.New().NewContext(req, resp)
+		c.SetParamNames(internal.ParamUserID)
+		c.SetParamValues("01FN3EEB2NVFJAHAPU00000001")
+
+		s.NoError(api.BatchMealsHandler(c))
+		s.Equal(http.StatusMultiStatus, resp.Code)
+		result := new(models.MealBatchResult)
+		s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), result))
+		s.Require().Len(result.Results, 1)
+		s.Require().NotNil(result.Results[0].Error)
+		s.Equal("MEAL_NOT_FOUND", result.Results[0].Error.Code)
+		s.Equal("meal not found", result.Results[0].Error.Message)
+	})
+
+	s.Run("[007] Detail of the error in English", func() {
+		api := MealAPI{DB: *s.db, Manager: managers.NewMealManager(*s.db)}
+		req := httptest.NewRequest(http.MethodGet, internal.RouteMeal+"?kcal_min=500&kcal_max=100", nil)
+		req.Header.Set(internal.HeaderAcceptLanguage, "en")
+		resp := httptest.NewRecorder()
+		c := echo.New().NewContext(req, resp)
+		c.SetParamNames(internal.ParamUserID)
+		c.SetParamValues("01FN3EEB2NVFJAHAPU00000001")
+
+		s.Error(api.ListMealsHandler(c))
+		problem := new(internal.ErrorResponse)
+		s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), problem))
+		s.Equal("REQUEST_NOT_VALID", problem.Code)
+		s.Equal("kcal_min can not be greater than kcal_max", problem.Detail)
+	})
+}
+
+func (s *MealAPITestSuite) TestGetCatalogHandler() {
+	tests := []struct {
+		name             string
+		acceptLanguage   string
+		expectedLanguage string
+		expectedSeason   models.CatalogEntry
+		expectedCategory string
+		expectedLettuce  string
+	}{
+		{
+			name:             "[001] Catalog in Spanish (ok)",
+			expectedLanguage: "es",
+			expectedSeason:   models.CatalogEntry{Key: "general", Name: "Todo el año"},
+			expectedCategory: "Verduras",
+			expectedLettuce:  "Lechuga",
+		},
+		{
+			name:             "[002] Catalog in English (ok)",
+			acceptLanguage:   "en-US",
+			expectedLanguage: "en",
+			expectedSeason:   models.CatalogEntry{Key: "general", Name: "All year"},
+			expectedCategory: "Vegetables",
+			expectedLettuce:  "Lettuce",
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			api := MealAPI{DB: *s.db, Manager: managers.NewMealManager(*s.db)}
+			req := httptest.NewRequest(http.MethodGet, internal.RouteCatalog, nil)
+			if t.acceptLanguage != "" {
+				req.Header.Set(internal.HeaderAcceptLanguage, t.acceptLanguage)
+			}
+			resp := httptest.NewRecorder()
+
+			s.NoError(api.GetCatalogHandler(echo.New().NewContext(req, resp)))
+			s.Equal(http.StatusOK, resp.Code)
+			s.Equal(t.expectedLanguage, resp.Header().Get(internal.HeaderContentLanguage))
+
+			catalog := new(models.Catalog)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), catalog))
+			s.Equal(t.expectedLanguage, catalog.Language)
+			s.Len(catalog.Types, len(models.MealTypes))
+			s.Contains(catalog.Seasons, t.expectedSeason)
+			s.Len(catalog.Categories, len(models.Ingredients))
+
+			var lettuce *models.CatalogIngredient
+			for _, category := range catalog.Categories {
+				s.Len(category.Ingredients, len(models.Ingredients[category.Key]))
+				if category.Key == "Verduras" {
+					s.Equal(t.expectedCategory, category.Name)
+					for i := range category.Ingredients {
+						if category.Ingredients[i].Key == "Lechuga" {
+							lettuce = &category.Ingredients[i]
+						}
+					}
+				}
+			}
+			s.Require().NotNil(lettuce)
+			s.Equal(t.expectedLettuce, lettuce.Name)
+			s.Equal(models.Vegetables["Lechuga"], lettuce.Kcal)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"io"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/etag"
+	"meals/pkg/url"
+	"net/http"
+)
+
+// imageCacheControl lets the clients keep the images, revalidating them with their ETag on every use, as
+// an image uploaded again keeps its URL
+const imageCacheControl = "private, no-cache"
+
+func (a *MealAPI) PostMealImageHandler(c echo.Context) error {
+	var userID, mealID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+		internal.ParamMealID: {Target: &mealID, Err: internal.ErrMealIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	// The form can take up to 1 MB besides the image
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, models.MaxImageBytes+1<<20)
+	fileHeader, err := c.FormFile("image")
+	if isTooLarge(err) {
+		return internal.NewErrorResponse(c, internal.ErrImageTooLarge)
+	}
+	if err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	if fileHeader.Size > models.MaxImageBytes {
+		return internal.NewErrorResponse(c, internal.ErrImageTooLarge)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+
+	image, err := a.Manager.PutMealImage(req.Context(), userID, mealID, content)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusCreated, image)
+}
+
+func (a *MealAPI) GetMealImageHandler(c echo.Context) error {
+	var userID, mealID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+		internal.ParamMealID: {Target: &mealID, Err: internal.ErrMealIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	size := c.Param(internal.ParamSize)
+	if size == "" {
+		size = models.ImageSizeOriginal
+	}
+
+	image, err := a.Manager.GetMealImage(c.Request().Context(), userID, mealID, size)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	tag := etag.Content(image.Content)
+	header := c.Response().Header()
+	header.Set(internal.HeaderETag, tag)
+	header.Set(echo.HeaderCacheControl, imageCacheControl)
+	if !image.ModTime.IsZero() {
+		header.Set(echo.HeaderLastModified, image.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if etag.Match(c.Request().Header.Get(internal.HeaderIfNoneMatch), tag, true) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, image.ContentType, image.Content)
+}
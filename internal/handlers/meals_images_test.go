@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"meals/internal"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"meals/pkg/blob"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+const (
+	imageUserID = "01FN3EEB2NVFJAHAPU00000001"
+	imageMealID = "01FN3EEB2NVFJAHAPM00000001"
+)
+
+// webpPixel is a lossless WebP of a single pixel
+var webpPixel, _ = base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+
+// imageUpload returns the request uploading the content as the image of the meal
+func (s *MealAPITestSuite) imageUpload(mealID string, content []byte) testRequest {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("image", "photo.jpg")
+	s.Require().NoError(err)
+	_, _ = part.Write(content)
+	s.Require().NoError(form.Close())
+	return testRequest{
+		method:  http.MethodPost,
+		target:  internal.RouteMealImage,
+		params:  []string{imageUserID, mealID},
+		headers: map[string]string{echo.HeaderContentType: form.FormDataContentType()},
+		body:    body.Bytes(),
+	}
+}
+
+// photo returns a JPEG of 300x200, red on the left half and blue on the right one, with the EXIF
+// orientation indicated
+func photo(orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+			if x >= 150 {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	encoded := new(bytes.Buffer)
+	_ = jpeg.Encode(encoded, img, nil)
+
+	// APP1 segment with a TIFF directory of a single entry, the orientation
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	content := append([]byte{}, encoded.Bytes()[:2]...)
+	content = append(content, segment...)
+	return append(content, encoded.Bytes()[2:]...)
+}
+
+// decodeImage returns the image of the response along with its format
+func (s *MealAPITestSuite) decodeImage(resp *httptest.ResponseRecorder) (image.Image, string) {
+	img, format, err := image.Decode(bytes.NewReader(resp.Body.Bytes()))
+	s.Require().NoError(err)
+	return img, format
+}
+
+func (s *MealAPITestSuite) TestMealImages() {
+	api := s.newAPI()
+	transparent := image.NewNRGBA(image.Rect(0, 0, 600, 600))
+	transparent.Set(10, 10, color.NRGBA{G: 255, A: 128})
+	encoded := new(bytes.Buffer)
+	s.Require().NoError(png.Encode(encoded, transparent))
+	// tag is the ETag of the small image got by the test of the cache
+	tag := ""
+	url := "/user/" + imageUserID + "/meal/" + imageMealID + "/image"
+
+	decoded := func(expectedType string, expectedBounds image.Rectangle) func(resp *httptest.ResponseRecorder) {
+		return func(resp *httptest.ResponseRecorder) {
+			s.Equal(expectedType, resp.Header().Get(echo.HeaderContentType))
+			img, _ := s.decodeImage(resp)
+			s.Equal(expectedBounds, img.Bounds())
+		}
+	}
+
+	tests := []struct {
+		name string
+		// upload is the content uploaded as the image of the meal, the image of the meal is got when nil
+		upload []byte
+		mealID string
+		size   string
+		// ifNoneMatch is the ETag the image is got with, when indicated
+		ifNoneMatch        *string
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+		// check checks the response, when there is no error
+		check func(resp *httptest.ResponseRecorder)
+	}{
+		{
+			name:               "[001] Upload a photo taken sideways (ok)",
+			upload:             photo(6),
+			mealID:             imageMealID,
+			expectedStatusCode: http.StatusCreated,
+			check: func(resp *httptest.ResponseRecorder) {
+				uploaded := new(models.MealImage)
+				s.Require().NoError(jsoniter.Unmarshal(resp.Body.Bytes(), uploaded))
+				s.Equal(&models.MealImage{Image: url, Thumbnails: map[string]string{
+					"small": url + "/small", "medium": url + "/medium", "large": url + "/large",
+				}}, uploaded)
+
+				meal, err := api.Manager.GetMeal(context.Background(), imageUserID, imageMealID)
+				s.Require().NoError(err)
+				s.Equal(url, meal.Image)
+			},
+		},
+		{
+			name:               "[002] The photo is stored upright and without EXIF (ok)",
+			mealID:             imageMealID,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				s.Equal("image/jpeg", resp.Header().Get(echo.HeaderContentType))
+				s.NotContains(resp.Body.String(), "Exif")
+				img, _ := s.decodeImage(resp)
+				s.Equal(image.Rect(0, 0, 200, 300), img.Bounds())
+				r, _, b, _ := img.At(100, 30).RGBA()
+				s.Greater(r, b, "the left half of the photo is the top one once upright")
+				r, _, b, _ = img.At(100, 270).RGBA()
+				s.Greater(b, r)
+			},
+		},
+		{
+			name:               "[003] Upload a photo (ok)",
+			upload:             photo(1),
+			mealID:             imageMealID,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[004] The small thumbnail fits its size (ok)",
+			mealID:             imageMealID,
+			size:               "small",
+			expectedStatusCode: http.StatusOK,
+			check:              decoded("image/jpeg", image.Rect(0, 0, 150, 100)),
+		},
+		{
+			name:               "[005] The medium thumbnail is never enlarged (ok)",
+			mealID:             imageMealID,
+			size:               "medium",
+			expectedStatusCode: http.StatusOK,
+			check:              decoded("image/jpeg", image.Rect(0, 0, 300, 200)),
+		},
+		{
+			name:               "[006] The large thumbnail is never enlarged (ok)",
+			mealID:             imageMealID,
+			size:               "large",
+			expectedStatusCode: http.StatusOK,
+			check:              decoded("image/jpeg", image.Rect(0, 0, 300, 200)),
+		},
+		{
+			name:               "[007] Upload an image with transparency (ok)",
+			upload:             encoded.Bytes(),
+			mealID:             imageMealID,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[008] Images with transparency are kept as PNG (ok)",
+			mealID:             imageMealID,
+			size:               "medium",
+			expectedStatusCode: http.StatusOK,
+			check:              decoded("image/png", image.Rect(0, 0, 400, 400)),
+		},
+		{
+			name:               "[009] Upload a WebP image (ok)",
+			upload:             webpPixel,
+			mealID:             imageMealID,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[010] Upload a file that is not an image (error)",
+			upload:             []byte("%PDF-1.4 not an image"),
+			mealID:             imageMealID,
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusUnsupportedMediaType, Code: "IMAGE_TYPE_NOT_SUPPORTED", Title: internal.ErrImageNotSupported.Error()},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			wantErr:            true,
+		},
+		{
+			name:               "[011] Upload an image over the size limit (error)",
+			upload:             append(photo(1), make([]byte, models.MaxImageBytes)...),
+			mealID:             imageMealID,
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusRequestEntityTooLarge, Code: "IMAGE_TOO_LARGE", Title: internal.ErrImageTooLarge.Error()},
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			wantErr:            true,
+		},
+		{
+			name:               "[012] Upload the image of a meal that does not exist (error)",
+			upload:             photo(1),
+			mealID:             "01FN3EEB2NVFJAHAPM00000009",
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusNotFound, Code: "MEAL_NOT_FOUND", Title: internal.ErrMealNotFound.Error()},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:               "[013] Get the image of a meal without it (error)",
+			mealID:             "01FN3EEB2NVFJAHAPM00000002",
+			size:               "small",
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusNotFound, Code: "IMAGE_NOT_FOUND", Title: internal.ErrImageNotFound.Error()},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:               "[014] Get an image to keep in the cache of the client, revalidated on every use (ok)",
+			mealID:             imageMealID,
+			size:               "small",
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				tag = resp.Header().Get(internal.HeaderETag)
+				s.NotEmpty(tag)
+				s.Equal("private, no-cache", resp.Header().Get(echo.HeaderCacheControl))
+				s.NotEmpty(resp.Header().Get(echo.HeaderLastModified))
+			},
+		},
+		{
+			name:               "[015] Get an image still current in the cache of the client (ok)",
+			mealID:             imageMealID,
+			size:               "small",
+			ifNoneMatch:        &tag,
+			expectedStatusCode: http.StatusNotModified,
+			check: func(resp *httptest.ResponseRecorder) {
+				s.Empty(resp.Body.Bytes())
+			},
+		},
+		{
+			name:               "[016] Upload the image again (ok)",
+			upload:             photo(2),
+			mealID:             imageMealID,
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:               "[017] The image uploaded again replaces the one in the cache of the client (ok)",
+			mealID:             imageMealID,
+			size:               "small",
+			ifNoneMatch:        &tag,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				s.NotEqual(tag, resp.Header().Get(internal.HeaderETag))
+			},
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			var resp *httptest.ResponseRecorder
+			var err error
+			if t.upload != nil {
+				resp, err = s.request(api.PostMealImageHandler, s.imageUpload(t.mealID, t.upload))
+			} else {
+				headers := map[string]string{}
+				if t.ifNoneMatch != nil {
+					headers[internal.HeaderIfNoneMatch] = *t.ifNoneMatch
+				}
+				resp, err = s.request(api.GetMealImageHandler, testRequest{method: http.MethodGet, target: internal.RouteMealImageSize, params: []string{imageUserID, t.mealID, t.size}, headers: headers})
+			}
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			if t.check != nil {
+				t.check(resp)
+			}
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestMealImagesDeleted() {
+	s.httpMock.On("GetCalendar", mock.Anything, imageUserID, mock.Anything, true).Return(nil)
+	api := s.newAPI()
+	for _, mealID := range []string{imageMealID, "01FN3EEB2NVFJAHAPM00000002"} {
+		resp, err := s.request(api.PostMealImageHandler, s.imageUpload(mealID, photo(1)))
+		s.Require().NoError(err)
+		s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+	}
+
+	s.Run("[001] Deleting the meals deletes their images (ok)", func() {
+		s.Require().NoError(api.Manager.DeleteMeal(context.Background(), imageUserID, imageMealID, "*"))
+		result, err := api.Manager.BatchMeals(context.Background(), imageUserID, models.MealBatch{Operations: []models.MealBatchOperation{
+			{Op: models.BatchOpDelete, Id: "01FN3EEB2NVFJAHAPM00000002", IfMatch: "*"},
+		}})
+		s.Require().NoError(err)
+		s.True(result.Committed)
+		for _, mealID := range []string{imageMealID, "01FN3EEB2NVFJAHAPM00000002"} {
+			for _, size := range []string{models.ImageSizeOriginal, "small", "medium", "large"} {
+				_, err = managers.Images.Get(context.Background(), "users/"+imageUserID+"/meals/"+mealID+"/images/"+size)
+				s.ErrorIs(err, blob.ErrNotFound)
+			}
+		}
+	})
+}
+
+// fakeS3 is an in memory S3 compatible store of a single bucket, enough for the requests of blob.S3
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]blob.Blob
+	signed  bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.signed = strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/")
+	key := strings.TrimPrefix(r.URL.Path, "/images/")
+	switch {
+	case r.Method == http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		f.objects[key] = blob.Blob{Content: content, ContentType: r.Header.Get(echo.HeaderContentType)}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Query().Get("list-type") == "2":
+		type object struct {
+			Key string `xml:"Key"`
+		}
+		list := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []object `xml:"Contents"`
+		}{}
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				list.Contents = append(list.Contents, object{Key: name})
+			}
+		}
+		_ = xml.NewEncoder(w).Encode(list)
+	default:
+		object, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(echo.HeaderContentType, object.ContentType)
+		_, _ = w.Write(object.Content)
+	}
+}
+
+func (s *MealAPITestSuite) TestMealImagesS3() {
+	fake := &fakeS3{objects: map[string]blob.Blob{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	managers.Images = blob.NewS3(blob.S3Config{
+		Endpoint: server.URL, Bucket: "images", Region: "eu-west-1", AccessKey: "access", SecretKey: "secret",
+	}, server.Client())
+	api := s.newAPI()
+
+	s.Run("[001] Upload and get an image from an S3 compatible store (ok)", func() {
+		resp, err := s.request(api.PostMealImageHandler, s.imageUpload(imageMealID, photo(1)))
+		s.Require().NoError(err)
+		s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+		s.Len(fake.objects, 4)
+		s.True(fake.signed)
+
+		resp, err = s.request(api.GetMealImageHandler, testRequest{method: http.MethodGet, target: internal.RouteMealImageSize, params: []string{imageUserID, imageMealID, "large"}})
+		s.Require().NoError(err)
+		s.Equal("image/jpeg", resp.Header().Get(echo.HeaderContentType))
+		img, _ := s.decodeImage(resp)
+		s.Equal(image.Rect(0, 0, 300, 200), img.Bounds())
+	})
+
+	s.Run("[002] Deleting the meal deletes its objects (ok)", func() {
+		s.httpMock.On("GetCalendar", mock.Anything, imageUserID, mock.Anything, true).Return(nil)
+		s.Require().NoError(api.Manager.DeleteMeal(context.Background(), imageUserID, imageMealID, "*"))
+		s.Empty(fake.objects)
+	})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"meals/internal"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *MealAPITestSuite) TestPatchMealHandler() {
+	tests := []struct {
+		name               string
+		userID             string
+		mealID             string
+		contentType        string
+		ifMatch            string
+		reqBody            string
+		renamed            bool
+		expectedETag       string
+		expectedResp       interface{}
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:         "[001] Merge patch of the description keeps the rest of the meal (ok)",
+			userID:       "01FN3EEB2NVFJAHAPU00000001",
+			mealID:       "01FN3EEB2NVFJAHAPM00000001",
+			contentType:  models.MIMEMergePatch,
+			ifMatch:      `"1"`,
+			reqBody:      `{"description":"Con mucho queso"}`,
+			expectedETag: `"2"`,
+			expectedResp: &models.Meal{
+				Id:          "01FN3EEB2NVFJAHAPM00000001",
+				Name:        "pizza",
+				Description: "Con mucho queso",
+				Type:        "ocasional",
+				Ingredients: []string{"Tomate", "Queso", "Pollo"},
+				Kcal:        130,
+				KcalTotal:   130,
+				Seasons:     []string{"invierno", "verano"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
+				Allergens:   []string{"lacteos"},
+				Diets:       []string{},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name:         "[002] JSON patch of name and ingredients renames and recomputes kcal (ok)",
+			userID:       "01FN3EEB2NVFJAHAPU00000001",
+			mealID:       "01FN3EEB2NVFJAHAPM00000002",
+			contentType:  models.MIMEJSONPatch,
+			ifMatch:      `"1"`,
+			reqBody:      `[{"op":"replace","path":"/name","value":"ensalada verde"},{"op":"replace","path":"/ingredients","value":["Lechuga","Pepino"]}]`,
+			renamed:      true,
+			expectedETag: `"2"`,
+			expectedResp: &models.Meal{
+				Id:          "01FN3EEB2NVFJAHAPM00000002",
+				Name:        "ensalada verde",
+				Type:        "semanal",
+				Ingredients: []string{"Lechuga", "Pepino"},
+				Kcal:        15,
+				KcalTotal:   15,
+				Seasons:     []string{"general"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
+				Allergens:   []string{},
+				Diets:       []string{"vegetariana", "vegana", "pescetariana"},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name:         "[003] Plain JSON is handled as a merge patch (ok)",
+			userID:       "01FN3EEB2NVFJAHAPU00000001",
+			mealID:       "01FN3EEB2NVFJAHAPM00000001",
+			contentType:  echo.MIMEApplicationJSON,
+			ifMatch:      `"2"`,
+			reqBody:      `{"image":"pizza.jpg"}`,
+			expectedETag: `"3"`,
+			expectedResp: &models.Meal{
+				Id:          "01FN3EEB2NVFJAHAPM00000001",
+				Name:        "pizza",
+				Description: "Con mucho queso",
+				Image:       "pizza.jpg",
+				Type:        "ocasional",
+				Ingredients: []string{"Tomate", "Queso", "Pollo"},
+				Kcal:        130,
+				KcalTotal:   130,
+				Seasons:     []string{"invierno", "verano"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
+				Allergens:   []string{"lacteos"},
+				Diets:       []string{},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name:        "[004] Patch leaves the meal invalid (400)",
+			userID:      "01FN3EEB2NVFJAHAPU00000001",
+			mealID:      "01FN3EEB2NVFJAHAPM00000001",
+			contentType: models.MIMEMergePatch,
+			ifMatch:     `"3"`,
+			reqBody:     `{"type":"diario"}`,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:        "[005] Patch with an outdated version (412)",
+			userID:      "01FN3EEB2NVFJAHAPU00000001",
+			mealID:      "01FN3EEB2NVFJAHAPM00000001",
+			contentType: models.MIMEMergePatch,
+			ifMatch:     `"4"`,
+			reqBody:     `{"description":"Con mucho queso"}`,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusPreconditionFailed,
+				Code:   "MEAL_VERSION_MISMATCH",
+				Title:  internal.ErrMealVersionMismatch.Error(),
+			},
+			expectedStatusCode: http.StatusPreconditionFailed,
+			wantErr:            true,
+		},
+		{
+			name:        "[006] Unsupported patch format (415)",
+			userID:      "01FN3EEB2NVFJAHAPU00000001",
+			mealID:      "01FN3EEB2NVFJAHAPM00000001",
+			contentType: echo.MIMETextPlain,
+			ifMatch:     `*`,
+			reqBody:     `description=Con mucho queso`,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusUnsupportedMediaType,
+				Code:   "PATCH_FORMAT_NOT_SUPPORTED",
+				Title:  internal.ErrPatchNotSupported.Error(),
+			},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			wantErr:            true,
+		},
+		{
+			name:        "[007] Meal does not exist (404)",
+			userID:      "01FN3EEB2NVFJAHAPU00000001",
+			mealID:      "01FN3EEB2NVFJAHAPM00000099",
+			contentType: models.MIMEMergePatch,
+			ifMatch:     `"1"`,
+			reqBody:     `{"description":"Con mucho queso"}`,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  internal.ErrMealNotFound.Error(),
+			},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:        "[008] Patch without If-Match (428)",
+			userID:      "01FN3EEB2NVFJAHAPU00000001",
+			mealID:      "01FN3EEB2NVFJAHAPM00000001",
+			contentType: models.MIMEMergePatch,
+			reqBody:     `{"description":"Sin versión"}`,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusPreconditionRequired,
+				Code:   "IF_MATCH_REQUIRED",
+				Title:  internal.ErrIfMatchNotPresent.Error(),
+			},
+			expectedStatusCode: http.StatusPreconditionRequired,
+			wantErr:            true,
+		},
+		{
+			name:         "[009] Patch of the kcal keeps the ones of the ingredients (ok)",
+			userID:       "01FN3EEB2NVFJAHAPU00000001",
+			mealID:       "01FN3EEB2NVFJAHAPM00000001",
+			contentType:  models.MIMEMergePatch,
+			ifMatch:      `"3"`,
+			reqBody:      `{"kcal":99999}`,
+			expectedETag: `"4"`,
+			expectedResp: &models.Meal{
+				Id:          "01FN3EEB2NVFJAHAPM00000001",
+				Name:        "pizza",
+				Description: "Con mucho queso",
+				Image:       "pizza.jpg",
+				Type:        "ocasional",
+				Ingredients: []string{"Tomate", "Queso", "Pollo"},
+				Kcal:        130,
+				KcalTotal:   130,
+				Seasons:     []string{"invierno", "verano"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
+				Allergens:   []string{"lacteos"},
+				Diets:       []string{},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+	}
+	getEchoContext := func(userId, mealId, contentType, ifMatch, body string) echo.Context {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPatch, internal.RouteMealID, bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		if ifMatch != "" {
+			req.Header.Set(internal.HeaderIfMatch, ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames(internal.ParamUserID, internal.ParamMealID)
+		c.SetParamValues(userId, mealId)
+		return c
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			mealManager := managers.NewMealManager(*s.db)
+			api := MealAPI{DB: *s.db, Manager: mealManager}
+			// Only a rename has to be propagated to the calendars
+			s.httpMock = &internal.EndpointsMock{}
+			managers.Microservices = s.httpMock
+			if t.renamed {
+				s.httpMock.On("GetCalendar", mock.Anything, t.userID, mock.Anything, false).Return(nil).Once()
+			}
+
+			c := getEchoContext(t.userID, t.mealID, t.contentType, t.ifMatch, t.reqBody)
+			err := api.PatchMealHandler(c)
+
+			resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
+			s.True(ok)
+			body := resp.Body.Bytes()
+			if t.wantErr {
+				s.Equal(t.wantErr, err != nil)
+				s.assertProblem(resp, t.expectedResp)
+			} else {
+				actualMeal := new(models.Meal)
+				s.NoError(jsoniter.Unmarshal(body, actualMeal))
+				s.Equal(actualMeal, t.expectedResp)
+				s.Equal(t.expectedETag, resp.Header().Get(internal.HeaderETag))
+				s.httpMock.AssertExpectations(s.T())
+			}
+
+			s.Equal(t.expectedStatusCode, c.Response().Status)
+		})
+	}
+}
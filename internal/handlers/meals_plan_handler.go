@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/url"
+	"net/http"
+)
+
+func (a *MealAPI) GeneratePlanHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	request := &models.MealPlanRequest{}
+	if err := c.Bind(request); err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &request.ProfileOverride); err != nil {
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+	}
+	plan, err := a.Manager.GeneratePlan(c.Request().Context(), userID, *request)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	for _, day := range plan.Days {
+		for _, slot := range day.Slots {
+			if slot.Meal != nil {
+				cleanMeal(slot.Meal)
+			}
+		}
+	}
+	return c.JSON(http.StatusOK, plan)
+}
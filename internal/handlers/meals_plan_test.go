@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"github.com/json-iterator/go"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (s *MealAPITestSuite) TestGeneratePlanHandler() {
+	const userID = "01FN3EEB2NVFJAHAPU00000001"
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000003", userID, "paella", "", "", "normal", "Arroz blanco|Gambas", 600, "verano")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000004", userID, "lentejas", "", "", "normal", "Lentejas|Chorizo", 400, "invierno")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000005", userID, "tortilla", "", "", "normal", "Huevo entero|Patatas fritas", 300, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000006", userID, "pollo asado", "", "", "normal", "Pollo", 500, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000007", userID, "merluza", "", "", "normal", "Merluza", 250, "otoño,invierno")
+	seed := int64(42)
+	noRepeat := 30
+
+	tests := []struct {
+		name               string
+		userID             string
+		reqBody            *models.MealPlanRequest
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+		// check checks the plan generated, when there is no error
+		check func(resp *httptest.ResponseRecorder, plan *models.MealPlan)
+	}{
+		{
+			name:               "[001] Plan of a winter week respecting season, type and repeats (ok)",
+			userID:             userID,
+			reqBody:            &models.MealPlanRequest{From: "2024-01-08", To: "2024-01-14", DailyKcal: 800, Seed: &seed},
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder, plan *models.MealPlan) {
+				s.Equal(seed, plan.Seed)
+				s.Equal(models.PlanDefaultSlotsPerDay, plan.SlotsPerDay)
+				s.Equal(models.PlanDefaultNoRepeatDays, plan.NoRepeatDays)
+				s.Require().Len(plan.Days, 7)
+				perType := map[string]int{}
+				lastPlanned := map[string]int{}
+				for day, planDay := range plan.Days {
+					s.Equal("invierno", planDay.Season)
+					s.Len(planDay.Slots, models.PlanDefaultSlotsPerDay)
+					kcal := 0
+					for _, slot := range planDay.Slots {
+						if slot.Meal == nil {
+							continue
+						}
+						s.NotEqual("paella", slot.Meal.Name, "out of season")
+						s.Empty(slot.Meal.UserId)
+						if last, ok := lastPlanned[slot.Meal.Id]; ok {
+							s.GreaterOrEqual(day-last, plan.NoRepeatDays, "%s repeated on %s", slot.Meal.Name, planDay.Date)
+						}
+						lastPlanned[slot.Meal.Id] = day
+						perType[slot.Meal.Type]++
+						kcal += slot.Meal.Kcal
+					}
+					s.Equal(kcal, planDay.Kcal)
+				}
+				s.LessOrEqual(perType["ocasional"], 1)
+				s.LessOrEqual(perType["semanal"], 2)
+
+				again, err := s.request(s.newAPI().GeneratePlanHandler, testRequest{
+					method: http.MethodPost,
+					target: internal.RoutePlanGenerate,
+					params: []string{userID},
+					body:   &models.MealPlanRequest{From: "2024-01-08", To: "2024-01-14", DailyKcal: 800, Seed: &seed},
+				})
+				s.NoError(err)
+				s.JSONEq(resp.Body.String(), again.Body.String(), "the same seed must generate the same plan")
+			},
+		},
+		{
+			name:               "[002] Slots without meal when the rules can not be met (ok)",
+			userID:             userID,
+			reqBody:            &models.MealPlanRequest{From: "2024-07-01", To: "2024-07-03", SlotsPerDay: 3, NoRepeatDays: &noRepeat, Seed: &seed},
+			expectedStatusCode: http.StatusOK,
+			check: func(_ *httptest.ResponseRecorder, plan *models.MealPlan) {
+				// Summer: pizza, ensalada, paella, tortilla and pollo asado, each of them once
+				s.Equal(9-5, plan.Unfilled)
+				for _, planDay := range plan.Days {
+					s.Equal("verano", planDay.Season)
+				}
+			},
+		},
+		{
+			name:    "[003] Dates in the wrong format (ko)",
+			userID:  userID,
+			reqBody: &models.MealPlanRequest{From: "08/01/2024", To: "2024-01-14"},
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
+				Errors: []models.FieldError{{Field: "from", Code: "datetime", Message: "debe ser una fecha con el formato AAAA-MM-DD"}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[004] End before the start (ko)",
+			userID:             userID,
+			reqBody:            &models.MealPlanRequest{From: "2024-01-14", To: "2024-01-08"},
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "WRONG_BODY", Title: internal.ErrWrongBody.Error()},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[005] More than 31 days (ko)",
+			userID:             userID,
+			reqBody:            &models.MealPlanRequest{From: "2024-01-01", To: "2024-02-01"},
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "WRONG_BODY", Title: internal.ErrWrongBody.Error()},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[006] User without meals (ko)",
+			userID:             "01FN3EEB2NVFJAHAPU00000099",
+			reqBody:            &models.MealPlanRequest{From: "2024-01-08", To: "2024-01-14"},
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusNotFound, Code: "MEALS_NOT_FOUND", Title: internal.ErrMealsNotFound.Error()},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(s.newAPI().GeneratePlanHandler, testRequest{
+				method: http.MethodPost,
+				target: internal.RoutePlanGenerate,
+				params: []string{t.userID},
+				body:   t.reqBody,
+			})
+			s.Equal(t.expectedStatusCode, resp.Code)
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			plan := new(models.MealPlan)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), plan))
+			t.check(resp, plan)
+		})
+	}
+}
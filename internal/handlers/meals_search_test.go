@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"github.com/json-iterator/go"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"net/http"
+	"net/url"
+)
+
+func mealNames(meals []models.Meal) []string {
+	names := make([]string, 0, len(meals))
+	for _, meal := range meals {
+		names = append(names, meal.Name)
+	}
+	return names
+}
+
+func (s *MealAPITestSuite) TestSearchMealsHandler() {
+	api := s.newAPI()
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000003", "01FN3EEB2NVFJAHAPU00000001", "Batido de plátano", "Con leche y canela", "", "normal", "Plátano|Leche entera", 150, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000004", "01FN3EEB2NVFJAHAPU00000001", "Pollo al curry", "Pollo guisado con curry", "", "normal", "Pollo|Curry|Arroz", 450, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000005", "01FN3EEB2NVFJAHAPU00000002", "Pollo asado", "", "", "normal", "Pollo", 300, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000006", "01FN3EEB2NVFJAHAPU00000001", "Flan <img src=x onerror=alert(1)>", "", "", "normal", "Huevo entero", 200, "general")
+
+	tests := []struct {
+		name string
+		// changes are the statements run on the meals before the search
+		changes            []string
+		query              url.Values
+		expectedStatusCode int
+		expectedNames      []string
+		// check checks the meals found, when indicated
+		check func(meals []models.Meal)
+	}{
+		{
+			name:               "[001] Words without accents find the ones with them, highlighted (ok)",
+			query:              url.Values{"q": {"PLATANO"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Batido de plátano"},
+			check: func(meals []models.Meal) {
+				s.Contains(meals[0].Snippet, "<mark>plátano</mark>")
+			},
+		},
+		{
+			name:               "[002] Partial words, sorted by relevance (ok)",
+			query:              url.Values{"q": {"pol"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Pollo al curry", "pizza"},
+		},
+		{
+			name:               "[003] Words in any order (ok)",
+			query:              url.Values{"q": {"curry pollo"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Pollo al curry"},
+		},
+		{
+			name:               "[004] Search along with the type (ok)",
+			query:              url.Values{"q": {"tomate"}, "type": {"semanal"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"ensalada"},
+		},
+		{
+			name:               "[005] Search along with the healthy filter (ok)",
+			query:              url.Values{"q": {"pollo"}, "healthy": {"true"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"pizza", "Pollo al curry"},
+		},
+		{
+			name:               "[006] The syntax of the search is not exposed (ok)",
+			query:              url.Values{"q": {`"curry* ^(`}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Pollo al curry"},
+		},
+		{
+			name:               "[007] The text of the snippet is escaped as HTML (ok)",
+			query:              url.Values{"q": {"flan"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Flan <img src=x onerror=alert(1)>"},
+			check: func(meals []models.Meal) {
+				s.Equal("<mark>Flan</mark> &lt;img src=x onerror=alert(1)&gt;", meals[0].Snippet)
+			},
+		},
+		{
+			name: "[008] The search follows the meals updated (ok)",
+			changes: []string{
+				"UPDATE meals SET name = 'Tortitas', description = '' WHERE id = '01FN3EEB2NVFJAHAPM00000003'",
+				"DELETE FROM meals WHERE id = '01FN3EEB2NVFJAHAPM00000004'",
+			},
+			query:              url.Values{"q": {"tortitas"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Tortitas"},
+		},
+		{
+			name:               "[009] The search leaves out the old text of the meals updated (ok)",
+			query:              url.Values{"q": {"batido"}},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "[010] The search leaves out the meals deleted (ok)",
+			query:              url.Values{"q": {"curry"}},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "[011] The search keeps finding the meals after a VACUUM (ok)",
+			changes:            []string{"VACUUM"},
+			query:              url.Values{"q": {"flan"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Flan <img src=x onerror=alert(1)>"},
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			for _, change := range t.changes {
+				_, err := s.db.Conn.Exec(change)
+				s.Require().NoError(err)
+			}
+			resp, _ := s.request(api.ListMealsHandler, testRequest{method: http.MethodGet, target: internal.RouteMeal + "/?" + t.query.Encode(), params: []string{"01FN3EEB2NVFJAHAPU00000001"}})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.expectedStatusCode != http.StatusOK {
+				return
+			}
+			var meals []models.Meal
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &meals))
+			s.Require().Equal(t.expectedNames, mealNames(meals))
+			if t.check != nil {
+				t.check(meals)
+			}
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestListMealsByIngredientsHandler() {
+	api := s.newAPI()
+	const userID = "01FN3EEB2NVFJAHAPU00000003"
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000003", userID, "Muslos al horno", "", "", "normal", "Pollo, Muslo|Patatas cocidas", 400, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000004", userID, "Pollo asado", "", "", "normal", "Pollo", 300, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000005", userID, "Batido", "", "", "normal", "Plátano|Leche entera", 150, "general")
+
+	tests := []struct {
+		name               string
+		query              url.Values
+		expectedStatusCode int
+		expectedNames      []string
+	}{
+		{
+			name:               "[001] Catalog names with commas are not split (ok)",
+			query:              url.Values{"ingredients_all": {"Pollo, Muslo"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Muslos al horno"},
+		},
+		{
+			name:               "[002] A catalog name does not match the longer ones that start with it (ok)",
+			query:              url.Values{"ingredients_all": {"Pollo"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Pollo asado"},
+		},
+		{
+			name:               "[003] Any of the repeated ingredients (ok)",
+			query:              url.Values{"ingredients_any": {"pollo, muslo", "Pollo"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Muslos al horno", "Pollo asado"},
+		},
+		{
+			name:               "[004] Ingredients without accents match the ones with them (ok)",
+			query:              url.Values{"ingredients_any": {"PLATANO"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Batido"},
+		},
+		{
+			name:               "[005] Ingredients with accents leave out the meals (ok)",
+			query:              url.Values{"ingredients_none": {"plátano", "pollo, muslo"}},
+			expectedStatusCode: http.StatusOK,
+			expectedNames:      []string{"Pollo asado"},
+		},
+		{
+			name:               "[006] Comma separated ingredients are a single one (404)",
+			query:              url.Values{"ingredients_any": {"Pollo,Plátano"}},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, _ := s.request(api.ListMealsHandler, testRequest{method: http.MethodGet, target: internal.RouteMeal + "/?" + t.query.Encode(), params: []string{userID}})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.expectedStatusCode != http.StatusOK {
+				return
+			}
+			var meals []models.Meal
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &meals))
+			s.Equal(t.expectedNames, mealNames(meals))
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"github.com/json-iterator/go"
+	"meals/internal"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"net/http"
+)
+
+func (s *MealAPITestSuite) TestMealServings() {
+	const userID = "01FN3EEB2NVFJAHAPU00000001"
+	api := s.newAPI()
+	ingredients := []string{
+		"250 g de Arroz blanco",
+		"2 Huevo entero",
+		"0.75 tazas de Leche entera",
+		"1 cucharada de Sal",
+		"Pimienta",
+	}
+	risotto, err := api.Manager.CreateMeal(context.Background(), userID, models.Meal{
+		Name:        "risotto",
+		Type:        "normal",
+		Seasons:     []string{"general"},
+		Servings:    3,
+		Ingredients: ingredients,
+	}, i18n.Default)
+	s.Require().NoError(err)
+	notValid := &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "REQUEST_NOT_VALID", Title: internal.ErrRequestNotValid.Error()}
+
+	tests := []struct {
+		name                string
+		mealID              string
+		query               string
+		expectedIngredients []string
+		expectedServings    int
+		expectedKcal        int
+		expectedKcalTotal   int
+		expectedResp        *internal.ErrorResponse
+		expectedStatusCode  int
+		wantErr             bool
+	}{
+		{
+			name:                "[001] The kcal of a serving are computed from the weights (ok)",
+			mealID:              risotto.Id,
+			expectedIngredients: ingredients,
+			expectedServings:    3,
+			expectedKcal:        295,
+			expectedKcalTotal:   885,
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:                "[002] A meal without servings has one (ok)",
+			mealID:              "01FN3EEB2NVFJAHAPM00000001",
+			expectedIngredients: []string{"Tomate", "Queso", "Pollo"},
+			expectedServings:    1,
+			expectedKcal:        130,
+			expectedKcalTotal:   130,
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:   "[003] Get the meal scaled to other servings (ok)",
+			mealID: risotto.Id,
+			query:  "?servings=4",
+			expectedIngredients: []string{
+				"330 g de Arroz blanco",
+				"3 Huevo entero",
+				"1 taza de Leche entera",
+				"1.5 cucharadas de Sal",
+				"Pimienta",
+			},
+			expectedServings:   4,
+			expectedKcal:       295,
+			expectedKcalTotal:  1180,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "[004] The quantities are never scaled to nothing (ok)",
+			mealID: risotto.Id,
+			query:  "?servings=1",
+			expectedIngredients: []string{
+				"85 g de Arroz blanco",
+				"0.5 Huevo entero",
+				"0.25 tazas de Leche entera",
+				"0.5 cucharadas de Sal",
+				"Pimienta",
+			},
+			expectedServings:   1,
+			expectedKcal:       295,
+			expectedKcalTotal:  295,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                "[005] The meal saved is not scaled (ok)",
+			mealID:              risotto.Id,
+			expectedIngredients: ingredients,
+			expectedServings:    3,
+			expectedKcal:        295,
+			expectedKcalTotal:   885,
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:               "[006] No servings (ko)",
+			mealID:             risotto.Id,
+			query:              "?servings=0",
+			expectedResp:       notValid,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[007] Too many servings (ko)",
+			mealID:             risotto.Id,
+			query:              "?servings=101",
+			expectedResp:       notValid,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[008] Servings not a number (ko)",
+			mealID:             risotto.Id,
+			query:              "?servings=dos",
+			expectedResp:       notValid,
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(api.GetMealHandler, testRequest{method: http.MethodGet, target: internal.RouteMealID + t.query, params: []string{userID, t.mealID}})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			meal := new(models.Meal)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), meal))
+			s.Equal(t.expectedIngredients, meal.Ingredients)
+			s.Equal(t.expectedServings, meal.Servings)
+			s.Equal(t.expectedKcal, meal.Kcal)
+			s.Equal(t.expectedKcalTotal, meal.KcalTotal)
+		})
+	}
+}
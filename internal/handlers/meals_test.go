@@ -5,11 +5,13 @@ import (
 	"github.com/json-iterator/go"
 	"github.com/labstack/echo/v4"
 	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"meals/internal"
 	"meals/internal/managers"
 	"meals/internal/models"
 	"meals/internal/repositories"
+	"meals/pkg/blob"
 	"meals/pkg/database"
 	"net/http"
 	"net/http/httptest"
@@ -32,10 +34,13 @@ func TestMealAPITestSuite(t *testing.T) {
 func (s *MealAPITestSuite) SetupTest() {
 	s.httpMock = &internal.EndpointsMock{}
 	managers.Microservices = s.httpMock
+	managers.Images = blob.NewLocal(s.T().TempDir())
 	_ = database.RemoveDB(databaseTest)
-	s.db = database.InitDB(databaseTest)
-	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000001", "01FN3EEB2NVFJAHAPU00000001", "pizza", "", "", "ocasional", "Tomate,Queso,Pollo", 130, "invierno,verano")
-	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000002", "01FN3EEB2NVFJAHAPU00000001", "ensalada", "", "", "semanal", "Tomate,Lechuga,Cebolla,Aguacate", 100, "general")
+	db, err := database.InitDB(databaseTest)
+	s.Require().NoError(err)
+	s.db = db
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000001", "01FN3EEB2NVFJAHAPU00000001", "pizza", "", "", "ocasional", "Tomate|Queso|Pollo", 130, "invierno,verano")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000002", "01FN3EEB2NVFJAHAPU00000001", "ensalada", "", "", "semanal", "Tomate|Lechuga|Cebolla|Aguacate", 100, "general")
 }
 
 func (s *MealAPITestSuite) TearDownTest() {
@@ -43,6 +48,69 @@ func (s *MealAPITestSuite) TearDownTest() {
 	_ = database.RemoveDB(databaseTest)
 }
 
+// testRequest is a request sent by the tests to a handler. Bodies other than []byte are sent as JSON,
+// the encoded ones along with their content type in the headers
+type testRequest struct {
+	method string
+	target string
+	// params are the values of the user_id, id and size path params, as many as the route has
+	params  []string
+	headers map[string]string
+	body    interface{}
+}
+
+// newAPI returns the API with the meal manager of the database of the test
+func (s *MealAPITestSuite) newAPI() *MealAPI {
+	return &MealAPI{DB: *s.db, Manager: managers.NewMealManager(*s.db)}
+}
+
+// request sends the request to the handler, returning the response recorded and the error of the handler
+func (s *MealAPITestSuite) request(handler echo.HandlerFunc, r testRequest) (*httptest.ResponseRecorder, error) {
+	var body []byte
+	switch reqBody := r.body.(type) {
+	case nil:
+	case []byte:
+		body = reqBody
+	default:
+		var err error
+		body, err = jsoniter.Marshal(reqBody)
+		s.Require().NoError(err)
+	}
+	req := httptest.NewRequest(r.method, r.target, bytes.NewReader(body))
+	if _, encoded := r.body.([]byte); r.body != nil && !encoded {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for name, value := range r.headers {
+		req.Header.Set(name, value)
+	}
+	resp := httptest.NewRecorder()
+	c := echo.New().NewContext(req, resp)
+	c.SetParamNames([]string{internal.ParamUserID, internal.ParamMealID, internal.ParamSize}[:len(r.params)]...)
+	c.SetParamValues(r.params...)
+	return resp, handler(c)
+}
+
+// assertProblem checks the response is the problem+json document expected, leaving out the fields that
+// change on every request. The fields that failed the validation are only checked when expected
+func (s *MealAPITestSuite) assertProblem(resp *httptest.ResponseRecorder, expected interface{}) {
+	expectedProblem, ok := expected.(*internal.ErrorResponse)
+	s.Require().True(ok)
+	s.Equal(internal.MIMEApplicationProblemJSON, resp.Header().Get(echo.HeaderContentType))
+
+	problem := new(internal.ErrorResponse)
+	s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), problem))
+	s.Equal(expectedProblem.Status, problem.Status)
+	s.Equal(expectedProblem.Code, problem.Code)
+	s.Equal(expectedProblem.Title, problem.Title)
+	s.Equal("urn:amc:meals:"+expectedProblem.Code, problem.Type)
+	s.NotEmpty(problem.Detail)
+	s.NotEmpty(problem.CorrelationID)
+	s.Equal(problem.CorrelationID, resp.Header().Get(echo.HeaderXRequestID))
+	if expectedProblem.Errors != nil {
+		s.Equal(expectedProblem.Errors, problem.Errors)
+	}
+}
+
 func (s *MealAPITestSuite) TestPostMealHandler() {
 	tests := []struct {
 		name               string
@@ -73,7 +141,13 @@ func (s *MealAPITestSuite) TestPostMealHandler() {
 				Type:        "ocasional",
 				Ingredients: []string{"Patata frita", "Huevo frito"},
 				Kcal:        208,
+				KcalTotal:   208,
 				Seasons:     []string{"general"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
+				Allergens:   []string{"huevos"},
+				Diets:       []string{},
 			},
 			expectedStatusCode: http.StatusCreated,
 			wantErr:            false,
@@ -88,13 +162,16 @@ func (s *MealAPITestSuite) TestPostMealHandler() {
 				Type:        "ocasional",
 				Ingredients: []string{"Patata frita, Huevo frito"},
 				Kcal:        320,
+				KcalTotal:   320,
 				Seasons:     []string{"general"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
 			},
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrWrongBody.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -104,10 +181,9 @@ func (s *MealAPITestSuite) TestPostMealHandler() {
 			userId:  "01FN3EEB2NVFJAHAPU00000001",
 			reqBody: "invalid",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrWrongBody.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -115,10 +191,9 @@ func (s *MealAPITestSuite) TestPostMealHandler() {
 		{
 			name: "[004] User id not present (400)",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrUserIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "USER_ID_NOT_PRESENT",
+				Title:  internal.ErrUserIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -135,10 +210,9 @@ func (s *MealAPITestSuite) TestPostMealHandler() {
 				Seasons:     []string{"general"},
 			},
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusConflict,
-					Message: internal.ErrMealAlreadyExist.Error(),
-				},
+				Status: http.StatusConflict,
+				Code:   "MEAL_ALREADY_EXISTS",
+				Title:  internal.ErrMealAlreadyExist.Error(),
 			},
 			expectedStatusCode: http.StatusConflict,
 			wantErr:            true,
@@ -171,11 +245,7 @@ func (s *MealAPITestSuite) TestPostMealHandler() {
 				s.Equal(t.wantErr, err != nil)
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
-				body := resp.Body.Bytes()
-
-				errorReturned := new(internal.ErrorResponse)
-				s.NoError(jsoniter.Unmarshal(body, errorReturned))
-				s.Equal(errorReturned, t.expectedResp)
+				s.assertProblem(resp, t.expectedResp)
 			} else {
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
@@ -197,6 +267,7 @@ func (s *MealAPITestSuite) TestGetMealHandler() {
 		name               string
 		userID             string
 		mealID             string
+		ifNoneMatch        string
 		expectedResp       interface{}
 		expectedStatusCode int
 		wantErr            bool
@@ -213,31 +284,41 @@ func (s *MealAPITestSuite) TestGetMealHandler() {
 				Type:        "ocasional",
 				Ingredients: []string{"Tomate", "Queso", "Pollo"},
 				Kcal:        130,
+				KcalTotal:   130,
 				Seasons:     []string{"invierno", "verano"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
 			},
 			expectedStatusCode: http.StatusOK,
 			wantErr:            false,
 		},
 		{
-			name:   "[002] Get meal, userId not indicated (400)",
+			name:               "[002] Get meal not modified since the version indicated (304)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			mealID:             "01FN3EEB2NVFJAHAPM00000001",
+			ifNoneMatch:        `"1"`,
+			expectedStatusCode: http.StatusNotModified,
+			wantErr:            false,
+		},
+		{
+			name:   "[003] Get meal, userId not indicated (400)",
 			mealID: "01FN3EEB2NVFJAHAPM00000001",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrUserIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "USER_ID_NOT_PRESENT",
+				Title:  internal.ErrUserIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
 		},
 		{
-			name:   "[003] Get meal, mealId not indicated (400)",
+			name:   "[004] Get meal, mealId not indicated (400)",
 			userID: "01FN3EEB2NVFJAHAPU00000001",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrMealIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "MEAL_ID_NOT_PRESENT",
+				Title:  internal.ErrMealIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -247,19 +328,21 @@ func (s *MealAPITestSuite) TestGetMealHandler() {
 			userID: "01FN3EEB2NVFJAHAPU00000001",
 			mealID: "01FN3EEB2NVFJAHAPM00000099",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusNotFound,
-					Message: internal.ErrMealNotFound.Error(),
-				},
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  internal.ErrMealNotFound.Error(),
 			},
 			expectedStatusCode: http.StatusNotFound,
 			wantErr:            true,
 		},
 	}
-	getEchoContext := func(userId, mealId string) echo.Context {
+	getEchoContext := func(userId, mealId, ifNoneMatch string) echo.Context {
 		e := echo.New()
 		req := httptest.NewRequest(http.MethodGet, internal.RouteMealID, nil)
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if ifNoneMatch != "" {
+			req.Header.Set(internal.HeaderIfNoneMatch, ifNoneMatch)
+		}
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames(internal.ParamUserID, internal.ParamMealID)
@@ -271,19 +354,15 @@ func (s *MealAPITestSuite) TestGetMealHandler() {
 			userManager := managers.NewMealManager(*s.db)
 			api := MealAPI{DB: *s.db, Manager: userManager}
 
-			c := getEchoContext(t.userID, t.mealID)
+			c := getEchoContext(t.userID, t.mealID, t.ifNoneMatch)
 			err := api.GetMealHandler(c)
 
 			if t.wantErr {
 				s.Equal(t.wantErr, err != nil)
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
-				body := resp.Body.Bytes()
-
-				errorReturned := new(internal.ErrorResponse)
-				s.NoError(jsoniter.Unmarshal(body, errorReturned))
-				s.Equal(errorReturned, t.expectedResp)
-			} else {
+				s.assertProblem(resp, t.expectedResp)
+			} else if t.expectedResp != nil {
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
 				body := resp.Body.Bytes()
@@ -291,6 +370,7 @@ func (s *MealAPITestSuite) TestGetMealHandler() {
 				actualMeal := new(models.Meal)
 				s.NoError(jsoniter.Unmarshal(body, actualMeal))
 				s.Equal(actualMeal, t.expectedResp)
+				s.Equal(`"1"`, resp.Header().Get(internal.HeaderETag))
 			}
 
 			s.Equal(t.expectedStatusCode, c.Response().Status)
@@ -320,7 +400,11 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "ocasional",
 					Ingredients: []string{"Tomate", "Queso", "Pollo"},
 					Kcal:        130,
+					KcalTotal:   130,
 					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 				{
 					Id:          "01FN3EEB2NVFJAHAPM00000002",
@@ -330,7 +414,11 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "semanal",
 					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
 					Kcal:        100,
+					KcalTotal:   100,
 					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 			},
 			expectedStatusCode: http.StatusOK,
@@ -351,7 +439,11 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "ocasional",
 					Ingredients: []string{"Tomate", "Queso", "Pollo"},
 					Kcal:        130,
+					KcalTotal:   130,
 					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 			},
 			expectedStatusCode: http.StatusOK,
@@ -372,7 +464,11 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "semanal",
 					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
 					Kcal:        100,
+					KcalTotal:   100,
 					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 			},
 			expectedStatusCode: http.StatusOK,
@@ -393,7 +489,11 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "semanal",
 					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
 					Kcal:        100,
+					KcalTotal:   100,
 					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 				{
 					Id:          "01FN3EEB2NVFJAHAPM00000001",
@@ -403,7 +503,11 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "ocasional",
 					Ingredients: []string{"Tomate", "Queso", "Pollo"},
 					Kcal:        130,
+					KcalTotal:   130,
 					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 			},
 			expectedStatusCode: http.StatusOK,
@@ -424,7 +528,50 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "ocasional",
 					Ingredients: []string{"Tomate", "Queso", "Pollo"},
 					Kcal:        130,
+					KcalTotal:   130,
+					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000002",
+					Name:        "ensalada",
+					Description: "",
+					Image:       "",
+					Type:        "semanal",
+					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
+					Kcal:        100,
+					KcalTotal:   100,
+					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name: "List meals filtered by season[] (ok)",
+			filters: map[string][]string{
+				"season[]": {"verano"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &[]models.Meal{
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000001",
+					Name:        "pizza",
+					Description: "",
+					Image:       "",
+					Type:        "ocasional",
+					Ingredients: []string{"Tomate", "Queso", "Pollo"},
+					Kcal:        130,
+					KcalTotal:   130,
 					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 				{
 					Id:          "01FN3EEB2NVFJAHAPM00000002",
@@ -434,19 +581,182 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 					Type:        "semanal",
 					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
 					Kcal:        100,
+					KcalTotal:   100,
 					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name: "List meals filtered by any of the ingredients (ok)",
+			filters: map[string][]string{
+				"ingredients_any": {"Pollo", "Aguacate"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &[]models.Meal{
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000001",
+					Name:        "pizza",
+					Description: "",
+					Image:       "",
+					Type:        "ocasional",
+					Ingredients: []string{"Tomate", "Queso", "Pollo"},
+					Kcal:        130,
+					KcalTotal:   130,
+					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000002",
+					Name:        "ensalada",
+					Description: "",
+					Image:       "",
+					Type:        "semanal",
+					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
+					Kcal:        100,
+					KcalTotal:   100,
+					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name: "List meals with all the ingredients and without others (ok)",
+			filters: map[string][]string{
+				"ingredients_all":  {"tomate", "cebolla"},
+				"ingredients_none": {"Queso"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &[]models.Meal{
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000002",
+					Name:        "ensalada",
+					Description: "",
+					Image:       "",
+					Type:        "semanal",
+					Ingredients: []string{"Tomate", "Lechuga", "Cebolla", "Aguacate"},
+					Kcal:        100,
+					KcalTotal:   100,
+					Seasons:     []string{"general"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name: "List meals filtered by kcal range (ok)",
+			filters: map[string][]string{
+				"kcal_min": {"110"},
+				"kcal_max": {"200"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &[]models.Meal{
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000001",
+					Name:        "pizza",
+					Description: "",
+					Image:       "",
+					Type:        "ocasional",
+					Ingredients: []string{"Tomate", "Queso", "Pollo"},
+					Kcal:        130,
+					KcalTotal:   130,
+					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
 				},
 			},
 			expectedStatusCode: http.StatusOK,
 			wantErr:            false,
 		},
+		{
+			name: "List meals filtered by type sorted by healthy (ok)",
+			filters: map[string][]string{
+				"type":    {"ocasional"},
+				"healthy": {"true"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &[]models.Meal{
+				{
+					Id:          "01FN3EEB2NVFJAHAPM00000001",
+					Name:        "pizza",
+					Description: "",
+					Image:       "",
+					Type:        "ocasional",
+					Ingredients: []string{"Tomate", "Queso", "Pollo"},
+					Kcal:        130,
+					KcalTotal:   130,
+					Seasons:     []string{"invierno", "verano"},
+					Servings:    1,
+					Steps:       []models.Step{},
+					Equipment:   []string{},
+				},
+			},
+			expectedStatusCode: http.StatusOK,
+			wantErr:            false,
+		},
+		{
+			name: "List meals, ingredients match whole ingredients (404)",
+			filters: map[string][]string{
+				"ingredients_all": {"Tomat"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEALS_NOT_FOUND",
+				Title:  internal.ErrMealsNotFound.Error(),
+			},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name: "List meals, filters are not part of the query (404)",
+			filters: map[string][]string{
+				"name": {"' OR 1=1 --"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusNotFound,
+				Code:   "MEALS_NOT_FOUND",
+				Title:  internal.ErrMealsNotFound.Error(),
+			},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name: "List meals, kcal range not valid (400)",
+			filters: map[string][]string{
+				"kcal_min": {"200"},
+				"kcal_max": {"100"},
+			},
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "REQUEST_NOT_VALID",
+				Title:  internal.ErrRequestNotValid.Error(),
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
 		{
 			name: "List meals, userId not indicated (400)",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrUserIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "USER_ID_NOT_PRESENT",
+				Title:  internal.ErrUserIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -479,11 +789,7 @@ func (s *MealAPITestSuite) TestListMealsHandler() {
 				s.Equal(t.wantErr, err != nil)
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
-				body := resp.Body.Bytes()
-
-				errorReturned := new(internal.ErrorResponse)
-				s.NoError(jsoniter.Unmarshal(body, errorReturned))
-				s.Equal(errorReturned, t.expectedResp)
+				s.assertProblem(resp, t.expectedResp)
 			} else {
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
@@ -504,15 +810,17 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 		name               string
 		userID             string
 		mealID             string
+		ifMatch            string
 		reqBody            interface{}
 		expectedResp       interface{}
 		expectedStatusCode int
 		wantErr            bool
 	}{
 		{
-			name:   "Update meal (ok)",
-			userID: "01FN3EEB2NVFJAHAPU00000001",
-			mealID: "01FN3EEB2NVFJAHAPM00000001",
+			name:    "Update meal (ok)",
+			userID:  "01FN3EEB2NVFJAHAPU00000001",
+			mealID:  "01FN3EEB2NVFJAHAPM00000001",
+			ifMatch: `"1"`,
 			reqBody: &models.Meal{
 				Name:        "pizza margarita",
 				Description: "",
@@ -529,7 +837,13 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 				Type:        "ocasional",
 				Ingredients: []string{"Tomate", "Queso"},
 				Kcal:        0,
+				KcalTotal:   0,
 				Seasons:     []string{"invierno", "verano"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
+				Allergens:   []string{"lacteos"},
+				Diets:       []string{},
 			},
 			expectedStatusCode: http.StatusOK,
 			wantErr:            false,
@@ -538,10 +852,9 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 			name:   "Update meal, userId not indicated (400)",
 			mealID: "01FN3EEB2NVFJAHAPM00000001",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrUserIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "USER_ID_NOT_PRESENT",
+				Title:  internal.ErrUserIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -550,18 +863,18 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 			name:   "Update meal, mealId not indicated (400)",
 			userID: "01FN3EEB2NVFJAHAPU00000001",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrMealIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "MEAL_ID_NOT_PRESENT",
+				Title:  internal.ErrMealIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
 		},
 		{
-			name:   "Update meal that does not exist (404)",
-			userID: "01FN3EEB2NVFJAHAPU00000001",
-			mealID: "01FN3EEB2NVFJAHAPM00000099",
+			name:    "Update meal that does not exist (404)",
+			userID:  "01FN3EEB2NVFJAHAPU00000001",
+			mealID:  "01FN3EEB2NVFJAHAPM00000099",
+			ifMatch: `"1"`,
 			reqBody: &models.Meal{
 				Id:          "01FN3EEB2NVFJAHAPM00000099",
 				UserId:      "01FN3EEB2NVFJAHAPU00000001",
@@ -571,13 +884,16 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 				Type:        "ocasional",
 				Ingredients: []string{"Tomate", "Queso"},
 				Kcal:        100,
+				KcalTotal:   100,
 				Seasons:     []string{"invierno"},
+				Servings:    1,
+				Steps:       []models.Step{},
+				Equipment:   []string{},
 			},
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusNotFound,
-					Message: internal.ErrMealNotFound.Error(),
-				},
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  internal.ErrMealNotFound.Error(),
 			},
 			expectedStatusCode: http.StatusNotFound,
 			wantErr:            true,
@@ -586,24 +902,87 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 			name:    "Update meal wrong body (400)",
 			userID:  "01FN3EEB2NVFJAHAPU00000001",
 			mealID:  "01FN3EEB2NVFJAHAPM00000001",
+			ifMatch: `"1"`,
 			reqBody: "invalid",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrWrongBody.Error(),
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:    "Update meal with an outdated version (412)",
+			userID:  "01FN3EEB2NVFJAHAPU00000001",
+			mealID:  "01FN3EEB2NVFJAHAPM00000001",
+			ifMatch: `"7"`,
+			reqBody: &models.Meal{
+				Name:        "pizza barbacoa",
+				Type:        "ocasional",
+				Ingredients: []string{"Tomate", "Queso"},
+				Seasons:     []string{"invierno"},
+			},
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusPreconditionFailed,
+				Code:   "MEAL_VERSION_MISMATCH",
+				Title:  internal.ErrMealVersionMismatch.Error(),
+			},
+			expectedStatusCode: http.StatusPreconditionFailed,
+			wantErr:            true,
+		},
+		{
+			name:    "Update meal that does not pass the validation reports every field (400)",
+			userID:  "01FN3EEB2NVFJAHAPU00000001",
+			mealID:  "01FN3EEB2NVFJAHAPM00000001",
+			ifMatch: `"1"`,
+			reqBody: &models.Meal{
+				Name:        "pizza barbacoa",
+				Type:        "diario",
+				Ingredients: []string{"Tomate", "Queso"},
+				Seasons:     []string{"invierno", "siempre"},
+			},
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
+				Errors: []models.FieldError{
+					{Field: "type", Code: "oneof", Message: "debe ser uno de: semanal, ocasional, normal"},
+					{Field: "seasons[1]", Code: "oneof", Message: "debe ser uno de: primavera, verano, otoño, invierno, general"},
 				},
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
 		},
+		{
+			name:   "Update meal without If-Match (428)",
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			mealID: "01FN3EEB2NVFJAHAPM00000001",
+			reqBody: &models.Meal{
+				Name:        "pizza barbacoa",
+				Type:        "ocasional",
+				Ingredients: []string{"Tomate", "Queso"},
+				Seasons:     []string{"invierno"},
+			},
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusPreconditionRequired,
+				Code:   "IF_MATCH_REQUIRED",
+				Title:  internal.ErrIfMatchNotPresent.Error(),
+			},
+			expectedStatusCode: http.StatusPreconditionRequired,
+			wantErr:            true,
+		},
 	}
-	getEchoContext := func(userId, mealId string, request interface{}) echo.Context {
+	getEchoContext := func(userId, mealId, ifMatch string, request interface{}) echo.Context {
 		var body []byte
 		body, err := jsoniter.Marshal(request)
 		s.NoError(err)
 		e := echo.New()
 		req := httptest.NewRequest(http.MethodPut, internal.RouteMealID, bytes.NewBuffer(body))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if ifMatch != "" {
+			req.Header.Set(internal.HeaderIfMatch, ifMatch)
+		}
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames(internal.ParamUserID, internal.ParamMealID)
@@ -617,21 +996,24 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 			if _, ok := t.reqBody.(*models.Meal); ok {
 				meal := t.reqBody.(*models.Meal)
 				meal.Id = t.mealID
-				s.httpMock.On("GetCalendar", t.userID, *meal, false).Return(nil).Once()
+				meal.Version = 2
+				if expected, ok := t.expectedResp.(*models.Meal); ok {
+					// The allergens and the diets are derived from the ingredients
+					meal.Allergens, meal.Diets = expected.Allergens, expected.Diets
+					meal.Servings, meal.KcalTotal = expected.Servings, expected.KcalTotal
+					meal.Steps, meal.Equipment = expected.Steps, expected.Equipment
+				}
+				s.httpMock.On("GetCalendar", mock.Anything, t.userID, *meal, false).Return(nil).Once()
 			}
 
-			c := getEchoContext(t.userID, t.mealID, t.reqBody)
+			c := getEchoContext(t.userID, t.mealID, t.ifMatch, t.reqBody)
 			err := api.PutMealHandler(c)
 
 			if t.wantErr {
 				s.Equal(t.wantErr, err != nil)
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
-				body := resp.Body.Bytes()
-
-				errorReturned := new(internal.ErrorResponse)
-				s.NoError(jsoniter.Unmarshal(body, errorReturned))
-				s.Equal(errorReturned, t.expectedResp)
+				s.assertProblem(resp, t.expectedResp)
 			} else {
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
@@ -640,6 +1022,7 @@ func (s *MealAPITestSuite) TestPutMealHandler() {
 				actualMeal := new(models.Meal)
 				s.NoError(jsoniter.Unmarshal(body, actualMeal))
 				s.Equal(actualMeal, t.expectedResp)
+				s.Equal(`"2"`, resp.Header().Get(internal.HeaderETag))
 			}
 
 			s.Equal(t.expectedStatusCode, c.Response().Status)
@@ -652,6 +1035,7 @@ func (s *MealAPITestSuite) TestDeleteMealHandler() {
 		name               string
 		userID             string
 		mealID             string
+		ifMatch            string
 		expectedResp       interface{}
 		expectedStatusCode int
 		wantErr            bool
@@ -660,6 +1044,7 @@ func (s *MealAPITestSuite) TestDeleteMealHandler() {
 			name:               "[001] Delete meal (ok)",
 			userID:             "01FN3EEB2NVFJAHAPU00000001",
 			mealID:             "01FN3EEB2NVFJAHAPM00000001",
+			ifMatch:            `"1"`,
 			expectedStatusCode: http.StatusNoContent,
 			wantErr:            false,
 		},
@@ -667,10 +1052,9 @@ func (s *MealAPITestSuite) TestDeleteMealHandler() {
 			name:   "[002] Delete meal, userId not indicated (400)",
 			mealID: "01FN3EEB2NVFJAHAPM00000001",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrUserIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "USER_ID_NOT_PRESENT",
+				Title:  internal.ErrUserIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
@@ -679,32 +1063,59 @@ func (s *MealAPITestSuite) TestDeleteMealHandler() {
 			name:   "[003] Delete meal, mealId not indicated (400)",
 			userID: "01FN3EEB2NVFJAHAPU00000001",
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusBadRequest,
-					Message: internal.ErrMealIDNotPresent.Error(),
-				},
+				Status: http.StatusBadRequest,
+				Code:   "MEAL_ID_NOT_PRESENT",
+				Title:  internal.ErrMealIDNotPresent.Error(),
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			wantErr:            true,
 		},
 		{
-			name:   "[005] Meal does not exist (404)",
-			userID: "01FN3EEB2NVFJAHAPU00000001",
-			mealID: "01FN3EEB2NVFJAHAPM00000099",
+			name:    "[005] Meal does not exist (404)",
+			userID:  "01FN3EEB2NVFJAHAPU00000001",
+			mealID:  "01FN3EEB2NVFJAHAPM00000099",
+			ifMatch: `"1"`,
 			expectedResp: &internal.ErrorResponse{
-				Err: internal.ErrorBody{
-					Status:  http.StatusNotFound,
-					Message: internal.ErrMealNotFound.Error(),
-				},
+				Status: http.StatusNotFound,
+				Code:   "MEAL_NOT_FOUND",
+				Title:  internal.ErrMealNotFound.Error(),
 			},
 			expectedStatusCode: http.StatusNotFound,
 			wantErr:            true,
 		},
+		{
+			name:    "[006] Delete meal with an outdated version (412)",
+			userID:  "01FN3EEB2NVFJAHAPU00000001",
+			mealID:  "01FN3EEB2NVFJAHAPM00000002",
+			ifMatch: `"3"`,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusPreconditionFailed,
+				Code:   "MEAL_VERSION_MISMATCH",
+				Title:  internal.ErrMealVersionMismatch.Error(),
+			},
+			expectedStatusCode: http.StatusPreconditionFailed,
+			wantErr:            true,
+		},
+		{
+			name:   "[007] Delete meal without If-Match (428)",
+			userID: "01FN3EEB2NVFJAHAPU00000001",
+			mealID: "01FN3EEB2NVFJAHAPM00000002",
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusPreconditionRequired,
+				Code:   "IF_MATCH_REQUIRED",
+				Title:  internal.ErrIfMatchNotPresent.Error(),
+			},
+			expectedStatusCode: http.StatusPreconditionRequired,
+			wantErr:            true,
+		},
 	}
-	getEchoContext := func(userId, mealId string) echo.Context {
+	getEchoContext := func(userId, mealId, ifMatch string) echo.Context {
 		e := echo.New()
 		req := httptest.NewRequest(http.MethodDelete, internal.RouteMealID, nil)
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if ifMatch != "" {
+			req.Header.Set(internal.HeaderIfMatch, ifMatch)
+		}
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames(internal.ParamUserID, internal.ParamMealID)
@@ -716,19 +1127,15 @@ func (s *MealAPITestSuite) TestDeleteMealHandler() {
 			userManager := managers.NewMealManager(*s.db)
 			api := MealAPI{DB: *s.db, Manager: userManager}
 
-			s.httpMock.On("GetCalendar", t.userID, models.Meal{Id: t.mealID}, true).Return(nil).Once()
-			c := getEchoContext(t.userID, t.mealID)
+			s.httpMock.On("GetCalendar", mock.Anything, t.userID, models.Meal{Id: t.mealID}, true).Return(nil).Once()
+			c := getEchoContext(t.userID, t.mealID, t.ifMatch)
 			err := api.DeleteMealHandler(c)
 
 			if t.wantErr {
 				s.Equal(t.wantErr, err != nil)
 				resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
 				s.True(ok)
-				body := resp.Body.Bytes()
-
-				errorReturned := new(internal.ErrorResponse)
-				s.NoError(jsoniter.Unmarshal(body, errorReturned))
-				s.Equal(errorReturned, t.expectedResp)
+				s.assertProblem(resp, t.expectedResp)
 			}
 			s.Equal(t.expectedStatusCode, c.Response().Status)
 		})
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"io"
+	"meals/internal"
+	"meals/internal/formats"
+	"meals/internal/models"
+	"meals/pkg/logging"
+	"meals/pkg/url"
+	"net/http"
+	"strings"
+)
+
+// maxImportSize is the biggest file of meals accepted by the import
+const maxImportSize = 10 << 20
+
+func (a *MealAPI) ExportMealsHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	filter := &models.MealsExportFilter{Format: formats.JSON}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, filter); err != nil {
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+	}
+	format, ok := formats.Get(filter.Format)
+	if !ok || format.NewEncoder == nil {
+		return internal.NewErrorResponse(c, internal.ErrFormatNotSupported)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, format.ContentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="meals`+format.Extension+`"`)
+	res.WriteHeader(http.StatusOK)
+
+	encoder := format.NewEncoder(res)
+	err := a.Manager.ExportMeals(c.Request().Context(), userID, func(meal *models.Meal) error {
+		cleanMeal(meal)
+		if err := encoder.Encode(meal); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		// The response is already being streamed, so the export is just cut
+		logging.FromContext(c.Request().Context()).Error("exporting the meals", "user_id", userID, "error", err)
+		return err
+	}
+	return encoder.Close()
+}
+
+func (a *MealAPI) ImportMealsHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	filter := &models.MealsImportFilter{}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, filter); err != nil {
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+	}
+
+	var (
+		body   io.Reader
+		format formats.Format
+		ok     bool
+	)
+	// Bigger files are rejected instead of imported in part. The form can take up to 1 MB besides the file
+	req := c.Request()
+	multipartForm := strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm)
+	limit := int64(maxImportSize)
+	if multipartForm {
+		limit += 1 << 20
+	}
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+	if multipartForm {
+		fileHeader, err := c.FormFile("file")
+		if isTooLarge(err) {
+			return internal.NewErrorResponse(c, internal.ErrFileTooLarge)
+		}
+		if err != nil {
+			return internal.NewErrorResponse(c, internal.ErrWrongBody)
+		}
+		if fileHeader.Size > maxImportSize {
+			return internal.NewErrorResponse(c, internal.ErrFileTooLarge)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return internal.NewErrorResponse(c, internal.ErrWrongBody)
+		}
+		defer file.Close()
+		body = file
+		format, ok = formats.FromFilename(fileHeader.Filename)
+	} else {
+		body = req.Body
+		format, ok = formats.FromContentType(req.Header.Get(echo.HeaderContentType))
+	}
+	if filter.Format != "" {
+		format, ok = formats.Get(filter.Format)
+	}
+	if !ok || format.Decode == nil {
+		return internal.NewErrorResponse(c, internal.ErrFormatNotSupported)
+	}
+
+	content, err := io.ReadAll(body)
+	if isTooLarge(err) {
+		return internal.NewErrorResponse(c, internal.ErrFileTooLarge)
+	}
+	if err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	rows, err := format.Decode(bytes.NewReader(content))
+	if err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	report, err := a.Manager.ImportMeals(req.Context(), userID, rows, filter.OnConflict)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	report.Format = format.Name
+
+	for i := range report.Items {
+		report.Items[i].Error = internal.LocalizeItemError(c, report.Items[i].Error)
+		report.Items[i].Warning = internal.LocalizeItemError(c, report.Items[i].Warning)
+	}
+
+	status := http.StatusOK
+	if report.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	return c.JSON(status, report)
+}
+
+// isTooLarge reports if err comes from a body bigger than the limit of http.MaxBytesReader
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"meals/internal"
+	"meals/internal/managers"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (s *MealAPITestSuite) TestExportMealsHandler() {
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000003", "01FN3EEB2NVFJAHAPU00000003", "=HIPERVINCULO(\"https://example.com\")", "", "", "semanal", "@Tomate|Queso", 100, "general")
+	tests := []struct {
+		name                string
+		userID              string
+		format              string
+		expectedContentType string
+		expectedBody        string
+		expectedStatusCode  int
+		wantErr             bool
+	}{
+		{
+			name:                "[001] Export meals as JSON by default (ok)",
+			userID:              "01FN3EEB2NVFJAHAPU00000001",
+			expectedContentType: "application/json",
+			expectedBody: `[
+{"id":"01FN3EEB2NVFJAHAPM00000002","name":"ensalada","description":"","image":"","type":"semanal","ingredients":["Tomate","Lechuga","Cebolla","Aguacate"],"kcal":100,"seasons":["general"],"servings":1},
+{"id":"01FN3EEB2NVFJAHAPM00000001","name":"pizza","description":"","image":"","type":"ocasional","ingredients":["Tomate","Queso","Pollo"],"kcal":130,"seasons":["invierno","verano"],"servings":1}
+]
+`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                "[002] Export meals as CSV (ok)",
+			userID:              "01FN3EEB2NVFJAHAPU00000001",
+			format:              "csv",
+			expectedContentType: "text/csv",
+			expectedBody: "id,name,description,image,type,ingredients,kcal,seasons,servings,prep_time,cook_time,difficulty,equipment,steps\n" +
+				"01FN3EEB2NVFJAHAPM00000002,ensalada,,,semanal,Tomate|Lechuga|Cebolla|Aguacate,100,general,1,0,0,,,\n" +
+				"01FN3EEB2NVFJAHAPM00000001,pizza,,,ocasional,Tomate|Queso|Pollo,130,invierno|verano,1,0,0,,,\n",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                "[003] Export of a user without meals is an empty YAML list (ok)",
+			userID:              "01FN3EEB2NVFJAHAPU00000002",
+			format:              "yaml",
+			expectedContentType: "application/yaml",
+			expectedBody:        "[]\n",
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:               "[004] Unknown format (400)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			format:             "xml",
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:                "[005] Cells a spreadsheet would run as formulas are escaped in CSV (ok)",
+			userID:              "01FN3EEB2NVFJAHAPU00000003",
+			format:              "csv",
+			expectedContentType: "text/csv",
+			expectedBody: "id,name,description,image,type,ingredients,kcal,seasons,servings,prep_time,cook_time,difficulty,equipment,steps\n" +
+				"01FN3EEB2NVFJAHAPM00000003,\"'=HIPERVINCULO(\"\"https://example.com\"\")\",,,semanal,'@Tomate|Queso,100,general,1,0,0,,,\n",
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	getEchoContext := func(userId, format string) echo.Context {
+		e := echo.New()
+		target := internal.RouteMealExport
+		if format != "" {
+			target += "?format=" + format
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames(internal.ParamUserID)
+		c.SetParamValues(userId)
+		return c
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			mealManager := managers.NewMealManager(*s.db)
+			api := MealAPI{DB: *s.db, Manager: mealManager}
+
+			c := getEchoContext(t.userID, t.format)
+			err := api.ExportMealsHandler(c)
+
+			resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
+			s.True(ok)
+			if t.wantErr {
+				s.Equal(t.wantErr, err != nil)
+			} else {
+				s.NoError(err)
+				s.Equal(t.expectedContentType, resp.Header().Get(echo.HeaderContentType))
+				s.Equal(t.expectedBody, resp.Body.String())
+			}
+			s.Equal(t.expectedStatusCode, c.Response().Status)
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestImportMealsHandler() {
+	csvFile := "name,type,ingredients,seasons\n" +
+		"gazpacho,normal,Tomates|Pepino|Pimiento,verano\n" +
+		"pizza,ocasional,Tomates|Queso mozzarella,general\n" +
+		"sin tipo,,Tomates,general\n"
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000004", "01FN3EEB2NVFJAHAPU00000004", "salmorejo", "", "", "normal", "Tomates", 80, "verano")
+	tests := []struct {
+		name               string
+		userID             string
+		contentType        string
+		query              string
+		reqBody            string
+		calendarErr        error
+		expectedStatuses   []string
+		expectedNames      []string
+		expectedWarnings   []string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "[001] Import CSV skipping existing meals (207)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			contentType:        "text/csv",
+			reqBody:            csvFile,
+			expectedStatuses:   []string{models.ImportStatusCreated, models.ImportStatusSkipped, models.ImportStatusFailed},
+			expectedNames:      []string{"gazpacho", "pizza", "sin tipo"},
+			expectedStatusCode: http.StatusMultiStatus,
+		},
+		{
+			name:               "[002] Import CSV renaming existing meals (207)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			contentType:        "text/csv",
+			query:              "?on_conflict=rename",
+			reqBody:            csvFile,
+			expectedStatuses:   []string{models.ImportStatusRenamed, models.ImportStatusRenamed, models.ImportStatusFailed},
+			expectedNames:      []string{"gazpacho (2)", "pizza (2)", "sin tipo"},
+			expectedStatusCode: http.StatusMultiStatus,
+		},
+		{
+			name:               "[003] Import JSON overwriting existing meals (ok)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			contentType:        "application/octet-stream",
+			query:              "?format=json&on_conflict=overwrite",
+			reqBody:            `[{"name":"ensalada","type":"normal","ingredients":["Lechuga"],"seasons":["general"]}]`,
+			expectedStatuses:   []string{models.ImportStatusUpdated},
+			expectedNames:      []string{"ensalada"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[004] Import YAML (ok)",
+			userID:             "01FN3EEB2NVFJAHAPU00000002",
+			contentType:        "application/x-yaml",
+			reqBody:            "- name: tortilla\n  type: semanal\n  ingredients: [Huevo entero, Patatas fritas]\n  seasons: [general]\n",
+			expectedStatuses:   []string{models.ImportStatusCreated},
+			expectedNames:      []string{"tortilla"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[005] Unknown format (400)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			contentType:        "text/plain",
+			reqBody:            "pizza",
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[006] File over the size limit is rejected, not imported in part (413)",
+			userID:             "01FN3EEB2NVFJAHAPU00000001",
+			contentType:        "text/csv",
+			reqBody:            csvFile + strings.Repeat("tarta,normal,Harina,general\n", maxImportSize/27),
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			wantErr:            true,
+		},
+		{
+			name:               "[007] Import CSV with the cells escaped on export (ok)",
+			userID:             "01FN3EEB2NVFJAHAPU00000002",
+			contentType:        "text/csv",
+			reqBody:            "name,type,ingredients,seasons\n'=SUMA(1;2),semanal,'-Tomates,general\n",
+			expectedStatuses:   []string{models.ImportStatusCreated},
+			expectedNames:      []string{"=SUMA(1;2)"},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[008] Calendar not updated after the import is a warning of a meal updated (ok)",
+			userID:             "01FN3EEB2NVFJAHAPU00000004",
+			contentType:        "application/json",
+			query:              "?on_conflict=overwrite",
+			reqBody:            `[{"name":"Salmorejo","type":"normal","ingredients":["Tomates","Pan de trigo blanco"],"seasons":["verano"]}]`,
+			calendarErr:        internal.ErrorWithExternalAPI,
+			expectedStatuses:   []string{models.ImportStatusUpdated},
+			expectedNames:      []string{"Salmorejo"},
+			expectedWarnings:   []string{"EXTERNAL_API_ERROR"},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	getEchoContext := func(userId, contentType, query, body string) echo.Context {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, internal.RouteMealImport+query, bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames(internal.ParamUserID)
+		c.SetParamValues(userId)
+		return c
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			mealManager := managers.NewMealManager(*s.db)
+			api := MealAPI{DB: *s.db, Manager: mealManager}
+			s.httpMock.On("GetCalendar", mock.Anything, t.userID, mock.Anything, false).Return(t.calendarErr)
+
+			c := getEchoContext(t.userID, t.contentType, t.query, t.reqBody)
+			err := api.ImportMealsHandler(c)
+
+			resp, ok := c.Response().Writer.(*httptest.ResponseRecorder)
+			s.True(ok)
+			if t.wantErr {
+				s.Equal(t.wantErr, err != nil)
+			} else {
+				s.NoError(err)
+				report := new(models.MealImportReport)
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), report))
+				var statuses, names, warnings []string
+				for _, item := range report.Items {
+					statuses = append(statuses, item.Status)
+					names = append(names, item.Name)
+					warning := ""
+					if item.Warning != nil {
+						warning = item.Warning.Code
+					}
+					warnings = append(warnings, warning)
+				}
+				s.Equal(t.expectedStatuses, statuses)
+				s.Equal(t.expectedNames, names)
+				s.Equal(len(t.expectedStatuses), report.Total)
+				if t.expectedWarnings != nil {
+					s.Equal(t.expectedWarnings, warnings)
+					s.Zero(report.Failed)
+				}
+				for _, item := range report.Items {
+					if item.Status == models.ImportStatusFailed {
+						s.Equal("WRONG_BODY", item.Error.Code)
+						s.Equal([]models.FieldError{{Field: "type", Code: "required", Message: "es obligatorio"}}, item.Error.Errors)
+					}
+				}
+			}
+			s.Equal(t.expectedStatusCode, c.Response().Status)
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestRecipeInteroperability() {
+	mealManager := managers.NewMealManager(*s.db)
+	api := MealAPI{DB: *s.db, Manager: mealManager}
+	newContext := func(method, target, contentType string, body []byte, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, target, bytes.NewBuffer(body))
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames(internal.ParamUserID, internal.ParamMealID)
+		c.SetParamValues(params...)
+		return c, rec
+	}
+
+	s.Run("[001] Get meal as a schema.org Recipe (ok)", func() {
+		c, rec := newContext(http.MethodGet, internal.RouteMealID, "", nil, "01FN3EEB2NVFJAHAPU00000001", "01FN3EEB2NVFJAHAPM00000001")
+		c.Request().Header.Set(echo.HeaderAccept, internal.MIMEApplicationLDJSON)
+		s.NoError(api.GetMealHandler(c))
+		s.Equal(internal.MIMEApplicationLDJSON, rec.Header().Get(echo.HeaderContentType))
+		s.JSONEq(`{
+			"@context": "https://schema.org",
+			"@type": "Recipe",
+			"identifier": "01FN3EEB2NVFJAHAPM00000001",
+			"name": "pizza",
+			"recipeCategory": "ocasional",
+			"keywords": "invierno, verano",
+			"recipeYield": "1",
+			"recipeIngredient": ["Tomate", "Queso", "Pollo"],
+			"nutrition": {"@type": "NutritionInformation", "calories": "130 kcal"}
+		}`, rec.Body.String())
+	})
+
+	s.Run("[002] Import the JSON-LD of a recipe page matching the catalog (ok)", func() {
+		page := `<html><head><script type="application/ld+json">
+			{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebPage", "name": "Recetas"},
+				{"@type": ["Recipe"], "name": "Crema de calabacín", "image": {"@type": "ImageObject", "url": "https://example.com/crema.jpg"},
+				 "recipeIngredient": ["2 calabacines", "1 cebolla, picada", "200 ml de leche entera", "Sal al gusto"],
+				 "keywords": "cena, Otoño", "nutrition": {"calories": "250 kcal"}}
+			]}
+		</script></head></html>`
+		c, rec := newContext(http.MethodPost, internal.RouteMealImport+"?format=jsonld", "text/html", []byte(page), "01FN3EEB2NVFJAHAPU00000002")
+		s.NoError(api.ImportMealsHandler(c))
+		s.Equal(http.StatusOK, rec.Code)
+
+		meals, err := mealManager.ListMeals(context.Background(), "01FN3EEB2NVFJAHAPU00000002", &models.MealsFilters{})
+		s.NoError(err)
+		s.Len(meals, 1)
+		s.Equal("Crema de calabacín", meals[0].Name)
+		s.Equal("https://example.com/crema.jpg", meals[0].Image)
+		s.Equal([]string{"Calabacín", "Cebolla", "Leche entera", "Sal al gusto"}, meals[0].Ingredients)
+		s.Equal([]string{"otoño"}, meals[0].Seasons)
+		s.Equal((31+47+68)/4, meals[0].Kcal)
+	})
+
+	s.Run("[003] Paprika export can be imported back (ok)", func() {
+		c, rec := newContext(http.MethodGet, internal.RouteMealExport+"?format=paprika", "", nil, "01FN3EEB2NVFJAHAPU00000001")
+		s.NoError(api.ExportMealsHandler(c))
+		s.Equal("application/zip", rec.Header().Get(echo.HeaderContentType))
+
+		c, rec = newContext(http.MethodPost, internal.RouteMealImport+"?format=paprika", "application/zip", rec.Body.Bytes(), "01FN3EEB2NVFJAHAPU00000003")
+		s.NoError(api.ImportMealsHandler(c))
+		report := new(models.MealImportReport)
+		s.NoError(jsoniter.Unmarshal(rec.Body.Bytes(), report))
+		s.Equal(2, report.Created)
+
+		meal, err := mealManager.ListMeals(context.Background(), "01FN3EEB2NVFJAHAPU00000003", &models.MealsFilters{Name: &[]string{"pizza"}[0]})
+		s.NoError(err)
+		s.Equal("ocasional", meal[0].Type)
+		s.Equal([]string{"invierno", "verano"}, meal[0].Seasons)
+		// The ingredients are matched to the catalog
+		s.Equal([]string{"Tomates", "Queso", "Pollo"}, meal[0].Ingredients)
+	})
+
+	s.Run("[004] Paprika calories are read after their label (ok)", func() {
+		recipe := `{"name": "Lentejas", "ingredients": "Caldo casero", "nutritional_info": "Fat: 12 g\nCalories: 450"}`
+		c, rec := newContext(http.MethodPost, internal.RouteMealImport+"?format=paprika", "application/json", []byte(recipe), "01FN3EEB2NVFJAHAPU00000004")
+		s.NoError(api.ImportMealsHandler(c))
+		s.Equal(http.StatusOK, rec.Code)
+
+		meals, err := mealManager.ListMeals(context.Background(), "01FN3EEB2NVFJAHAPU00000004", &models.MealsFilters{})
+		s.NoError(err)
+		s.Len(meals, 1)
+		s.Equal(450, meals[0].Kcal)
+	})
+
+	s.Run("[005] Each representation of a meal is negotiated and tagged apart (ok)", func() {
+		tags := map[string]string{}
+		for _, accept := range []string{"", "application/ld+json;q=0, application/json", "application/json;q=0.5, application/ld+json", "*/*"} {
+			c, rec := newContext(http.MethodGet, internal.RouteMealID, "", nil, "01FN3EEB2NVFJAHAPU00000001", "01FN3EEB2NVFJAHAPM00000001")
+			c.Request().Header.Set(echo.HeaderAccept, accept)
+			s.NoError(api.GetMealHandler(c))
+			tags[accept] = rec.Header().Get(internal.HeaderETag)
+			if accept == "application/json;q=0.5, application/ld+json" {
+				s.Equal(internal.MIMEApplicationLDJSON, rec.Header().Get(echo.HeaderContentType))
+			} else {
+				s.Contains(rec.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+				s.NotContains(rec.Body.String(), "@context")
+			}
+		}
+		s.Equal(`"1"`, tags[""])
+		s.Equal(`"1"`, tags["*/*"])
+		s.Equal(`"1"`, tags["application/ld+json;q=0, application/json"])
+		s.Equal(`"1-jsonld"`, tags["application/json;q=0.5, application/ld+json"])
+
+		// The tag of one representation does not validate the other one
+		c, rec := newContext(http.MethodGet, internal.RouteMealID, "", nil, "01FN3EEB2NVFJAHAPU00000001", "01FN3EEB2NVFJAHAPM00000001")
+		c.Request().Header.Set(echo.HeaderAccept, internal.MIMEApplicationLDJSON)
+		c.Request().Header.Set(internal.HeaderIfNoneMatch, `"1"`)
+		s.NoError(api.GetMealHandler(c))
+		s.Equal(http.StatusOK, rec.Code)
+	})
+
+	s.Run("[006] Catalog ingredients with commas are read back whole (ok)", func() {
+		recipe := `{"@context": "https://schema.org", "@type": "Recipe", "name": "Lomo asado",
+			"recipeIngredient": ["500 g de lomo de cerdo", "2 dientes de ajo, picados"]}`
+		c, rec := newContext(http.MethodPost, internal.RouteMealImport+"?format=jsonld", "application/ld+json", []byte(recipe), "01FN3EEB2NVFJAHAPU00000005")
+		s.NoError(api.ImportMealsHandler(c))
+		s.Equal(http.StatusOK, rec.Code)
+
+		meals, err := mealManager.ListMeals(context.Background(), "01FN3EEB2NVFJAHAPU00000005", &models.MealsFilters{})
+		s.NoError(err)
+		s.Len(meals, 1)
+		c, rec = newContext(http.MethodGet, internal.RouteMealID, "", nil, "01FN3EEB2NVFJAHAPU00000005", meals[0].Id)
+		s.NoError(api.GetMealHandler(c))
+		meal := new(models.Meal)
+		s.NoError(jsoniter.Unmarshal(rec.Body.Bytes(), meal))
+		s.Equal([]string{"Cerdo, lomo", "Ajos"}, meal.Ingredients)
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/url"
+	"net/http"
+)
+
+func (a *MealAPI) ListPantryHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	items, err := a.Manager.ListPantry(c.Request().Context(), userID)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	if items == nil {
+		items = []*models.PantryItem{}
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (a *MealAPI) GetPantryItemHandler(c echo.Context) error {
+	var userID, itemID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+		internal.ParamItemID: {Target: &itemID, Err: internal.ErrPantryItemIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	item, err := a.Manager.GetPantryItem(c.Request().Context(), userID, itemID)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, item)
+}
+
+func (a *MealAPI) PostPantryItemHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	itemFront := &models.PantryItem{}
+	if err := c.Bind(itemFront); err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	item, err := a.Manager.CreatePantryItem(c.Request().Context(), userID, *itemFront)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusCreated, item)
+}
+
+func (a *MealAPI) PutPantryItemHandler(c echo.Context) error {
+	var userID, itemID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+		internal.ParamItemID: {Target: &itemID, Err: internal.ErrPantryItemIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	itemFront := &models.PantryItem{}
+	if err := c.Bind(itemFront); err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	item, err := a.Manager.UpdatePantryItem(c.Request().Context(), userID, itemID, *itemFront)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, item)
+}
+
+func (a *MealAPI) DeletePantryItemHandler(c echo.Context) error {
+	var userID, itemID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+		internal.ParamItemID: {Target: &itemID, Err: internal.ErrPantryItemIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	if err := a.Manager.DeletePantryItem(c.Request().Context(), userID, itemID); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (a *MealAPI) CookableMealsHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	filter := &models.CookableFilter{}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, filter); err != nil {
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+	}
+	meals, err := a.Manager.CookableMeals(c.Request().Context(), userID, *filter)
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+	for _, meal := range meals {
+		cleanMeal(meal.Meal)
+	}
+	return c.JSON(http.StatusOK, meals)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+const pantryUserID = "01FN3EEB2NVFJAHAPU00000001"
+
+// addPantryItem adds an item to the pantry of the user and returns it
+func (s *MealAPITestSuite) addPantryItem(item models.PantryItem) *models.PantryItem {
+	resp, err := s.request(s.newAPI().PostPantryItemHandler, testRequest{method: http.MethodPost, target: internal.RoutePantry, params: []string{pantryUserID}, body: item})
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+	created := new(models.PantryItem)
+	s.Require().NoError(jsoniter.Unmarshal(resp.Body.Bytes(), created))
+	return created
+}
+
+// inDays returns the date of the day the days indicated after today
+func inDays(days int) string {
+	return time.Now().AddDate(0, 0, days).Format(models.PlanDateLayout)
+}
+
+func (s *MealAPITestSuite) TestPantryHandlers() {
+	api := s.newAPI()
+	s.addPantryItem(models.PantryItem{Ingredient: "sal"})
+	s.addPantryItem(models.PantryItem{Ingredient: "Lechuga", ExpiresOn: inDays(1)})
+	// tomatoesID is the id of the item added by the first test
+	tomatoesID, missingID := "", "01FN3EEB2NVFJAHAPI00000099"
+	notFound := &internal.ErrorResponse{Status: http.StatusNotFound, Code: "PANTRY_ITEM_NOT_FOUND", Title: internal.ErrPantryItemNotFound.Error()}
+
+	tests := []struct {
+		name               string
+		method             string
+		itemID             *string
+		reqBody            interface{}
+		handler            echo.HandlerFunc
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+		// check checks the response, when there is no error
+		check func(resp *httptest.ResponseRecorder)
+	}{
+		{
+			name:               "[001] Add an ingredient with its name in the catalog (ok)",
+			method:             http.MethodPost,
+			reqBody:            models.PantryItem{Ingredient: " tomate ", Quantity: "1 kg", ExpiresOn: inDays(5)},
+			handler:            api.PostPantryItemHandler,
+			expectedStatusCode: http.StatusCreated,
+			check: func(resp *httptest.ResponseRecorder) {
+				tomatoes := new(models.PantryItem)
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), tomatoes))
+				s.NotEmpty(tomatoes.Id)
+				s.Equal("Tomates", tomatoes.Ingredient)
+				s.Equal("Verduras", tomatoes.Category)
+				s.Equal("1 kg", tomatoes.Quantity)
+				tomatoesID = tomatoes.Id
+			},
+		},
+		{
+			name:               "[002] List the pantry, the items expiring sooner first (ok)",
+			method:             http.MethodGet,
+			handler:            api.ListPantryHandler,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				var items []*models.PantryItem
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &items))
+				s.Require().Len(items, 3)
+				s.Equal("Lechuga", items[0].Ingredient)
+				s.Equal("Tomates", items[1].Ingredient)
+				s.Equal("sal", items[2].Ingredient)
+				s.Empty(items[2].Category)
+			},
+		},
+		{
+			name:               "[003] Update an item (ok)",
+			method:             http.MethodPut,
+			itemID:             &tomatoesID,
+			reqBody:            models.PantryItem{Ingredient: "Tomates", Quantity: "500 g"},
+			handler:            api.PutPantryItemHandler,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "[004] Get an item (ok)",
+			method:             http.MethodGet,
+			itemID:             &tomatoesID,
+			handler:            api.GetPantryItemHandler,
+			expectedStatusCode: http.StatusOK,
+			check: func(resp *httptest.ResponseRecorder) {
+				item := new(models.PantryItem)
+				s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), item))
+				s.Equal(models.PantryItem{Id: tomatoesID, Ingredient: "Tomates", Quantity: "500 g", Category: "Verduras"}, *item)
+			},
+		},
+		{
+			name:               "[005] Delete an item (ok)",
+			method:             http.MethodDelete,
+			itemID:             &tomatoesID,
+			handler:            api.DeletePantryItemHandler,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "[006] Get an item deleted (ko)",
+			method:             http.MethodGet,
+			itemID:             &tomatoesID,
+			handler:            api.GetPantryItemHandler,
+			expectedResp:       notFound,
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:               "[007] Ingredient already in the pantry (ko)",
+			method:             http.MethodPost,
+			reqBody:            models.PantryItem{Ingredient: "lechugas"},
+			handler:            api.PostPantryItemHandler,
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusConflict, Code: "PANTRY_ITEM_ALREADY_EXISTS", Title: internal.ErrPantryItemAlreadyExist.Error()},
+			expectedStatusCode: http.StatusConflict,
+			wantErr:            true,
+		},
+		{
+			name:    "[008] Expiry date not valid (ko)",
+			method:  http.MethodPost,
+			reqBody: models.PantryItem{Ingredient: "Cebolla", ExpiresOn: "mañana"},
+			handler: api.PostPantryItemHandler,
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
+				Errors: []models.FieldError{{Field: "expires_on", Code: "datetime", Message: "debe ser una fecha con el formato AAAA-MM-DD"}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[009] Update an item not found (ko)",
+			method:             http.MethodPut,
+			itemID:             &missingID,
+			reqBody:            models.PantryItem{Ingredient: "Cebolla"},
+			handler:            api.PutPantryItemHandler,
+			expectedResp:       notFound,
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:               "[010] Delete an item not found (ko)",
+			method:             http.MethodDelete,
+			itemID:             &missingID,
+			handler:            api.DeletePantryItemHandler,
+			expectedResp:       notFound,
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			params := []string{pantryUserID}
+			if t.itemID != nil {
+				params = append(params, *t.itemID)
+			}
+			resp, err := s.request(t.handler, testRequest{method: t.method, target: internal.RoutePantry, params: params, body: t.reqBody})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			if t.check != nil {
+				t.check(resp)
+			}
+		})
+	}
+}
+
+func (s *MealAPITestSuite) TestCookableMealsHandler() {
+	s.addPantryItem(models.PantryItem{Ingredient: "Tomate", ExpiresOn: inDays(2)})
+	s.addPantryItem(models.PantryItem{Ingredient: "Lechuga"})
+	s.addPantryItem(models.PantryItem{Ingredient: "Cebolla", ExpiresOn: inDays(10)})
+	s.addPantryItem(models.PantryItem{Ingredient: "Pollo", ExpiresOn: inDays(1)})
+	s.addPantryItem(models.PantryItem{Ingredient: "Queso", ExpiresOn: inDays(-1)})
+
+	tests := []struct {
+		name               string
+		query              string
+		expectedResp       *internal.ErrorResponse
+		expectedStatusCode int
+		wantErr            bool
+		// check checks the meals ranked, when there is no error
+		check func(meals []models.CookableMeal)
+	}{
+		{
+			name:               "[001] Fewest missing ingredients first, expired items missing (ok)",
+			expectedStatusCode: http.StatusOK,
+			check: func(meals []models.CookableMeal) {
+				s.Require().Len(meals, 2)
+				s.Equal("ensalada", meals[0].Meal.Name)
+				s.Equal([]string{"Tomate", "Lechuga", "Cebolla"}, meals[0].Available)
+				s.Equal([]string{"Aguacate"}, meals[0].Missing)
+				s.Equal(0.75, meals[0].Coverage)
+				s.Empty(meals[0].Meal.UserId)
+				s.Equal("pizza", meals[1].Meal.Name)
+				s.Equal([]string{"Queso"}, meals[1].Missing)
+				s.Empty(meals[1].Expiring)
+			},
+		},
+		{
+			name:               "[002] Meals using the items about to expire first (ok)",
+			query:              "?expiring_within=3",
+			expectedStatusCode: http.StatusOK,
+			check: func(meals []models.CookableMeal) {
+				s.Require().Len(meals, 2)
+				s.Equal("pizza", meals[0].Meal.Name)
+				s.Equal([]string{"Tomate", "Pollo"}, meals[0].Expiring)
+				s.Equal("ensalada", meals[1].Meal.Name)
+				s.Equal([]string{"Tomate"}, meals[1].Expiring)
+			},
+		},
+		{
+			name:               "[003] Meals missing more ingredients left out (ok)",
+			query:              "?max_missing=0",
+			expectedStatusCode: http.StatusOK,
+			check: func(meals []models.CookableMeal) {
+				s.Empty(meals)
+			},
+		},
+		{
+			name:               "[004] Negative window (ko)",
+			query:              "?expiring_within=-1",
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "REQUEST_NOT_VALID", Title: internal.ErrRequestNotValid.Error()},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			resp, err := s.request(s.newAPI().CookableMealsHandler, testRequest{method: http.MethodGet, target: internal.RouteMealCookable + t.query, params: []string{pantryUserID}})
+			s.Equal(t.expectedStatusCode, resp.Code, resp.Body.String())
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			var meals []models.CookableMeal
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), &meals))
+			t.check(meals)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/internal"
+	"meals/internal/formats"
+	"meals/internal/models"
+	"meals/pkg/url"
+	"net/http"
+)
+
+func (a *MealAPI) ShoppingListHandler(c echo.Context) error {
+	var userID string
+	if err := url.ParseURLPath(c, url.PathMap{
+		internal.ParamUserID: {Target: &userID, Err: internal.ErrUserIDNotPresent},
+	}); err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	filter := &models.ShoppingListFilter{}
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, filter); err != nil {
+		return internal.NewErrorResponse(c, internal.WithCause(internal.ErrRequestNotValid, err))
+	}
+	var format formats.ShoppingListFormat
+	if filter.Format != "" && filter.Format != formats.JSON {
+		var ok bool
+		if format, ok = formats.GetShoppingListFormat(filter.Format); !ok {
+			return internal.NewErrorResponse(c, internal.ErrFormatNotSupported)
+		}
+	}
+
+	request := &models.ShoppingListRequest{}
+	if err := c.Bind(request); err != nil {
+		return internal.NewErrorResponse(c, internal.ErrWrongBody)
+	}
+	list, err := a.Manager.ShoppingList(c.Request().Context(), userID, *request, internal.Language(c))
+	if err != nil {
+		return internal.NewErrorResponse(c, err)
+	}
+
+	if format.Write == nil {
+		return c.JSON(http.StatusOK, list)
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, format.ContentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="shopping-list`+format.Extension+`"`)
+	res.WriteHeader(http.StatusOK)
+	return format.Write(res, list)
+}
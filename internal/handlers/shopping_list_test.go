@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/json-iterator/go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/mock"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"net/http"
+)
+
+// shoppingItem returns the item of the ingredient in the list, along with the key of its category
+func shoppingItem(list *models.ShoppingList, key string) (string, *models.ShoppingListItem) {
+	for _, category := range list.Categories {
+		for i := range category.Items {
+			if category.Items[i].Key == key {
+				return category.Key, &category.Items[i]
+			}
+		}
+	}
+	return "", nil
+}
+
+func (s *MealAPITestSuite) TestShoppingListHandler() {
+	const userID = "01FN3EEB2NVFJAHAPU00000001"
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000003", userID, "pisto", "", "", "normal", "200 g de calabacín|2 huevos|Tomates", 200, "verano")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000004", userID, "crema", "", "", "normal", "0.5 kg calabacines|1 l leche entera", 150, "general")
+	s.db.Conn.Exec(repositories.CreateMeal, "01FN3EEB2NVFJAHAPM00000005", userID, "=1+1", "", "", "normal", "@Pimienta", 10, "general")
+	exported := &models.ShoppingListRequest{MealIds: []string{"01FN3EEB2NVFJAHAPM00000001", "01FN3EEB2NVFJAHAPM00000003"}}
+
+	tests := []struct {
+		name                string
+		query               string
+		acceptLanguage      string
+		reqBody             *models.ShoppingListRequest
+		calendar            []models.Calendar
+		calendarErr         error
+		expectedContentType string
+		expectedLines       []string
+		expectedResp        *internal.ErrorResponse
+		expectedStatusCode  int
+		wantErr             bool
+		// check checks the list in JSON, when there is no error
+		check func(list *models.ShoppingList)
+	}{
+		{
+			name: "[001] Ingredients of the meals without repeats, grouped by category (ok)",
+			reqBody: &models.ShoppingListRequest{MealIds: []string{
+				"01FN3EEB2NVFJAHAPM00000001", "01FN3EEB2NVFJAHAPM00000002", "01FN3EEB2NVFJAHAPM00000001",
+				"01FN3EEB2NVFJAHAPM00000003", "01FN3EEB2NVFJAHAPM00000004",
+			}},
+			expectedStatusCode: http.StatusOK,
+			check: func(list *models.ShoppingList) {
+				s.Equal("es", list.Language)
+				s.Equal([]models.ShoppingListMeal{
+					{Id: "01FN3EEB2NVFJAHAPM00000001", Name: "pizza", Times: 2},
+					{Id: "01FN3EEB2NVFJAHAPM00000002", Name: "ensalada", Times: 1},
+					{Id: "01FN3EEB2NVFJAHAPM00000003", Name: "pisto", Times: 1},
+					{Id: "01FN3EEB2NVFJAHAPM00000004", Name: "crema", Times: 1},
+				}, list.Meals)
+
+				category, tomatoes := shoppingItem(list, "Tomates")
+				s.Equal("Verduras", category)
+				s.Require().NotNil(tomatoes)
+				s.Equal(4, tomatoes.Times)
+				s.Equal([]string{"pizza", "ensalada", "pisto"}, tomatoes.Meals)
+				s.Empty(tomatoes.Quantities)
+
+				category, zucchini := shoppingItem(list, "Calabacín")
+				s.Equal("Verduras", category)
+				s.Require().NotNil(zucchini)
+				s.Equal([]models.ShoppingQuantity{{Amount: 700, Unit: "g"}}, zucchini.Quantities)
+
+				_, milk := shoppingItem(list, "Leche entera")
+				s.Require().NotNil(milk)
+				s.Equal([]models.ShoppingQuantity{{Amount: 1000, Unit: "ml"}}, milk.Quantities)
+
+				category, avocado := shoppingItem(list, "Aguacate")
+				s.Equal(models.ShoppingCategoryOther, category)
+				s.NotNil(avocado)
+				s.Equal(models.ShoppingCategoryOther, list.Categories[len(list.Categories)-1].Key)
+			},
+		},
+		{
+			name:           "[002] Meals of the calendar between the dates, in English (ok)",
+			acceptLanguage: "en",
+			reqBody:        &models.ShoppingListRequest{From: "2024-01-08", To: "2024-01-14"},
+			calendar: []models.Calendar{
+				{MealId: "01FN3EEB2NVFJAHAPM00000002", Date: "2024-01-07T00:00:00Z"},
+				{MealId: "01FN3EEB2NVFJAHAPM00000002", Date: "2024-01-08T00:00:00Z"},
+				{MealId: "01FN3EEB2NVFJAHAPM00000099", Date: "2024-01-09T00:00:00Z"},
+				{MealId: "01FN3EEB2NVFJAHAPM00000002", Date: "2024-01-10"},
+				{MealId: "01FN3EEB2NVFJAHAPM00000001", Date: "2024-01-15"},
+			},
+			expectedStatusCode: http.StatusOK,
+			check: func(list *models.ShoppingList) {
+				s.Equal("en", list.Language)
+				s.Equal([]models.ShoppingListMeal{{Id: "01FN3EEB2NVFJAHAPM00000002", Name: "ensalada", Times: 2}}, list.Meals)
+				_, lettuce := shoppingItem(list, "Lechuga")
+				s.Require().NotNil(lettuce)
+				s.Equal("Lettuce", lettuce.Name)
+				s.Equal(2, lettuce.Times)
+				s.Equal("Vegetables", list.Categories[len(list.Categories)-2].Name)
+				s.Equal("Other", list.Categories[len(list.Categories)-1].Name)
+			},
+		},
+		{
+			name:                "[003] Export as plain text (ok)",
+			query:               "?format=text",
+			reqBody:             exported,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedLines:       []string{"Verduras\n", "- Calabacín: 200 g (pisto)\n", "- Tomates (pizza, pisto)\n"},
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:                "[004] Export as Markdown (ok)",
+			query:               "?format=markdown",
+			reqBody:             exported,
+			expectedContentType: "text/markdown; charset=utf-8",
+			expectedLines:       []string{"## Verduras\n\n", "- [ ] Calabacín: 200 g _(pisto)_\n"},
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:                "[005] Export as CSV (ok)",
+			query:               "?format=csv",
+			reqBody:             exported,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedLines:       []string{"category,ingredient,quantity,times,meals\n", "Verduras,Tomates,,2,pizza|pisto\n", "Otros,huevos,2,1,pisto\n"},
+			expectedStatusCode:  http.StatusOK,
+		},
+		{
+			name:               "[006] Neither meals nor dates (ko)",
+			reqBody:            &models.ShoppingListRequest{},
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "WRONG_BODY", Title: internal.ErrWrongBody.Error()},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:    "[007] Start without end (ko)",
+			reqBody: &models.ShoppingListRequest{From: "2024-01-08"},
+			expectedResp: &internal.ErrorResponse{
+				Status: http.StatusBadRequest,
+				Code:   "WRONG_BODY",
+				Title:  internal.ErrWrongBody.Error(),
+				Errors: []models.FieldError{{Field: "to", Code: "required_with", Message: "no es válido"}},
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[008] Meal not found (ko)",
+			reqBody:            &models.ShoppingListRequest{MealIds: []string{"01FN3EEB2NVFJAHAPM00000099"}},
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusNotFound, Code: "MEAL_NOT_FOUND", Title: internal.ErrMealNotFound.Error()},
+			expectedStatusCode: http.StatusNotFound,
+			wantErr:            true,
+		},
+		{
+			name:               "[009] Format not supported (ko)",
+			query:              "?format=pdf",
+			reqBody:            &models.ShoppingListRequest{MealIds: []string{"01FN3EEB2NVFJAHAPM00000001"}},
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusBadRequest, Code: "FORMAT_NOT_SUPPORTED", Title: internal.ErrFormatNotSupported.Error()},
+			expectedStatusCode: http.StatusBadRequest,
+			wantErr:            true,
+		},
+		{
+			name:               "[010] Calendar not available (ko)",
+			reqBody:            &models.ShoppingListRequest{From: "2024-01-08", To: "2024-01-14"},
+			calendarErr:        errors.New("connection refused"),
+			expectedResp:       &internal.ErrorResponse{Status: http.StatusInternalServerError, Code: "EXTERNAL_API_ERROR", Title: internal.ErrorWithExternalAPI.Error()},
+			expectedStatusCode: http.StatusInternalServerError,
+			wantErr:            true,
+		},
+		{
+			name:               "[011] Cells a spreadsheet would run as formulas are escaped in CSV (ok)",
+			query:              "?format=csv",
+			reqBody:            &models.ShoppingListRequest{MealIds: []string{"01FN3EEB2NVFJAHAPM00000005"}},
+			expectedLines:      []string{"category,ingredient,quantity,times,meals\nOtros,'@Pimienta,,1,'=1+1\n"},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+	for _, t := range tests {
+		s.Run(t.name, func() {
+			if t.calendar != nil || t.calendarErr != nil {
+				s.httpMock.On("ListCalendar", mock.Anything, userID).Return(t.calendar, t.calendarErr).Once()
+			}
+			headers := map[string]string{}
+			if t.acceptLanguage != "" {
+				headers[internal.HeaderAcceptLanguage] = t.acceptLanguage
+			}
+			resp, err := s.request(s.newAPI().ShoppingListHandler, testRequest{
+				method:  http.MethodPost,
+				target:  internal.RouteShoppingList + t.query,
+				params:  []string{userID},
+				headers: headers,
+				body:    t.reqBody,
+			})
+			s.Equal(t.expectedStatusCode, resp.Code)
+			if t.wantErr {
+				s.Error(err)
+				s.assertProblem(resp, t.expectedResp)
+				return
+			}
+			s.NoError(err)
+			if t.check == nil {
+				if t.expectedContentType != "" {
+					s.Equal(t.expectedContentType, resp.Header().Get(echo.HeaderContentType))
+				}
+				for _, line := range t.expectedLines {
+					s.Contains(resp.Body.String(), line)
+				}
+				return
+			}
+			list := new(models.ShoppingList)
+			s.NoError(jsoniter.Unmarshal(resp.Body.Bytes(), list))
+			t.check(list)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/language"
+	"path"
+	"strings"
+)
+
+const (
+	Spanish = "es"
+	English = "en"
+
+	// Default is the language of the stored keys, used when the client does not ask for a supported one
+	Default = Spanish
+)
+
+// Groups of messages of the bundles
+const (
+	GroupErrors      = "errors"
+	GroupFields      = "fields"
+	GroupTypes       = "types"
+	GroupSeasons     = "seasons"
+	GroupCategories  = "categories"
+	GroupIngredients = "ingredients"
+	GroupAllergens   = "allergens"
+	GroupDiets       = "diets"
+	GroupDetails     = "details"
+	GroupConflicts   = "conflicts"
+)
+
+// Languages are the supported languages, the first one is the default
+var Languages = []string{Spanish, English}
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// bundles are the messages of every language by group and key
+var bundles = map[string]map[string]map[string]string{}
+
+var matcher language.Matcher
+
+func init() {
+	tags := make([]language.Tag, 0, len(Languages))
+	for _, lang := range Languages {
+		data, err := locales.ReadFile(path.Join("locales", lang+".json"))
+		if err != nil {
+			panic(err)
+		}
+		bundle := map[string]map[string]string{}
+		if err = json.Unmarshal(data, &bundle); err != nil {
+			panic(fmt.Sprintf("i18n: bundle %s: %v", lang, err))
+		}
+		bundles[lang] = bundle
+		tags = append(tags, language.Make(lang))
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+// Negotiate returns the supported language that best matches an Accept-Language header
+func Negotiate(acceptLanguage string) string {
+	if strings.TrimSpace(acceptLanguage) == "" {
+		return Default
+	}
+	preferred, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(preferred) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(preferred...)
+	if confidence == language.No {
+		return Default
+	}
+	return Languages[index]
+}
+
+// Message returns the message of a key in the language indicated, formatted with the args. Missing messages
+// fall back to the default language and then to the key itself
+func Message(lang, group, key string, args ...any) string {
+	message, ok := Lookup(lang, group, key)
+	if !ok {
+		message = strings.TrimSpace(key)
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Lookup returns the message of a key in the language indicated or, when missing, in the default one
+func Lookup(lang, group, key string) (string, bool) {
+	if message, ok := bundles[lang][group][key]; ok {
+		return message, true
+	}
+	message, ok := bundles[Default][group][key]
+	return message, ok
+}
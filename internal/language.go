@@ -0,0 +1,21 @@
+package internal
+
+import (
+	"github.com/labstack/echo/v4"
+	"meals/internal/i18n"
+)
+
+const contextKeyLanguage = "language"
+
+// Language returns the language negotiated with the Accept-Language header of the request. It is also
+// returned in the Content-Language header of the response
+func Language(c echo.Context) string {
+	if lang, ok := c.Get(contextKeyLanguage).(string); ok {
+		return lang
+	}
+	lang := i18n.Negotiate(c.Request().Header.Get(HeaderAcceptLanguage))
+	c.Set(contextKeyLanguage, lang)
+	c.Response().Header().Set(HeaderContentLanguage, lang)
+	c.Response().Header().Add(echo.HeaderVary, HeaderAcceptLanguage)
+	return lang
+}
@@ -0,0 +1,52 @@
+package managers
+
+import (
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"sort"
+)
+
+// Catalog returns the types, seasons, allergens, diets and ingredients with their display names in the language
+// indicated. Categories and ingredients are sorted by key
+func Catalog(lang string) *models.Catalog {
+	catalog := &models.Catalog{
+		Language:   lang,
+		Types:      catalogEntries(lang, i18n.GroupTypes, models.MealTypes),
+		Seasons:    catalogEntries(lang, i18n.GroupSeasons, models.MealSeasons),
+		Allergens:  catalogEntries(lang, i18n.GroupAllergens, models.MealAllergens),
+		Diets:      catalogEntries(lang, i18n.GroupDiets, models.MealDiets),
+		Categories: make([]models.CatalogCategory, 0, len(models.Ingredients)),
+	}
+	for category, ingredients := range models.Ingredients {
+		entry := models.CatalogCategory{
+			Key:         category,
+			Name:        i18n.Message(lang, i18n.GroupCategories, category),
+			Ingredients: make([]models.CatalogIngredient, 0, len(ingredients)),
+		}
+		for ingredient, kcal := range ingredients {
+			entry.Ingredients = append(entry.Ingredients, models.CatalogIngredient{
+				Key:       ingredient,
+				Name:      i18n.Message(lang, i18n.GroupIngredients, ingredient),
+				Kcal:      kcal,
+				Allergens: append([]string{}, models.IngredientAllergens(category, ingredient)...),
+				Diets:     append([]string{}, models.IngredientDiets(category, ingredient)...),
+			})
+		}
+		sort.Slice(entry.Ingredients, func(i, j int) bool {
+			return entry.Ingredients[i].Key < entry.Ingredients[j].Key
+		})
+		catalog.Categories = append(catalog.Categories, entry)
+	}
+	sort.Slice(catalog.Categories, func(i, j int) bool {
+		return catalog.Categories[i].Key < catalog.Categories[j].Key
+	})
+	return catalog
+}
+
+func catalogEntries(lang, group string, keys []string) []models.CatalogEntry {
+	entries := make([]models.CatalogEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, models.CatalogEntry{Key: key, Name: i18n.Message(lang, group, key)})
+	}
+	return entries
+}
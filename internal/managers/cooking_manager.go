@@ -0,0 +1,38 @@
+package managers
+
+import (
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"strings"
+)
+
+// prepareCooking validates the cooking steps and the equipment of the meal, once its fields are validated, and
+// leaves them without surrounding spaces and the equipment without repeated items. The total time is the sum
+// of the prep and cook times
+func prepareCooking(meal *models.Meal) error {
+	steps := make([]models.Step, 0, len(meal.Steps))
+	for i, step := range meal.Steps {
+		if step.Text = strings.TrimSpace(step.Text); step.Text == "" {
+			return internal.WithDetail(internal.ErrWrongBody, internal.DetailStepEmpty, i+1)
+		}
+		if meal.PrepTime+meal.CookTime > 0 && step.TimerMinutes > meal.PrepTime+meal.CookTime {
+			return internal.WithDetail(internal.ErrWrongBody, internal.DetailStepTimerTooLong, i+1)
+		}
+		steps = append(steps, step)
+	}
+	meal.Steps = steps
+
+	equipment := []string{}
+	seen := map[string]bool{}
+	for _, item := range meal.Equipment {
+		item = strings.TrimSpace(item)
+		if key := text.Fold(item); item != "" && !seen[key] {
+			seen[key] = true
+			equipment = append(equipment, item)
+		}
+	}
+	meal.Equipment = equipment
+	meal.TotalTime = meal.PrepTime + meal.CookTime
+	return nil
+}
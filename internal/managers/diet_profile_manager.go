@@ -0,0 +1,111 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"meals/internal"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"slices"
+	"strings"
+)
+
+func (m *MealManager) GetDietProfile(ctx context.Context, userID string) (*models.DietProfile, error) {
+	return m.profiles.GetDietProfile(ctx, userID)
+}
+
+// PutDietProfile creates or replaces the diet profile of the user. The allergens and the diets are kept in
+// the order of the catalog and the disliked ingredients once, as they are written
+func (m *MealManager) PutDietProfile(ctx context.Context, userID string, profile models.DietProfile) (*models.DietProfile, error) {
+	if err := m.validate.Struct(profile); err != nil {
+		return nil, internal.WrongBody(err)
+	}
+	for _, allergen := range profile.ExcludedAllergens {
+		if !slices.Contains(models.MealAllergens, allergen) {
+			return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailAllergenNotValid, allergen)
+		}
+	}
+	for _, diet := range profile.Diets {
+		if !slices.Contains(models.MealDiets, diet) {
+			return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailDietNotValid, diet)
+		}
+	}
+	profile.ExcludedAllergens = inCatalogOrder(models.MealAllergens, profile.ExcludedAllergens)
+	profile.Diets = inCatalogOrder(models.MealDiets, profile.Diets)
+
+	disliked := []string{}
+	seen := map[string]bool{}
+	for _, ingredient := range profile.DislikedIngredients {
+		ingredient = strings.TrimSpace(ingredient)
+		if key := text.Fold(ingredient); !seen[key] {
+			seen[key] = true
+			disliked = append(disliked, ingredient)
+		}
+	}
+	profile.DislikedIngredients = disliked
+	return m.profiles.PutDietProfile(ctx, userID, profile)
+}
+
+func (m *MealManager) DeleteDietProfile(ctx context.Context, userID string) error {
+	return m.profiles.DeleteDietProfile(ctx, userID)
+}
+
+// ActiveDietProfile returns the diet profile to apply to a request of the user, nil when the user has none
+// or the request ignores it
+func (m *MealManager) ActiveDietProfile(ctx context.Context, userID string, override models.ProfileOverride) (*models.DietProfile, error) {
+	if override.IgnoreProfile {
+		return nil, nil
+	}
+	profile, err := m.profiles.GetDietProfile(ctx, userID)
+	if errors.Is(err, internal.ErrDietProfileNotFound) {
+		return nil, nil
+	}
+	return profile, err
+}
+
+// applyDietProfile adds the diet profile to the filters sent, which can only narrow it
+func applyDietProfile(filters *models.MealsFilters, profile *models.DietProfile) {
+	if profile == nil {
+		return
+	}
+	filters.AllergenFree = append(filters.AllergenFree, profile.ExcludedAllergens...)
+	filters.Diets = append(filters.Diets, profile.Diets...)
+	filters.IngredientsNone = append(filters.IngredientsNone, profile.DislikedIngredients...)
+}
+
+// profileConflicts returns the reasons the meal does not fit the diet profile in the language indicated, if any
+func profileConflicts(lang string, meal *models.Meal, profile *models.DietProfile) []string {
+	if profile == nil {
+		return nil
+	}
+	var conflicts []string
+	for _, allergen := range profile.ExcludedAllergens {
+		if slices.Contains(meal.Allergens, allergen) {
+			conflicts = append(conflicts, i18n.Message(lang, i18n.GroupConflicts, "allergen", allergen))
+		}
+	}
+	for _, diet := range profile.Diets {
+		if !slices.Contains(meal.Diets, diet) {
+			conflicts = append(conflicts, i18n.Message(lang, i18n.GroupConflicts, "diet", diet))
+		}
+	}
+	// The disliked ingredients match as in the filters of the meals listed
+	for _, disliked := range profile.DislikedIngredients {
+		if models.HasIngredient(meal.Ingredients, disliked) {
+			conflicts = append(conflicts, i18n.Message(lang, i18n.GroupConflicts, "ingredient", disliked))
+		}
+	}
+	return conflicts
+}
+
+// inCatalogOrder returns the keys of the catalog among the values, once and in the order of the catalog
+func inCatalogOrder(catalog, values []string) []string {
+	keys := []string{}
+	for _, key := range catalog {
+		if slices.Contains(values, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,116 @@
+package managers
+
+import (
+	"context"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"slices"
+	"strings"
+)
+
+// tagMeal derives the allergens and the diets of the meal from its ingredients. The allergens of the
+// ingredients not in the catalog are found by their words, while the meal is only suitable for the diets
+// when all its ingredients are in the catalog and suitable for them, as the rest can not be checked
+func (m *MealManager) tagMeal(meal *models.Meal) {
+	allergens := map[string]bool{}
+	diets := map[string]bool{}
+	for _, diet := range models.MealDiets {
+		diets[diet] = true
+	}
+	known := 0
+	for _, ingredient := range meal.Ingredients {
+		if strings.TrimSpace(ingredient) == "" {
+			continue
+		}
+		name := ingredient
+		if _, ok := m.allIngredients[name]; !ok {
+			if name, ok = m.matcher.Match(ingredient); !ok {
+				for _, allergen := range wordAllergens(ingredient) {
+					allergens[allergen] = true
+				}
+				diets = map[string]bool{}
+				continue
+			}
+		}
+		known++
+		category := ingredientCategory(name)
+		for _, allergen := range models.IngredientAllergens(category, name) {
+			allergens[allergen] = true
+		}
+		suitable := map[string]bool{}
+		for _, diet := range models.IngredientDiets(category, name) {
+			suitable[diet] = diets[diet]
+		}
+		diets = suitable
+	}
+
+	meal.Allergens = []string{}
+	for _, allergen := range models.MealAllergens {
+		if allergens[allergen] {
+			meal.Allergens = append(meal.Allergens, allergen)
+		}
+	}
+	meal.Diets = []string{}
+	for _, diet := range models.MealDiets {
+		if known > 0 && diets[diet] {
+			meal.Diets = append(meal.Diets, diet)
+		}
+	}
+}
+
+// wordAllergens returns the allergens revealed by the words of an ingredient, in singular or plural
+func wordAllergens(ingredient string) (allergens []string) {
+	words := map[string]bool{}
+	for _, word := range text.Words(ingredient) {
+		words[word] = true
+		words[strings.TrimSuffix(word, "s")] = true
+		words[strings.TrimSuffix(word, "es")] = true
+	}
+	for _, allergen := range models.MealAllergens {
+		for _, word := range models.AllergenWords[allergen] {
+			if words[word] {
+				allergens = append(allergens, allergen)
+				break
+			}
+		}
+	}
+	return allergens
+}
+
+// TagMeals tags the allergens and the diets of the meals created before they were derived, returning
+// how many were tagged
+func (m *MealManager) TagMeals(ctx context.Context) (tagged int, err error) {
+	meals, err := m.db.UntaggedMeals(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, meal := range meals {
+		if err = ctx.Err(); err != nil {
+			return tagged, err
+		}
+		m.tagMeal(meal)
+		// A meal updated meanwhile is skipped, the update tagged it already
+		ok, err := m.db.TagMeal(ctx, *meal)
+		if err != nil {
+			return tagged, err
+		}
+		if ok {
+			tagged++
+		}
+	}
+	return tagged, nil
+}
+
+// validDietaryFilters checks the allergens and the diet to filter by are in the catalog
+func validDietaryFilters(filters *models.MealsFilters) error {
+	for _, allergen := range filters.AllergenFree {
+		if !slices.Contains(models.MealAllergens, allergen) {
+			return internal.WithDetail(internal.ErrRequestNotValid, internal.DetailAllergenNotValid, allergen)
+		}
+	}
+	if filters.Diet != nil && !slices.Contains(models.MealDiets, *filters.Diet) {
+		return internal.WithDetail(internal.ErrRequestNotValid, internal.DetailDietNotValid, *filters.Diet)
+	}
+	return nil
+}
@@ -1,38 +1,56 @@
 package managers
 
 import (
+	"context"
 	"encoding/json"
 	"math/rand"
+	"meals/internal/config"
+	"meals/internal/metrics"
 	"meals/internal/models"
+	"meals/pkg/tracing"
 	"net/http"
+	neturl "net/url"
 )
 
 const (
-	APP_ID  = "e21c0369"
-	APP_KEY = "165f842c901b3ba223f917cbe0a46ca9"
-	ROUTE   = "https://api.edamam.com/api/recipes/v2?type=public"
+	ROUTE = "https://api.edamam.com/api/recipes/v2?type=public"
 )
 
-var httpClient = &http.Client{}
+// edamamHealth are the health labels of the recipes API that leave out the allergens and keep the diets of the catalog
+var edamamHealth = map[string]string{
+	"gluten": "gluten-free", "crustaceos": "crustacean-free", "huevos": "egg-free", "pescado": "fish-free",
+	"cacahuetes": "peanut-free", "soja": "soy-free", "lacteos": "dairy-free", "frutos_de_cascara": "tree-nut-free",
+	"apio": "celery-free", "mostaza": "mustard-free", "sesamo": "sesame-free", "sulfitos": "sulfite-free",
+	"altramuces": "lupine-free", "moluscos": "mollusk-free",
+	"vegetariana": "vegetarian", "vegana": "vegan", "pescetariana": "pescatarian",
+}
+
+// httpClient leaves the credentials of the application out of the spans of the calls
+var httpClient = &http.Client{Transport: tracing.Transport(metrics.ServiceEdamam, metrics.Transport(metrics.ServiceEdamam, nil), "app_id", "app_key")}
 
 type ExternalMealsManager struct {
 }
 
 type IExternalMealsManager interface {
-	ListMeals(query string) ([]models.Meal, error)
+	ListMeals(ctx context.Context, query string, profile *models.DietProfile) ([]models.Meal, error)
 }
 
 func NewExternalMealsManager() *ExternalMealsManager {
 	return &ExternalMealsManager{}
 }
-func (em *ExternalMealsManager) ListMeals(query string) ([]models.Meal, error) {
+
+// ListMeals searches the recipes API, leaving out the recipes that do not fit the diet profile when there is one
+func (em *ExternalMealsManager) ListMeals(ctx context.Context, query string, profile *models.DietProfile) ([]models.Meal, error) {
 	queries := []string{"pollo", "carne", "pasta", "arroz", "tortilla"}
 	url := ROUTE
 	if query == "" {
 		query = queries[rand.Intn(len(queries))]
 	}
-	url += "&q=" + query + "&app_id=" + APP_ID + "&app_key=" + APP_KEY
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	url += "&q=" + query + "&app_id=" + config.Config.EdamamAppID + "&app_key=" + config.Config.EdamamAppKey
+	if params := profileParams(profile); len(params) > 0 {
+		url += "&" + params.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return []models.Meal{}, err
 	}
@@ -51,3 +69,18 @@ func (em *ExternalMealsManager) ListMeals(query string) ([]models.Meal, error) {
 	}
 	return meals, nil
 }
+
+// profileParams returns the parameters of the recipes API that apply the diet profile
+func profileParams(profile *models.DietProfile) neturl.Values {
+	params := neturl.Values{}
+	if profile == nil {
+		return params
+	}
+	for _, key := range append(append([]string{}, profile.ExcludedAllergens...), profile.Diets...) {
+		params.Add("health", edamamHealth[key])
+	}
+	for _, ingredient := range profile.DislikedIngredients {
+		params.Add("excluded", ingredient)
+	}
+	return params
+}
@@ -0,0 +1,128 @@
+package managers
+
+import (
+	"context"
+	"meals/internal/models"
+	"meals/pkg/database"
+	"meals/pkg/tracing"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Dependencies probed by the status endpoint
+const (
+	DependencyCalendars = "calendars"
+	DependencyUsers     = "users"
+	DependencyRecipes   = "recipes"
+	checkDatabase       = "database"
+)
+
+// probeTimeout bounds every check, so a dependency that does not answer can not block the status
+const probeTimeout = 2 * time.Second
+
+// Dependency is a service called by this one
+type Dependency struct {
+	Name string
+	URL  string
+}
+
+type HealthManager struct {
+	db           *database.Database
+	dependencies []Dependency
+	client       *http.Client
+}
+
+type IHealthManager interface {
+	Ready(ctx context.Context) *models.Health
+	Status(ctx context.Context) *models.Health
+}
+
+func NewHealthManager(db *database.Database, dependencies []Dependency) *HealthManager {
+	return &HealthManager{
+		db:           db,
+		dependencies: dependencies,
+		client:       &http.Client{Transport: tracing.Transport("status", nil), Timeout: probeTimeout},
+	}
+}
+
+// Ready checks the database can be reached and all its scripts have been executed
+func (hm *HealthManager) Ready(ctx context.Context) *models.Health {
+	check := hm.checkDatabase(ctx)
+	return newHealth(map[string]models.HealthCheck{checkDatabase: check})
+}
+
+// Status checks the database and the dependencies concurrently. A dependency is up when it answers
+// with a status below 500, whatever the path
+func (hm *HealthManager) Status(ctx context.Context) *models.Health {
+	checks := make(map[string]models.HealthCheck, len(hm.dependencies)+1)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dependency := range hm.dependencies {
+		wg.Add(1)
+		go func(dependency Dependency) {
+			defer wg.Done()
+			check := hm.probe(ctx, dependency.URL)
+			mu.Lock()
+			checks[dependency.Name] = check
+			mu.Unlock()
+		}(dependency)
+	}
+	db := hm.checkDatabase(ctx)
+	wg.Wait()
+	checks[checkDatabase] = db
+	return newHealth(checks)
+}
+
+func (hm *HealthManager) checkDatabase(ctx context.Context) models.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := hm.db.Ping(ctx)
+	var version int
+	if err == nil {
+		version, err = hm.db.CheckVersion(ctx)
+	}
+	check := models.HealthCheck{Status: models.HealthUp, LatencyMs: since(start), Version: version}
+	if err != nil {
+		check.Status, check.Error = models.HealthDown, err.Error()
+	}
+	return check
+}
+
+func (hm *HealthManager) probe(ctx context.Context, url string) models.HealthCheck {
+	check := models.HealthCheck{Status: models.HealthDown, URL: url}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	start := time.Now()
+	response, err := hm.client.Do(request)
+	check.LatencyMs = since(start)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	_ = response.Body.Close()
+	check.HTTPStatus = response.StatusCode
+	if response.StatusCode < http.StatusInternalServerError {
+		check.Status = models.HealthUp
+	}
+	return check
+}
+
+// newHealth returns ok when every check is up, degraded otherwise
+func newHealth(checks map[string]models.HealthCheck) *models.Health {
+	health := &models.Health{Status: models.HealthOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != models.HealthUp {
+			health.Status = models.HealthDegraded
+		}
+	}
+	return health
+}
+
+func since(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
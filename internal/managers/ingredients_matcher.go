@@ -0,0 +1,91 @@
+package managers
+
+import (
+	"meals/pkg/text"
+	"sort"
+	"strings"
+)
+
+// ingredientMatcher finds the ingredient of the catalog a free text line (e.g. "200 g de calabacín") refers to
+type ingredientMatcher struct {
+	aliases []ingredientAlias
+}
+
+type ingredientAlias struct {
+	name  string
+	words string
+}
+
+func newIngredientMatcher(ingredients map[string]int) *ingredientMatcher {
+	matcher := &ingredientMatcher{}
+	for name := range ingredients {
+		for _, alias := range ingredientAliases(name) {
+			matcher.aliases = append(matcher.aliases, ingredientAlias{name: name, words: alias})
+		}
+	}
+	// The longest aliases are tried first, so "Pollo, Hígado" wins over "Pollo"
+	sort.Slice(matcher.aliases, func(i, j int) bool {
+		if len(matcher.aliases[i].words) != len(matcher.aliases[j].words) {
+			return len(matcher.aliases[i].words) > len(matcher.aliases[j].words)
+		}
+		return matcher.aliases[i].words < matcher.aliases[j].words
+	})
+	return matcher
+}
+
+// ingredientAliases returns the ways an ingredient can be written: "Cerdo, lomo" is also "lomo de cerdo",
+// "Tomates" is also "tomate" and "Calabacín" is also "calabacines"
+func ingredientAliases(name string) []string {
+	var aliases []string
+	words := strings.Join(text.Words(name), " ")
+	aliases = append(aliases, words)
+	if parts := strings.SplitN(name, ",", 2); len(parts) == 2 {
+		aliases = append(aliases, strings.Join(text.Words(parts[1]+" de "+parts[0]), " "))
+	}
+	if strings.Contains(words, " ") {
+		return aliases
+	}
+	switch {
+	case strings.HasSuffix(words, "es"):
+		aliases = append(aliases, strings.TrimSuffix(words, "s"), strings.TrimSuffix(words, "es"))
+	case strings.HasSuffix(words, "s"):
+		aliases = append(aliases, strings.TrimSuffix(words, "s"))
+	case strings.ContainsAny(words[len(words)-1:], "aeiou"):
+		aliases = append(aliases, words+"s")
+	default:
+		aliases = append(aliases, words+"es")
+	}
+	return aliases
+}
+
+// Match returns the catalog ingredient mentioned in the line
+func (im *ingredientMatcher) Match(line string) (string, bool) {
+	words := " " + strings.Join(text.Words(line), " ") + " "
+	for _, alias := range im.aliases {
+		if strings.Contains(words, " "+alias.words+" ") {
+			return alias.name, true
+		}
+	}
+	return "", false
+}
+
+// MatchAll maps the lines to the ingredients of the catalog. The lines that do not match are kept
+// without the preparation notes, reporting how many did match
+func (im *ingredientMatcher) MatchAll(lines []string) (ingredients []string, matched int) {
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		ingredient, ok := im.Match(line)
+		if ok {
+			matched++
+		} else {
+			// "2 dientes de ajo, picados": the preparation follows the comma
+			ingredient = strings.TrimSpace(strings.SplitN(line, ",", 2)[0])
+		}
+		if ingredient == "" || seen[ingredient] {
+			continue
+		}
+		seen[ingredient] = true
+		ingredients = append(ingredients, ingredient)
+	}
+	return
+}
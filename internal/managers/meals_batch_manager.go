@@ -0,0 +1,143 @@
+package managers
+
+import (
+	"context"
+	"fmt"
+	"meals/internal"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"meals/pkg/etag"
+	"meals/pkg/logging"
+	"net/http"
+)
+
+// BatchMeals runs the create, update and delete operations of the batch in a single transaction.
+// Every operation runs inside its own savepoint, so in best_effort mode a failure only discards that operation
+// while in atomic mode it rolls back the whole batch
+func (m *MealManager) BatchMeals(ctx context.Context, userID string, batch models.MealBatch) (result *models.MealBatchResult, err error) {
+	if err = m.validate.Struct(batch); err != nil {
+		return nil, internal.WrongBody(err)
+	}
+	if batch.Mode == "" {
+		batch.Mode = models.BatchModeAtomic
+	}
+	result = &models.MealBatchResult{Mode: batch.Mode, Results: make([]models.MealBatchItemResult, len(batch.Operations))}
+	for i, op := range batch.Operations {
+		result.Results[i] = models.MealBatchItemResult{Index: i, Op: op.Op, Id: op.Id}
+	}
+	renamed := make([]bool, len(batch.Operations))
+
+	aborted := false
+	err = m.db.Transaction(ctx, func(tx *repositories.SQLiteMealRepository) error {
+		for i, op := range batch.Operations {
+			item := &result.Results[i]
+			opErr := tx.Savepoint(ctx, fmt.Sprintf("batch_op_%d", i), func() (err error) {
+				renamed[i], err = m.batchOperation(ctx, tx, userID, op, item)
+				return
+			})
+			if opErr == nil {
+				continue
+			}
+			setBatchError(item, opErr)
+			if batch.Mode == models.BatchModeAtomic {
+				aborted = true
+				return opErr
+			}
+		}
+		return nil
+	})
+	if aborted {
+		for i := range result.Results {
+			if item := &result.Results[i]; !item.Failed() {
+				setBatchError(item, internal.ErrBatchAborted)
+			}
+		}
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Committed = true
+
+	// The calendars and the images are only updated once the changes are committed
+	for i, item := range result.Results {
+		if item.Failed() {
+			continue
+		}
+		if item.Op == models.BatchOpDelete {
+			deleteMealImages(ctx, userID, item.Id)
+		}
+		switch {
+		case item.Op == models.BatchOpUpdate && renamed[i]:
+			err = Microservices.GetCalendar(ctx, userID, *item.Meal, false)
+		case item.Op == models.BatchOpDelete:
+			err = Microservices.GetCalendar(ctx, userID, models.Meal{Id: item.Id}, true)
+		default:
+			continue
+		}
+		if err != nil {
+			// The operation is committed already, so it is still reported as succeeded
+			logging.FromContext(ctx).Error("updating the calendar", "user_id", userID, "meal_id", item.Id, "error", err)
+			result.Results[i].Warning = internal.NewItemError(i18n.Default, err)
+		}
+	}
+	return result, nil
+}
+
+// batchOperation runs a single operation of a batch with the repository bound to the transaction
+func (m *MealManager) batchOperation(ctx context.Context, tx *repositories.SQLiteMealRepository, userID string, op models.MealBatchOperation, item *models.MealBatchItemResult) (renamed bool, err error) {
+	var meal *models.Meal
+	switch op.Op {
+	case models.BatchOpCreate:
+		if op.Meal == nil {
+			return false, internal.ErrWrongBody
+		}
+		if meal, err = m.createMeal(ctx, tx, userID, *op.Meal); err != nil {
+			return false, err
+		}
+		item.Status = http.StatusCreated
+	case models.BatchOpUpdate:
+		if err = checkBatchTarget(op); err != nil {
+			return false, err
+		}
+		if op.Meal == nil {
+			return false, internal.ErrWrongBody
+		}
+		mealPut := *op.Meal
+		mealPut.Id = op.Id
+		if meal, renamed, err = m.updateMeal(ctx, tx, userID, op.Id, mealPut, op.IfMatch); err != nil {
+			return false, err
+		}
+		item.Status = http.StatusOK
+	case models.BatchOpDelete:
+		if err = checkBatchTarget(op); err != nil {
+			return false, err
+		}
+		if err = m.deleteMeal(ctx, tx, userID, op.Id, op.IfMatch); err != nil {
+			return false, err
+		}
+		item.Status = http.StatusNoContent
+		return false, nil
+	default:
+		return false, internal.ErrBatchOpNotSupported
+	}
+	item.Id, item.Meal, item.ETag = meal.Id, meal, etag.Version(meal.Version)
+	return renamed, nil
+}
+
+// checkBatchTarget checks an update or delete operation indicates the meal and its version
+func checkBatchTarget(op models.MealBatchOperation) error {
+	if op.Id == "" {
+		return internal.ErrMealIDNotPresent
+	}
+	if op.IfMatch == "" {
+		return internal.ErrIfMatchNotPresent
+	}
+	return nil
+}
+
+func setBatchError(item *models.MealBatchItemResult, err error) {
+	item.Status, item.Meal, item.ETag = internal.ErrorFor(err).Status, nil, ""
+	item.Error = internal.NewItemError(i18n.Default, err)
+}
@@ -0,0 +1,104 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/blob"
+	"meals/pkg/imaging"
+	"meals/pkg/logging"
+	"strings"
+)
+
+// Images keeps the images of the meals and their thumbnails
+var Images blob.Store = blob.NewLocal("./images")
+
+// PutMealImage stores the image of the meal, turned upright and without its metadata, along with its
+// thumbnails. The image of the meal is pointed to the stored one
+func (m *MealManager) PutMealImage(ctx context.Context, userID, mealID string, content []byte) (*models.MealImage, error) {
+	meal, err := m.db.GetMeal(ctx, userID, mealID)
+	if err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(content)
+	switch {
+	case errors.Is(err, imaging.ErrFormatNotSupported):
+		return nil, internal.ErrImageNotSupported
+	case errors.Is(err, imaging.ErrTooManyPixels):
+		return nil, internal.WithDetail(internal.ErrImageTooLarge, internal.DetailImageTooManyPixels)
+	case err != nil:
+		return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailImageNotReadable, err.Error())
+	}
+
+	sides := map[string]int{models.ImageSizeOriginal: models.MaxImageSide}
+	for size, side := range models.ImageSizes {
+		sides[size] = side
+	}
+	for size, side := range sides {
+		encoded, contentType, err := imaging.Encode(imaging.Fit(img, side))
+		if err != nil {
+			logging.FromContext(ctx).Error("encoding the image", "size", size, "error", err)
+			return nil, internal.ErrSomethingWentWrong
+		}
+		if err = Images.Put(ctx, imageKey(userID, mealID, size), blob.Blob{Content: encoded, ContentType: contentType}); err != nil {
+			logging.FromContext(ctx).Error("storing the image", "size", size, "error", err)
+			return nil, internal.ErrSomethingWentWrong
+		}
+	}
+
+	image := mealImage(userID, mealID)
+	if meal.Image != image.Image {
+		meal.Image = image.Image
+		if _, err = m.db.UpdateMeal(ctx, userID, mealID, *meal); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+// GetMealImage returns the image of the meal in the size indicated, the original or one of its thumbnails
+func (m *MealManager) GetMealImage(ctx context.Context, userID, mealID, size string) (*blob.Blob, error) {
+	if _, ok := models.ImageSizes[size]; !ok && size != models.ImageSizeOriginal {
+		return nil, internal.WithDetail(internal.ErrRequestNotValid, internal.DetailImageSizeNotSupported, size)
+	}
+	if _, err := m.db.GetMeal(ctx, userID, mealID); err != nil {
+		return nil, err
+	}
+	image, err := Images.Get(ctx, imageKey(userID, mealID, size))
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, internal.ErrImageNotFound
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("reading the image", "size", size, "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	return image, nil
+}
+
+// deleteMealImages deletes the images of a deleted meal. As the meal is already gone, a failure is only logged
+func deleteMealImages(ctx context.Context, userID, mealID string) {
+	if err := Images.DeleteAll(ctx, imagesDir(userID, mealID)); err != nil {
+		logging.FromContext(ctx).Error("deleting the images of the meal", "user_id", userID, "meal_id", mealID, "error", err)
+	}
+}
+
+// mealImage returns the URLs of the image of the meal and of its thumbnails
+func mealImage(userID, mealID string) *models.MealImage {
+	replacer := strings.NewReplacer(":"+internal.ParamUserID, userID, ":"+internal.ParamMealID, mealID)
+	image := &models.MealImage{Image: replacer.Replace(internal.RouteMealImage), Thumbnails: map[string]string{}}
+	for size := range models.ImageSizes {
+		image.Thumbnails[size] = strings.Replace(replacer.Replace(internal.RouteMealImageSize), ":"+internal.ParamSize, size, 1)
+	}
+	return image
+}
+
+func imagesDir(userID, mealID string) string {
+	return "users/" + userID + "/meals/" + mealID + "/images"
+}
+
+// imageKey is the key of the image of the meal in the size indicated. Keys have no extension, as the
+// images are stored as JPEG or PNG depending on their transparency
+func imageKey(userID, mealID, size string) string {
+	return imagesDir(userID, mealID) + "/" + size
+}
@@ -1,116 +1,312 @@
 package managers
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/evanphx/json-patch/v5"
 	"github.com/go-playground/validator/v10"
+	"math"
 	"meals/internal"
+	"meals/internal/formats"
 	"meals/internal/models"
 	"meals/internal/repositories"
 	"meals/internal/utils"
+	"meals/pkg/blob"
 	"meals/pkg/database"
+	"meals/pkg/etag"
+	"meals/pkg/logging"
 	"reflect"
+	"strings"
 )
 
 type MealManager struct {
 	db             *repositories.SQLiteMealRepository
+	pantry         *repositories.SQLitePantryRepository
+	profiles       *repositories.SQLiteDietProfileRepository
 	validate       *validator.Validate
 	allIngredients map[string]int
+	matcher        *ingredientMatcher
 }
 
 var Microservices utils.EndpointsI = &utils.Endpoints{}
 
 type IMealManager interface {
-	GetMeal(userID, mealID string) (meal *models.Meal, err error)
-	ListMeals(userID string, filters *models.MealsFilters) (meals []*models.Meal, err error)
-	UpdateMeal(userID string, mealID string, mealPut models.Meal) (meal *models.Meal, err error)
-	CreateMeal(userID string, mealPost models.Meal) (meal *models.Meal, err error)
-	DeleteMeal(userID, mealID string) (err error)
+	GetMeal(ctx context.Context, userID, mealID string) (meal *models.Meal, err error)
+	ListMeals(ctx context.Context, userID string, filters *models.MealsFilters) (meals []*models.Meal, err error)
+	UpdateMeal(ctx context.Context, userID string, mealID string, mealPut models.Meal, ifMatch string) (meal *models.Meal, err error)
+	PatchMeal(ctx context.Context, userID string, mealID string, patch models.MealPatch, ifMatch string) (meal *models.Meal, err error)
+	CreateMeal(ctx context.Context, userID string, mealPost models.Meal, lang string) (meal *models.Meal, err error)
+	DeleteMeal(ctx context.Context, userID, mealID string, ifMatch string) (err error)
+	BatchMeals(ctx context.Context, userID string, batch models.MealBatch) (result *models.MealBatchResult, err error)
+	ExportMeals(ctx context.Context, userID string, fn func(meal *models.Meal) error) error
+	ImportMeals(ctx context.Context, userID string, rows []formats.Row, onConflict string) (report *models.MealImportReport, err error)
+	GeneratePlan(ctx context.Context, userID string, request models.MealPlanRequest) (plan *models.MealPlan, err error)
+	ShoppingList(ctx context.Context, userID string, request models.ShoppingListRequest, lang string) (list *models.ShoppingList, err error)
+	ListPantry(ctx context.Context, userID string) (items []*models.PantryItem, err error)
+	GetPantryItem(ctx context.Context, userID, itemID string) (item *models.PantryItem, err error)
+	CreatePantryItem(ctx context.Context, userID string, item models.PantryItem) (*models.PantryItem, error)
+	UpdatePantryItem(ctx context.Context, userID, itemID string, item models.PantryItem) (*models.PantryItem, error)
+	DeletePantryItem(ctx context.Context, userID, itemID string) error
+	CookableMeals(ctx context.Context, userID string, filter models.CookableFilter) (meals []models.CookableMeal, err error)
+	GetDietProfile(ctx context.Context, userID string) (*models.DietProfile, error)
+	PutDietProfile(ctx context.Context, userID string, profile models.DietProfile) (*models.DietProfile, error)
+	DeleteDietProfile(ctx context.Context, userID string) error
+	ActiveDietProfile(ctx context.Context, userID string, override models.ProfileOverride) (*models.DietProfile, error)
+	PutMealImage(ctx context.Context, userID, mealID string, content []byte) (*models.MealImage, error)
+	GetMealImage(ctx context.Context, userID, mealID, size string) (*blob.Blob, error)
 }
 
 func NewMealManager(db database.Database) *MealManager {
+	allIngredients := GetAllIngredients()
+	validate := validator.New()
+	// Failed fields are reported with their JSON names
+	validate.RegisterTagNameFunc(jsonFieldName)
 	return &MealManager{
 		db:             repositories.NewSQLiteMealRepository(&db),
-		validate:       validator.New(),
-		allIngredients: GetAllIngredients(),
+		pantry:         repositories.NewSQLitePantryRepository(&db),
+		profiles:       repositories.NewSQLiteDietProfileRepository(&db),
+		validate:       validate,
+		allIngredients: allIngredients,
+		matcher:        newIngredientMatcher(allIngredients),
 	}
 }
 
 // GetMeal function to get a specific meal from a user
-func (m *MealManager) GetMeal(userID, mealID string) (meal *models.Meal, err error) {
-	return m.db.GetMeal(userID, mealID)
+func (m *MealManager) GetMeal(ctx context.Context, userID, mealID string) (meal *models.Meal, err error) {
+	return m.db.GetMeal(ctx, userID, mealID)
 }
 
-// ListMeals returns all the meals created by a user
-func (m *MealManager) ListMeals(userID string, filters *models.MealsFilters) (meals []*models.Meal, err error) {
-	return m.db.ListMeals(userID, *filters)
+// ListMeals returns the meals created by a user that match the filters and the diet profile of the user
+func (m *MealManager) ListMeals(ctx context.Context, userID string, filters *models.MealsFilters) (meals []*models.Meal, err error) {
+	if filters.KcalMin != nil && *filters.KcalMin < 0 || filters.KcalMax != nil && *filters.KcalMax < 0 {
+		return nil, internal.WithDetail(internal.ErrRequestNotValid, internal.DetailKcalNegative)
+	}
+	if filters.KcalMin != nil && filters.KcalMax != nil && *filters.KcalMin > *filters.KcalMax {
+		return nil, internal.WithDetail(internal.ErrRequestNotValid, internal.DetailKcalRange)
+	}
+	if filters.MaxTotalTime != nil && *filters.MaxTotalTime < 0 {
+		return nil, internal.WithDetail(internal.ErrRequestNotValid, internal.DetailMaxTotalTimeNegative)
+	}
+	if err = validDietaryFilters(filters); err != nil {
+		return nil, err
+	}
+	profile, err := m.ActiveDietProfile(ctx, userID, filters.ProfileOverride)
+	if err != nil {
+		return nil, err
+	}
+	applyDietProfile(filters, profile)
+	return m.db.ListMeals(ctx, userID, *filters)
 }
 
-// UpdateMeal function to update the meal selected (if any parameter is missing we get the oldest ones
-func (m *MealManager) UpdateMeal(userID string, mealID string, mealPut models.Meal) (meal *models.Meal, err error) {
+// UpdateMeal function to replace the meal selected with the one sent (partial updates are done with PatchMeal)
+// The update is only applied when ifMatch matches the current version of the meal
+func (m *MealManager) UpdateMeal(ctx context.Context, userID string, mealID string, mealPut models.Meal, ifMatch string) (meal *models.Meal, err error) {
+	meal, renamed, err := m.updateMeal(ctx, m.db, userID, mealID, mealPut, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+	if renamed {
+		if err = Microservices.GetCalendar(ctx, userID, *meal, false); err != nil {
+			return nil, err
+		}
+	}
+	return
 
+}
+
+// updateMeal replaces the meal in the repository indicated, reporting if its name changed
+func (m *MealManager) updateMeal(ctx context.Context, repo *repositories.SQLiteMealRepository, userID string, mealID string, mealPut models.Meal, ifMatch string) (meal *models.Meal, renamed bool, err error) {
 	if err = m.validate.Struct(mealPut); err != nil {
-		return nil, err
+		return nil, false, internal.WrongBody(err)
+	}
+	if err = prepareCooking(&mealPut); err != nil {
+		return nil, false, err
 	}
-	mealGet, err := m.db.GetMeal(userID, mealID)
+	mealGet, err := repo.GetMeal(ctx, userID, mealID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !etag.Match(ifMatch, etag.Version(mealGet.Version), false) {
+		return nil, false, internal.ErrMealVersionMismatch
+	}
+	mealPut.Version = mealGet.Version
+
+	if mealPut.Servings == 0 {
+		mealPut.Servings = 1
+	}
+	changed := !reflect.DeepEqual(mealPut.Ingredients, mealGet.Ingredients) || mealPut.Servings != mealGet.Servings
+	if !changed {
+		mealPut.Kcal = mealGet.Kcal
+	}
+	m.nutrition(&mealPut, changed)
+	m.tagMeal(&mealPut)
+
+	meal, err = repo.UpdateMeal(ctx, userID, mealID, mealPut)
+	if err != nil {
+		return nil, false, err
+	}
+	return meal, meal.Name != mealGet.Name, nil
+}
+
+// PatchMeal function to partially update the meal selected with a JSON Merge Patch or a JSON Patch,
+// only when ifMatch matches its current version
+func (m *MealManager) PatchMeal(ctx context.Context, userID string, mealID string, patch models.MealPatch, ifMatch string) (meal *models.Meal, err error) {
+	mealGet, err := m.db.GetMeal(ctx, userID, mealID)
 	if err != nil {
 		return nil, err
 	}
+	if !etag.Match(ifMatch, etag.Version(mealGet.Version), false) {
+		return nil, internal.ErrMealVersionMismatch
+	}
 
-	var kcal int
-	if !reflect.DeepEqual(mealPut.Ingredients, mealGet.Ingredients) {
-		for _, ing := range mealPut.Ingredients {
-			kcal += m.allIngredients[ing]
+	original, err := json.Marshal(mealGet)
+	if err != nil {
+		return nil, internal.ErrSomethingWentWrong
+	}
+	var patched []byte
+	switch patch.ContentType {
+	case models.MIMEJSONPatch:
+		operations, err := jsonpatch.DecodePatch(patch.Body)
+		if err != nil {
+			return nil, internal.ErrWrongBody
 		}
-		mealPut.Kcal = kcal / len(mealPut.Ingredients)
-	} else {
-		mealPut.Kcal = mealGet.Kcal
+		if patched, err = operations.Apply(original); err != nil {
+			return nil, internal.ErrWrongBody
+		}
+	case models.MIMEMergePatch:
+		if patched, err = jsonpatch.MergePatch(original, patch.Body); err != nil {
+			return nil, internal.ErrWrongBody
+		}
+	default:
+		return nil, internal.ErrPatchNotSupported
 	}
 
-	meal, err = m.db.UpdateMeal(userID, mealID, mealPut)
+	mealPatch := models.Meal{}
+	if err = json.Unmarshal(patched, &mealPatch); err != nil {
+		return nil, internal.ErrWrongBody
+	}
+	// The identity of the meal can not be patched
+	mealPatch.Id, mealPatch.UserId, mealPatch.Version = mealGet.Id, mealGet.UserId, mealGet.Version
+	if err = m.validate.Struct(mealPatch); err != nil {
+		return nil, internal.WrongBody(err)
+	}
+	if err = prepareCooking(&mealPatch); err != nil {
+		return nil, err
+	}
+	if mealPatch.Servings == 0 {
+		mealPatch.Servings = 1
+	}
+	changed := !reflect.DeepEqual(mealPatch.Ingredients, mealGet.Ingredients) || mealPatch.Servings != mealGet.Servings
+	if !changed {
+		mealPatch.Kcal = mealGet.Kcal
+	}
+	m.nutrition(&mealPatch, changed)
+	m.tagMeal(&mealPatch)
+
+	meal, err = m.db.UpdateMeal(ctx, userID, mealID, mealPatch)
 	if err != nil {
 		return nil, err
 	}
 	if meal.Name != mealGet.Name {
-		if err = Microservices.GetCalendar(userID, *meal, false); err != nil {
+		if err = Microservices.GetCalendar(ctx, userID, *meal, false); err != nil {
 			return nil, err
 		}
 	}
 	return
-
 }
 
-// CreateMeal function to create a new meal for the user selected
-func (m *MealManager) CreateMeal(userID string, mealPost models.Meal) (meal *models.Meal, err error) {
+// CreateMeal function to create a new meal for the user selected, warning of the conflicts with the diet
+// profile of the user in the language indicated. The meal is created anyway, as the profile may not apply to every meal
+func (m *MealManager) CreateMeal(ctx context.Context, userID string, mealPost models.Meal, lang string) (meal *models.Meal, err error) {
+	if meal, err = m.createMeal(ctx, m.db, userID, mealPost); err != nil {
+		return nil, err
+	}
+	profile, err := m.ActiveDietProfile(ctx, userID, models.ProfileOverride{})
+	if err != nil {
+		// The meal is already created
+		logging.FromContext(ctx).Error("checking the diet profile", "user_id", userID, "meal_id", meal.Id, "error", err)
+		return meal, nil
+	}
+	meal.ProfileConflicts = profileConflicts(lang, meal, profile)
+	return meal, nil
+}
 
+// createMeal creates the meal in the repository indicated
+func (m *MealManager) createMeal(ctx context.Context, repo *repositories.SQLiteMealRepository, userID string, mealPost models.Meal) (meal *models.Meal, err error) {
 	if err = m.validate.Struct(mealPost); err != nil {
-		return nil, internal.ErrWrongBody
+		return nil, internal.WrongBody(err)
 	}
-	_, err = m.db.GetMealByName(userID, mealPost.Name)
-	if err != nil {
+	if err = prepareCooking(&mealPost); err != nil {
 		return nil, err
 	}
-	var kcal int
-	if mealPost.Kcal == 0 {
-		for _, ing := range mealPost.Ingredients {
-			kcal += m.allIngredients[ing]
-		}
-		mealPost.Kcal = kcal / len(mealPost.Ingredients)
+	_, err = repo.GetMealByName(ctx, userID, mealPost.Name)
+	if err != nil {
+		return nil, err
 	}
-	return m.db.CreateMeal(userID, mealPost)
+	m.nutrition(&mealPost, mealPost.Kcal == 0)
+	m.tagMeal(&mealPost)
+	return repo.CreateMeal(ctx, userID, mealPost)
 }
 
-// DeleteMeal function to delete on meal from user
-func (m *MealManager) DeleteMeal(userID, mealID string) (err error) {
-	if _, err = m.db.GetMeal(userID, mealID); err != nil {
+// DeleteMeal function to delete on meal from user, only when ifMatch matches its current version, along with its images
+func (m *MealManager) DeleteMeal(ctx context.Context, userID, mealID string, ifMatch string) (err error) {
+	if err = m.deleteMeal(ctx, m.db, userID, mealID, ifMatch); err != nil {
 		return err
 	}
-	if err = m.db.DeleteMeal(userID, mealID); err != nil {
+	deleteMealImages(ctx, userID, mealID)
+	if err = Microservices.GetCalendar(ctx, userID, models.Meal{Id: mealID}, true); err != nil {
 		return err
 	}
-	if err = Microservices.GetCalendar(userID, models.Meal{Id: mealID}, true); err != nil {
+
+	return
+}
+
+// deleteMeal deletes the meal from the repository indicated
+func (m *MealManager) deleteMeal(ctx context.Context, repo *repositories.SQLiteMealRepository, userID, mealID string, ifMatch string) (err error) {
+	mealGet, err := repo.GetMeal(ctx, userID, mealID)
+	if err != nil {
 		return err
 	}
+	if !etag.Match(ifMatch, etag.Version(mealGet.Version), false) {
+		return internal.ErrMealVersionMismatch
+	}
+	return repo.DeleteMeal(ctx, userID, mealID, mealGet.Version)
+}
 
-	return
+// computeKcal returns the kcal of a serving of the meal. When the ingredients of the catalog carry their
+// weight, e.g. "200 g de Arroz blanco", those are the kcal of the weights shared by the servings; otherwise
+// the average kcal of the ingredients, as the catalog has the ones of 100 g
+func (m *MealManager) computeKcal(ingredients []string, servings int) int {
+	if len(ingredients) == 0 {
+		return 0
+	}
+	weighed, found := 0.0, false
+	for _, ingredient := range ingredients {
+		key, quantity := m.shoppingIngredient(ingredient)
+		kcal, ok := m.allIngredients[key]
+		if !ok || quantity == nil || quantity.Unit != "g" && quantity.Unit != "ml" {
+			continue
+		}
+		weighed += float64(kcal) * quantity.Amount / 100
+		found = true
+	}
+	if found {
+		return int(math.Round(weighed / float64(servings)))
+	}
+
+	var kcal int
+	for _, ing := range ingredients {
+		kcal += m.allIngredients[ing]
+	}
+	return kcal / len(ingredients)
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
 }
 
 func GetAllIngredients() map[string]int {
@@ -0,0 +1,191 @@
+package managers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"meals/internal"
+	"meals/internal/models"
+	"sort"
+	"time"
+)
+
+// planCandidates is the number of meals closest to the kcal of a slot the planner picks from, so the
+// plans do not always repeat the same meals
+const planCandidates = 3
+
+// GeneratePlan plans the meals of the user for every day between the dates of the request. A meal is only
+// planned in its seasons or when it is general, as many times a week as its type allows and not again
+// within the days indicated. When there is a daily kcal target, every slot prefers the meals closest to
+// the kcal still missing that day. The diet profile of the user leaves out the meals that do not fit it
+// and sets the kcal target when the request does not
+func (m *MealManager) GeneratePlan(ctx context.Context, userID string, request models.MealPlanRequest) (plan *models.MealPlan, err error) {
+	if err = m.validate.Struct(request); err != nil {
+		return nil, internal.WrongBody(err)
+	}
+	from, _ := time.Parse(models.PlanDateLayout, request.From)
+	to, _ := time.Parse(models.PlanDateLayout, request.To)
+	if to.Before(from) {
+		return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailPlanToBeforeFrom)
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > models.PlanMaxDays {
+		return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailPlanTooLong, models.PlanMaxDays)
+	}
+
+	profile, err := m.ActiveDietProfile(ctx, userID, request.ProfileOverride)
+	if err != nil {
+		return nil, err
+	}
+	filters := models.MealsFilters{}
+	applyDietProfile(&filters, profile)
+	if profile != nil && request.DailyKcal == 0 {
+		request.DailyKcal = profile.DailyKcal
+	}
+
+	meals, err := m.db.ListMeals(ctx, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+	plan = newPlan(request)
+	planMeals(plan, meals, from, to)
+	return plan, nil
+}
+
+// newPlan returns an empty plan with the defaults of the settings not sent in the request
+func newPlan(request models.MealPlanRequest) *models.MealPlan {
+	plan := &models.MealPlan{
+		From:         request.From,
+		To:           request.To,
+		SlotsPerDay:  request.SlotsPerDay,
+		DailyKcal:    request.DailyKcal,
+		NoRepeatDays: models.PlanDefaultNoRepeatDays,
+		MaxPerWeek:   map[string]int{},
+	}
+	if plan.SlotsPerDay == 0 {
+		plan.SlotsPerDay = models.PlanDefaultSlotsPerDay
+	}
+	if request.NoRepeatDays != nil {
+		plan.NoRepeatDays = *request.NoRepeatDays
+	}
+	for mealType, max := range models.PlanDefaultMaxPerWeek {
+		plan.MaxPerWeek[mealType] = max
+	}
+	for mealType, max := range request.MaxPerWeek {
+		// 0 removes the limit of the type
+		plan.MaxPerWeek[mealType] = max
+		if max == 0 {
+			delete(plan.MaxPerWeek, mealType)
+		}
+	}
+	if request.Seed != nil {
+		plan.Seed = *request.Seed
+	} else {
+		plan.Seed = rand.Int63()
+	}
+	return plan
+}
+
+// planMeals fills the days of the plan. The meals are sorted by id first, so the same seed always
+// generates the same plan
+func planMeals(plan *models.MealPlan, meals []*models.Meal, from, to time.Time) {
+	meals = append([]*models.Meal{}, meals...)
+	sort.Slice(meals, func(i, j int) bool { return meals[i].Id < meals[j].Id })
+	random := rand.New(rand.NewSource(plan.Seed))
+
+	lastPlanned := map[string]int{} // Day of the plan a meal was last planned
+	perWeek := map[string]int{}     // Meals of every type planned in the week, by type and week
+	for day, date := 0, from; !date.After(to); day, date = day+1, date.AddDate(0, 0, 1) {
+		year, week := date.ISOWeek()
+		weekKey := func(mealType string) string { return fmt.Sprintf("%d-%d-%s", year, week, mealType) }
+		planDay := models.MealPlanDay{
+			Date:   date.Format(models.PlanDateLayout),
+			Season: seasonOf(date),
+			Slots:  make([]models.MealPlanSlot, plan.SlotsPerDay),
+		}
+		for slot := range planDay.Slots {
+			planDay.Slots[slot].Slot = slot + 1
+			var candidates []*models.Meal
+			for _, meal := range meals {
+				if last, ok := lastPlanned[meal.Id]; ok && (day == last || day-last < plan.NoRepeatDays) {
+					continue
+				}
+				if max, ok := plan.MaxPerWeek[meal.Type]; ok && perWeek[weekKey(meal.Type)] >= max {
+					continue
+				}
+				if !inSeason(meal, planDay.Season) {
+					continue
+				}
+				candidates = append(candidates, meal)
+			}
+			if len(candidates) == 0 {
+				plan.Unfilled++
+				continue
+			}
+
+			meal := pickMeal(random, candidates, slotKcal(plan, planDay.Kcal, slot))
+			planDay.Slots[slot].Meal = meal
+			planDay.Kcal += meal.Kcal
+			lastPlanned[meal.Id] = day
+			perWeek[weekKey(meal.Type)]++
+		}
+		plan.Days = append(plan.Days, planDay)
+	}
+}
+
+// slotKcal returns the kcal a slot should have to reach the daily target, or -1 when there is no target
+func slotKcal(plan *models.MealPlan, planned, slot int) int {
+	if plan.DailyKcal == 0 {
+		return -1
+	}
+	missing := plan.DailyKcal - planned
+	if missing < 0 {
+		missing = 0
+	}
+	return missing / (plan.SlotsPerDay - slot)
+}
+
+// pickMeal picks one of the meals closest to the kcal indicated, or any of them when there is no target
+func pickMeal(random *rand.Rand, candidates []*models.Meal, kcal int) *models.Meal {
+	if kcal < 0 {
+		return candidates[random.Intn(len(candidates))]
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return math.Abs(float64(candidates[i].Kcal-kcal)) < math.Abs(float64(candidates[j].Kcal-kcal))
+	})
+	closest := planCandidates
+	if len(candidates) < closest {
+		closest = len(candidates)
+	}
+	return candidates[random.Intn(closest)]
+}
+
+// inSeason tells if a meal can be planned in a season. Meals without seasons are general
+func inSeason(meal *models.Meal, season string) bool {
+	general := true
+	for _, s := range meal.Seasons {
+		if s == season || s == "general" {
+			return true
+		}
+		general = general && s == ""
+	}
+	return general
+}
+
+// seasonOf returns the season of a date in the northern hemisphere, starting on the 21st of March, June,
+// September and December
+func seasonOf(date time.Time) string {
+	month, day := date.Month(), date.Day()
+	switch {
+	case month < time.March || month == time.March && day < 21:
+		return "invierno"
+	case month < time.June || month == time.June && day < 21:
+		return "primavera"
+	case month < time.September || month == time.September && day < 21:
+		return "verano"
+	case month < time.December || month == time.December && day < 21:
+		return "otoño"
+	default:
+		return "invierno"
+	}
+}
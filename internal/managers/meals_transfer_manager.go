@@ -0,0 +1,152 @@
+package managers
+
+import (
+	"context"
+	"fmt"
+	"meals/internal"
+	"meals/internal/formats"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"meals/internal/repositories"
+	"meals/pkg/logging"
+)
+
+// maxRenameAttempts limits the names tried when renaming an imported meal that already exists
+const maxRenameAttempts = 100
+
+// ExportMeals calls fn with every meal of the user, one at a time
+func (m *MealManager) ExportMeals(ctx context.Context, userID string, fn func(meal *models.Meal) error) error {
+	return m.db.EachMeal(ctx, userID, fn)
+}
+
+// ImportMeals creates the meals read from a file in a single transaction. Every row is validated with
+// the same rules as a new meal, and the rows whose name already exists are skipped, renamed or overwritten
+// depending on onConflict. A failed row does not prevent the rest from being imported.
+// The free text ingredients of recipes from other apps are matched to the catalog, and then the kcal
+// are computed from it unless none of them is known
+func (m *MealManager) ImportMeals(ctx context.Context, userID string, rows []formats.Row, onConflict string) (report *models.MealImportReport, err error) {
+	if onConflict == "" {
+		onConflict = models.ImportConflictSkip
+	}
+	if err = m.validate.Var(onConflict, "oneof=skip rename overwrite"); err != nil {
+		return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailOnConflictNotValid)
+	}
+	report = &models.MealImportReport{OnConflict: onConflict, Total: len(rows), Items: make([]models.MealImportItem, len(rows))}
+	renamed := make([]*models.Meal, len(rows))
+
+	err = m.db.Transaction(ctx, func(tx *repositories.SQLiteMealRepository) error {
+		for i, row := range rows {
+			item := &report.Items[i]
+			*item = models.MealImportItem{Row: row.Row, Name: row.Meal.Name}
+			if row.MatchIngredients {
+				var matched int
+				if row.Meal.Ingredients, matched = m.matcher.MatchAll(row.Meal.Ingredients); matched > 0 {
+					row.Meal.Kcal = 0
+				}
+			}
+			if row.Err != nil {
+				setImportError(item, internal.WithCause(internal.ErrWrongBody, row.Err))
+				continue
+			}
+			if rowErr := m.validate.Struct(row.Meal); rowErr != nil {
+				setImportError(item, internal.WrongBody(rowErr))
+				continue
+			}
+			rowErr := tx.Savepoint(ctx, fmt.Sprintf("import_row_%d", i), func() (err error) {
+				renamed[i], err = m.importMeal(ctx, tx, userID, row.Meal, onConflict, item)
+				return
+			})
+			if rowErr != nil {
+				setImportError(item, rowErr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, meal := range renamed {
+		if meal == nil || report.Items[i].Status == models.ImportStatusFailed {
+			continue
+		}
+		if err = Microservices.GetCalendar(ctx, userID, *meal, false); err != nil {
+			// The meal is imported already, so it is still reported as updated
+			logging.FromContext(ctx).Error("updating the calendar", "user_id", userID, "meal_id", meal.Id, "error", err)
+			report.Items[i].Warning = internal.NewItemError(i18n.Default, err)
+		}
+	}
+	for _, item := range report.Items {
+		switch item.Status {
+		case models.ImportStatusCreated, models.ImportStatusRenamed:
+			report.Created++
+		case models.ImportStatusUpdated:
+			report.Updated++
+		case models.ImportStatusSkipped:
+			report.Skipped++
+		case models.ImportStatusFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// importMeal creates a single imported meal applying the conflict policy, returning the meal
+// when an overwrite changed the name of an existing one
+func (m *MealManager) importMeal(ctx context.Context, tx *repositories.SQLiteMealRepository, userID string, meal models.Meal, onConflict string, item *models.MealImportItem) (renamed *models.Meal, err error) {
+	existing, err := tx.FindMealByName(ctx, userID, meal.Name)
+	if err != nil {
+		return nil, err
+	}
+	status := models.ImportStatusCreated
+	if existing != nil {
+		switch onConflict {
+		case models.ImportConflictSkip:
+			item.Status, item.Id = models.ImportStatusSkipped, existing.Id
+			return nil, nil
+		case models.ImportConflictOverwrite:
+			mealPut := meal
+			mealPut.Id = existing.Id
+			updated, nameChanged, err := m.updateMeal(ctx, tx, userID, existing.Id, mealPut, "*")
+			if err != nil {
+				return nil, err
+			}
+			item.Status, item.Id = models.ImportStatusUpdated, updated.Id
+			if nameChanged {
+				return updated, nil
+			}
+			return nil, nil
+		case models.ImportConflictRename:
+			if meal.Name, err = m.freeName(ctx, tx, userID, meal.Name); err != nil {
+				return nil, err
+			}
+			status = models.ImportStatusRenamed
+		}
+	}
+	created, err := m.createMeal(ctx, tx, userID, meal)
+	if err != nil {
+		return nil, err
+	}
+	item.Status, item.Id, item.Name = status, created.Id, created.Name
+	return nil, nil
+}
+
+// freeName returns the first name like "name (2)" not used yet by the meals of the user
+func (m *MealManager) freeName(ctx context.Context, tx *repositories.SQLiteMealRepository, userID, name string) (string, error) {
+	for i := 2; i < maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		existing, err := tx.FindMealByName(ctx, userID, candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+	}
+	return "", internal.ErrMealAlreadyExist
+}
+
+func setImportError(item *models.MealImportItem, err error) {
+	item.Status = models.ImportStatusFailed
+	item.Error = internal.NewItemError(i18n.Default, err)
+}
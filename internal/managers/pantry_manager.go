@@ -0,0 +1,167 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/text"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ListPantry returns the items of the pantry of the user, the ones expiring sooner first
+func (m *MealManager) ListPantry(ctx context.Context, userID string) (items []*models.PantryItem, err error) {
+	if items, err = m.pantry.ListPantry(ctx, userID); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		item.Category = ingredientCategory(item.Ingredient)
+	}
+	return items, nil
+}
+
+func (m *MealManager) GetPantryItem(ctx context.Context, userID, itemID string) (item *models.PantryItem, err error) {
+	if item, err = m.pantry.GetPantryItem(ctx, userID, itemID); err != nil {
+		return nil, err
+	}
+	item.Category = ingredientCategory(item.Ingredient)
+	return item, nil
+}
+
+// CreatePantryItem adds an ingredient to the pantry with its name in the catalog, so "tomate" is stored as "Tomates"
+func (m *MealManager) CreatePantryItem(ctx context.Context, userID string, item models.PantryItem) (*models.PantryItem, error) {
+	if err := m.validatePantryItem(&item); err != nil {
+		return nil, err
+	}
+	created, err := m.pantry.CreatePantryItem(ctx, userID, item)
+	if err != nil {
+		return nil, err
+	}
+	created.Category = ingredientCategory(created.Ingredient)
+	return created, nil
+}
+
+func (m *MealManager) UpdatePantryItem(ctx context.Context, userID, itemID string, item models.PantryItem) (*models.PantryItem, error) {
+	if err := m.validatePantryItem(&item); err != nil {
+		return nil, err
+	}
+	updated, err := m.pantry.UpdatePantryItem(ctx, userID, itemID, item)
+	if err != nil {
+		return nil, err
+	}
+	updated.Category = ingredientCategory(updated.Ingredient)
+	return updated, nil
+}
+
+func (m *MealManager) DeletePantryItem(ctx context.Context, userID, itemID string) error {
+	return m.pantry.DeletePantryItem(ctx, userID, itemID)
+}
+
+// CookableMeals ranks the meals of the user by the ingredients they miss from the pantry, fewest first, and then
+// by the share of their ingredients available. With a window of expiring days, the meals that use more of
+// the items about to expire come first
+func (m *MealManager) CookableMeals(ctx context.Context, userID string, filter models.CookableFilter) (cookable []models.CookableMeal, err error) {
+	if filter.ExpiringWithin != nil && *filter.ExpiringWithin < 0 || filter.MaxMissing != nil && *filter.MaxMissing < 0 {
+		return nil, internal.WithDetail(internal.ErrRequestNotValid, internal.DetailPantryFiltersNegative)
+	}
+	meals, err := m.db.ListMeals(ctx, userID, models.MealsFilters{})
+	if errors.Is(err, internal.ErrMealsNotFound) {
+		return []models.CookableMeal{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := m.pantry.ListPantry(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().Format(models.PlanDateLayout)
+	expiringBy := ""
+	if filter.ExpiringWithin != nil {
+		expiringBy = time.Now().AddDate(0, 0, *filter.ExpiringWithin).Format(models.PlanDateLayout)
+	}
+	available := map[string]bool{}
+	expiring := map[string]bool{}
+	for _, item := range items {
+		if item.ExpiresOn != "" && item.ExpiresOn < today {
+			continue
+		}
+		key := m.ingredientKey(item.Ingredient)
+		available[key] = true
+		if expiringBy != "" && item.ExpiresOn != "" && item.ExpiresOn <= expiringBy {
+			expiring[key] = true
+		}
+	}
+
+	cookable = []models.CookableMeal{}
+	for _, meal := range meals {
+		entry := models.CookableMeal{Meal: meal, Available: []string{}, Missing: []string{}}
+		for _, ingredient := range meal.Ingredients {
+			if strings.TrimSpace(ingredient) == "" {
+				continue
+			}
+			key := m.ingredientKey(ingredient)
+			if !available[key] {
+				entry.Missing = append(entry.Missing, ingredient)
+				continue
+			}
+			entry.Available = append(entry.Available, ingredient)
+			if expiring[key] {
+				entry.Expiring = append(entry.Expiring, ingredient)
+			}
+		}
+		if filter.MaxMissing != nil && len(entry.Missing) > *filter.MaxMissing {
+			continue
+		}
+		if total := len(entry.Available) + len(entry.Missing); total > 0 {
+			entry.Coverage = float64(len(entry.Available)) / float64(total)
+		}
+		cookable = append(cookable, entry)
+	}
+	sort.SliceStable(cookable, func(i, j int) bool {
+		a, b := cookable[i], cookable[j]
+		switch {
+		case len(a.Expiring) != len(b.Expiring):
+			return len(a.Expiring) > len(b.Expiring)
+		case len(a.Missing) != len(b.Missing):
+			return len(a.Missing) < len(b.Missing)
+		case a.Coverage != b.Coverage:
+			return a.Coverage > b.Coverage
+		default:
+			return a.Meal.Name < b.Meal.Name
+		}
+	})
+	return cookable, nil
+}
+
+func (m *MealManager) validatePantryItem(item *models.PantryItem) error {
+	item.Ingredient = strings.TrimSpace(item.Ingredient)
+	if err := m.validate.Struct(item); err != nil {
+		return internal.WrongBody(err)
+	}
+	if name, ok := m.matcher.Match(item.Ingredient); ok {
+		item.Ingredient = name
+	}
+	return nil
+}
+
+// ingredientKey returns the name in the catalog of an ingredient, or the folded name when it is not in it
+func (m *MealManager) ingredientKey(ingredient string) string {
+	if name, ok := m.matcher.Match(ingredient); ok {
+		return name
+	}
+	return text.Fold(ingredient)
+}
+
+// ingredientCategory returns the category of the catalog of an ingredient, empty when it is not in it
+func ingredientCategory(ingredient string) string {
+	for category, ingredients := range models.Ingredients {
+		if _, ok := ingredients[ingredient]; ok {
+			return category
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package managers
+
+import (
+	"math"
+	"meals/internal"
+	"meals/internal/models"
+	"strconv"
+	"strings"
+)
+
+// measures are the steps the quantities are rounded to when scaled, by their unit and up to the amount
+// indicated, so they can be measured. The step is also the least quantity
+var measures = map[string][]struct {
+	upTo, step float64
+}{
+	"g":           {{10, 1}, {100, 5}, {math.Inf(1), 10}},
+	"ml":          {{10, 1}, {100, 5}, {math.Inf(1), 10}},
+	"":            {{2, 0.5}, {math.Inf(1), 1}},
+	"cucharada":   {{math.Inf(1), 0.5}},
+	"cucharadita": {{math.Inf(1), 0.5}},
+	"taza":        {{math.Inf(1), 0.25}},
+}
+
+// plurals are the units written in singular and in plural
+var plurals = map[string]string{
+	"ud": "uds", "unidad": "unidades", "cucharada": "cucharadas", "cucharadita": "cucharaditas", "taza": "tazas",
+}
+
+// nutrition sets the servings of the meal, 1 when not sent, and its total kcal. The kcal of a serving are
+// computed again when indicated
+func (m *MealManager) nutrition(meal *models.Meal, computeKcal bool) {
+	if meal.Servings == 0 {
+		meal.Servings = 1
+	}
+	if computeKcal {
+		meal.Kcal = m.computeKcal(meal.Ingredients, meal.Servings)
+	}
+	meal.KcalTotal = meal.Kcal * meal.Servings
+}
+
+// ScaleMeal changes the meal to the servings indicated, with the quantities of its ingredients scaled and
+// rounded to what can be measured in their unit, e.g. "250 g de Arroz blanco" for 3 servings is
+// "330 g de Arroz blanco" for 4. The kcal of a serving do not change
+func ScaleMeal(meal *models.Meal, servings int) error {
+	if servings < 1 || servings > models.MaxServings {
+		return internal.WithDetail(internal.ErrRequestNotValid, internal.DetailServingsRange, models.MaxServings)
+	}
+	if meal.Servings == 0 {
+		meal.Servings = 1
+	}
+	factor := float64(servings) / float64(meal.Servings)
+	scaled := make([]string, 0, len(meal.Ingredients))
+	for _, ingredient := range meal.Ingredients {
+		scaled = append(scaled, scaleIngredient(ingredient, factor))
+	}
+	meal.Ingredients = scaled
+	meal.Servings = servings
+	meal.KcalTotal = meal.Kcal * servings
+	return nil
+}
+
+// scaleIngredient returns the ingredient with its quantity scaled, as it is when it has none
+func scaleIngredient(ingredient string, factor float64) string {
+	match := quantityRegexp.FindStringSubmatchIndex(ingredient)
+	if match == nil || factor == 1 {
+		return ingredient
+	}
+	written := ingredient[match[2]:match[3]]
+	amount, _ := strconv.ParseFloat(strings.Replace(written, ",", ".", 1), 64)
+	unit := ""
+	if match[4] >= 0 {
+		unit = ingredient[match[4]:match[5]]
+	}
+
+	// The amounts in kg and l are rounded in g and ml
+	base := units[strings.ToLower(unit)]
+	if base.factor == 0 {
+		base.factor = 1
+	}
+	scaled := roundMeasure(amount*factor*base.factor, base.unit) / base.factor
+	formatted := strconv.FormatFloat(math.Round(scaled*1000)/1000, 'f', -1, 64)
+	if strings.Contains(written, ",") {
+		formatted = strings.Replace(formatted, ".", ",", 1)
+	}
+	if unit == "" {
+		return ingredient[:match[2]] + formatted + ingredient[match[3]:]
+	}
+	return ingredient[:match[2]] + formatted + ingredient[match[3]:match[4]] + inNumber(unit, scaled) + ingredient[match[5]:]
+}
+
+// roundMeasure rounds the amount to the step of its unit, never to nothing
+func roundMeasure(amount float64, unit string) float64 {
+	steps, ok := measures[unit]
+	if !ok {
+		return amount
+	}
+	for _, measure := range steps {
+		if amount <= measure.upTo {
+			return math.Max(measure.step, math.Round(amount/measure.step)*measure.step)
+		}
+	}
+	return amount
+}
+
+// inNumber returns the unit in singular for one and in plural for the rest of amounts
+func inNumber(unit string, amount float64) string {
+	lower := strings.ToLower(unit)
+	for singular, plural := range plurals {
+		switch {
+		case lower == singular && amount != 1:
+			return plural
+		case lower == plural && amount == 1:
+			return singular
+		}
+	}
+	return unit
+}
@@ -0,0 +1,180 @@
+package managers
+
+import (
+	"context"
+	"errors"
+	"meals/internal"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quantityRegexp reads the quantity at the start of an ingredient, e.g. "200 g de calabacín" or "2 huevos"
+var quantityRegexp = regexp.MustCompile(`(?i)^\s*(\d+(?:[.,]\d+)?)\s*(kg|g|mg|l|cl|ml|uds?|unidad(?:es)?|cucharadas?|cucharaditas?|tazas?)?\.?\s+(?:de\s+)?(.+)$`)
+
+// units are the units of the quantities, converted to the smallest one of their kind so they can be summed
+var units = map[string]struct {
+	unit   string
+	factor float64
+}{
+	"kg": {"g", 1000}, "g": {"g", 1}, "mg": {"g", 0.001},
+	"l": {"ml", 1000}, "cl": {"ml", 10}, "ml": {"ml", 1},
+	"ud": {"", 1}, "uds": {"", 1}, "unidad": {"", 1}, "unidades": {"", 1},
+	"cucharada": {"cucharada", 1}, "cucharadas": {"cucharada", 1},
+	"cucharadita": {"cucharadita", 1}, "cucharaditas": {"cucharadita", 1},
+	"taza": {"taza", 1}, "tazas": {"taza", 1},
+}
+
+// ShoppingList returns the ingredients of the meals indicated or planned in the calendar between the dates,
+// grouped by the categories of the catalog with their names in the language indicated. A meal planned
+// several times counts its ingredients as many times
+func (m *MealManager) ShoppingList(ctx context.Context, userID string, request models.ShoppingListRequest, lang string) (list *models.ShoppingList, err error) {
+	if err = m.validate.Struct(request); err != nil {
+		return nil, internal.WrongBody(err)
+	}
+	if len(request.MealIds) == 0 && request.From == "" {
+		return nil, internal.WithDetail(internal.ErrWrongBody, internal.DetailShoppingListRange)
+	}
+
+	mealIDs := request.MealIds
+	fromCalendar := len(mealIDs) == 0
+	if fromCalendar {
+		if mealIDs, err = m.calendarMeals(ctx, userID, request.From, request.To); err != nil {
+			return nil, err
+		}
+	}
+
+	list = &models.ShoppingList{Language: lang, Meals: []models.ShoppingListMeal{}}
+	times := map[string]int{}
+	var meals []*models.Meal
+	for _, id := range mealIDs {
+		if times[id]++; times[id] > 1 {
+			continue
+		}
+		meal, err := m.db.GetMeal(ctx, userID, id)
+		if errors.Is(err, internal.ErrMealNotFound) && fromCalendar {
+			// The calendar may still refer to meals deleted since
+			continue
+		}
+		if err != nil {
+			return nil, internal.WithDetail(err, internal.DetailMeal, id)
+		}
+		meals = append(meals, meal)
+	}
+
+	items := map[string]*models.ShoppingListItem{}
+	for _, meal := range meals {
+		list.Meals = append(list.Meals, models.ShoppingListMeal{Id: meal.Id, Name: meal.Name, Times: times[meal.Id]})
+		for _, ingredient := range meal.Ingredients {
+			key, quantity := m.shoppingIngredient(ingredient)
+			if key == "" {
+				continue
+			}
+			item, ok := items[key]
+			if !ok {
+				item = &models.ShoppingListItem{Key: key, Name: i18n.Message(lang, i18n.GroupIngredients, key), Meals: []string{}}
+				items[key] = item
+			}
+			if n := len(item.Meals); n == 0 || item.Meals[n-1] != meal.Name {
+				item.Times += times[meal.Id]
+				item.Meals = append(item.Meals, meal.Name)
+			}
+			if quantity != nil {
+				addQuantity(item, models.ShoppingQuantity{Amount: quantity.Amount * float64(times[meal.Id]), Unit: quantity.Unit})
+			}
+		}
+	}
+	list.Categories = shoppingCategories(items, lang)
+	return list, nil
+}
+
+// calendarMeals returns the ids of the meals planned in the calendar between the dates, once per day planned
+func (m *MealManager) calendarMeals(ctx context.Context, userID, from, to string) ([]string, error) {
+	calendar, err := Microservices.ListCalendar(ctx, userID)
+	if err != nil {
+		return nil, internal.WithDetail(internal.ErrorWithExternalAPI, internal.DetailCalendar, err.Error())
+	}
+	var ids []string
+	for _, day := range calendar {
+		// The dates of the calendar may carry the time
+		date := day.Date
+		if len(date) > len(models.PlanDateLayout) {
+			date = date[:len(models.PlanDateLayout)]
+		}
+		if _, err := time.Parse(models.PlanDateLayout, date); err != nil || date < from || date > to || day.MealId == "" {
+			continue
+		}
+		ids = append(ids, day.MealId)
+	}
+	return ids, nil
+}
+
+// shoppingIngredient returns the ingredient of the catalog a meal refers to, along with its quantity when it
+// carries one. Ingredients not in the catalog are kept as they are written
+func (m *MealManager) shoppingIngredient(ingredient string) (string, *models.ShoppingQuantity) {
+	ingredient = strings.TrimSpace(ingredient)
+	var quantity *models.ShoppingQuantity
+	if match := quantityRegexp.FindStringSubmatch(ingredient); match != nil {
+		amount, _ := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+		unit := units[strings.ToLower(match[2])]
+		if unit.factor == 0 {
+			unit.factor = 1
+		}
+		quantity = &models.ShoppingQuantity{Amount: amount * unit.factor, Unit: unit.unit}
+		ingredient = strings.TrimSpace(match[3])
+	}
+	if name, ok := m.matcher.Match(ingredient); ok {
+		return name, quantity
+	}
+	return ingredient, quantity
+}
+
+func addQuantity(item *models.ShoppingListItem, quantity models.ShoppingQuantity) {
+	for i := range item.Quantities {
+		if item.Quantities[i].Unit == quantity.Unit {
+			item.Quantities[i].Amount += quantity.Amount
+			return
+		}
+	}
+	item.Quantities = append(item.Quantities, quantity)
+}
+
+// shoppingCategories groups the items by the categories of the catalog sorted by key, with the ingredients
+// not in the catalog last
+func shoppingCategories(items map[string]*models.ShoppingListItem, lang string) []models.ShoppingListCategory {
+	categoryOf := map[string]string{}
+	for category, ingredients := range models.Ingredients {
+		for ingredient := range ingredients {
+			categoryOf[ingredient] = category
+		}
+	}
+
+	grouped := map[string]*models.ShoppingListCategory{}
+	for key, item := range items {
+		category, ok := categoryOf[key]
+		if !ok {
+			category = models.ShoppingCategoryOther
+		}
+		if grouped[category] == nil {
+			grouped[category] = &models.ShoppingListCategory{Key: category, Name: i18n.Message(lang, i18n.GroupCategories, category)}
+		}
+		grouped[category].Items = append(grouped[category].Items, *item)
+	}
+
+	categories := make([]models.ShoppingListCategory, 0, len(grouped))
+	for _, category := range grouped {
+		sort.Slice(category.Items, func(i, j int) bool { return category.Items[i].Key < category.Items[j].Key })
+		categories = append(categories, *category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if (categories[i].Key == models.ShoppingCategoryOther) != (categories[j].Key == models.ShoppingCategoryOther) {
+			return categories[j].Key == models.ShoppingCategoryOther
+		}
+		return categories[i].Key < categories[j].Key
+	})
+	return categories
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "meals"
+
+// Services called by this one, used as the service label of the outbound metrics
+const (
+	ServiceCalendars = "calendars"
+	ServiceEdamam    = "edamam"
+	ServiceImages    = "images"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests served, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of the SQLite queries, by repository method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"method"})
+
+	outboundDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "outbound_request_duration_seconds",
+		Help:      "Latency of the calls to other services, by service and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "method", "status"})
+	outboundErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbound_errors_total",
+		Help:      "Calls to other services that failed or answered with a 5xx status.",
+	}, []string{"service", "method"})
+)
+
+// NewRegistry returns a registry with the metrics of the service, the pool stats of the database
+// and the Go runtime and process metrics
+func NewRegistry(db *sql.DB) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		httpRequests, httpDuration, dbDuration, outboundDuration, outboundErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+	return registry
+}
+
+// Middleware counts the requests served and their latency by the route they matched
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			route := c.Path()
+			if route == "" {
+				// Requests that did not match any route would create a series for every path
+				route = "unmatched"
+			}
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = http.StatusInternalServerError
+				if httpErr, ok := err.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				}
+			}
+			method := c.Request().Method
+			httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+			httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// ObserveQuery records the latency of a query of a repository method, to be deferred with its start time
+func ObserveQuery(method string, start time.Time) {
+	dbDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// Transport records the latency and errors of the calls made with it to the service indicated
+func Transport(service string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{service: service, base: base}
+}
+
+type transport struct {
+	service string
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	outboundDuration.WithLabelValues(t.service, req.Method, status).Observe(time.Since(start).Seconds())
+	if err != nil || res.StatusCode >= http.StatusInternalServerError {
+		outboundErrors.WithLabelValues(t.service, req.Method).Inc()
+	}
+	return res, err
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 	"meals/internal/models"
 )
@@ -9,7 +10,13 @@ type EndpointsMock struct {
 	mock.Mock
 }
 
-func (e *EndpointsMock) GetCalendar(userId string, meal models.Meal, delete bool) (err error) {
-	args := e.Called(userId, meal, delete)
+func (e *EndpointsMock) GetCalendar(ctx context.Context, userId string, meal models.Meal, delete bool) (err error) {
+	args := e.Called(ctx, userId, meal, delete)
 	return args.Error(0)
 }
+
+func (e *EndpointsMock) ListCalendar(ctx context.Context, userId string) (calendar []models.Calendar, err error) {
+	args := e.Called(ctx, userId)
+	calendar, _ = args.Get(0).([]models.Calendar)
+	return calendar, args.Error(1)
+}
@@ -0,0 +1,37 @@
+package models
+
+// MealTypes and MealSeasons are the canonical keys stored for the type and seasons of a meal
+var (
+	MealTypes   = []string{"semanal", "ocasional", "normal"}
+	MealSeasons = []string{"primavera", "verano", "otoño", "invierno", "general"}
+)
+
+// Catalog is the catalog of the service with the display names in the language of the request.
+// Meals are always stored and filtered by the keys, whatever the language
+type Catalog struct {
+	Language   string            `json:"language"`
+	Types      []CatalogEntry    `json:"types"`
+	Seasons    []CatalogEntry    `json:"seasons"`
+	Allergens  []CatalogEntry    `json:"allergens"`
+	Diets      []CatalogEntry    `json:"diets"`
+	Categories []CatalogCategory `json:"categories"`
+}
+
+type CatalogEntry struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type CatalogCategory struct {
+	Key         string              `json:"key"`
+	Name        string              `json:"name"`
+	Ingredients []CatalogIngredient `json:"ingredients"`
+}
+
+type CatalogIngredient struct {
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	Kcal      int      `json:"kcal"`
+	Allergens []string `json:"allergens"`
+	Diets     []string `json:"diets"`
+}
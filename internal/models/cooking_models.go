@@ -0,0 +1,19 @@
+package models
+
+// Step is a cooking step of a meal, with the minutes of its timer when it has to be timed
+type Step struct {
+	Text         string `json:"text" validate:"required,max=1000"`
+	TimerMinutes int    `json:"timer_minutes,omitempty" validate:"min=0,max=1440"`
+}
+
+// StepDB is a step stored in its own table, in the position it has among the steps of the meal
+type StepDB struct {
+	MealId       string `db:"meal_id"`
+	Position     int    `db:"position"`
+	Text         string `db:"text"`
+	TimerMinutes int    `db:"timer_minutes"`
+}
+
+func StepToAPI(step StepDB) Step {
+	return Step{Text: step.Text, TimerMinutes: step.TimerMinutes}
+}
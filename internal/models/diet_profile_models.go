@@ -0,0 +1,52 @@
+package models
+
+import "strings"
+
+// DietProfile is what the user can and wants to eat, applied by default when listing, searching and
+// planning meals. Allergens and diets are keys of the catalog
+type DietProfile struct {
+	ExcludedAllergens   []string `json:"excluded_allergens" validate:"max=14"`
+	Diets               []string `json:"diets" validate:"max=3"`
+	DislikedIngredients []string `json:"disliked_ingredients" validate:"max=50,dive,required,max=100,excludesall=0x2C"`
+	// DailyKcal is the default target of the plans, 0 when there is not
+	DailyKcal int `json:"daily_kcal" validate:"min=0,max=10000"`
+}
+
+type DietProfileDB struct {
+	UserId              string `db:"user_id"`
+	ExcludedAllergens   string `db:"excluded_allergens"`
+	Diets               string `db:"diets"`
+	DislikedIngredients string `db:"disliked_ingredients"`
+	DailyKcal           int    `db:"daily_kcal"`
+}
+
+// ProfileOverride ignores the diet profile of the user in the request it is sent with
+type ProfileOverride struct {
+	IgnoreProfile bool `query:"ignore_profile"`
+}
+
+func DietProfileToAPI(profile *DietProfileDB) *DietProfile {
+	return &DietProfile{
+		ExcludedAllergens:   list(profile.ExcludedAllergens),
+		Diets:               list(profile.Diets),
+		DislikedIngredients: list(profile.DislikedIngredients),
+		DailyKcal:           profile.DailyKcal,
+	}
+}
+
+func DietProfileFromAPI(userID string, profile *DietProfile) *DietProfileDB {
+	return &DietProfileDB{
+		UserId:              userID,
+		ExcludedAllergens:   strings.Join(profile.ExcludedAllergens, ","),
+		Diets:               strings.Join(profile.Diets, ","),
+		DislikedIngredients: strings.Join(profile.DislikedIngredients, ","),
+		DailyKcal:           profile.DailyKcal,
+	}
+}
+
+func list(column string) []string {
+	if column == "" {
+		return []string{}
+	}
+	return strings.Split(column, ",")
+}
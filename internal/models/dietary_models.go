@@ -0,0 +1,123 @@
+package models
+
+// MealAllergens are the keys of the 14 allergens of the EU Regulation 1169/2011, in the order of its annex
+var MealAllergens = []string{
+	"gluten", "crustaceos", "huevos", "pescado", "cacahuetes", "soja", "lacteos",
+	"frutos_de_cascara", "apio", "mostaza", "sesamo", "sulfitos", "altramuces", "moluscos",
+}
+
+// MealDiets are the keys of the diets a meal can be suitable for
+var MealDiets = []string{"vegetariana", "vegana", "pescetariana"}
+
+var (
+	vegan       = []string{"vegetariana", "vegana", "pescetariana"}
+	vegetarian  = []string{"vegetariana", "pescetariana"}
+	pescatarian = []string{"pescetariana"}
+	noDiet      = []string{}
+)
+
+// categoryAllergens are the allergens of the ingredients of a category, unless the ingredient has its own
+var categoryAllergens = map[string][]string{
+	"Lácteos":  {"lacteos"},
+	"Pescados": {"pescado"},
+	"Huevos":   {"huevos"},
+}
+
+// ingredientAllergens are the allergens of the ingredients that do not have the ones of their category
+var ingredientAllergens = map[string][]string{
+	"Apio":                        {"apio"},
+	"Brotes de Soja":              {"soja"},
+	"Uva pasa":                    {"sulfitos"},
+	"Flan de huevo":               {"huevos", "lacteos"},
+	"Mousse":                      {"huevos", "lacteos"},
+	"Almejas":                     {"moluscos"},
+	"Calamar":                     {"moluscos"},
+	"Mejillón":                    {"moluscos"},
+	"Ostras":                      {"moluscos"},
+	"Pulpo":                       {"moluscos"},
+	"Cangrejo":                    {"crustaceos"},
+	"Gambas":                      {"crustaceos"},
+	"Langosta":                    {"crustaceos"},
+	"Langostino":                  {"crustaceos"},
+	"Avena":                       {"gluten"},
+	"Cebada":                      {"gluten"},
+	"Centeno":                     {"gluten"},
+	"Cereales con chocolate":      {"gluten", "lacteos"},
+	"Cereales desayuno, con miel": {"gluten"},
+	"Harina de trigo integral":    {"gluten"},
+	"Harina de trigo refinada":    {"gluten"},
+	"Pan de centeno":              {"gluten"},
+	"Pan de trigo blanco":         {"gluten"},
+	"Pan de trigo integral":       {"gluten"},
+	"Pan de trigo molde blanco":   {"gluten"},
+	"Pan de trigo molde integral": {"gluten"},
+	"Pasta al huevo":              {"gluten", "huevos"},
+	"Pasta de sémola":             {"gluten"},
+	"Sémola de trigo":             {"gluten"},
+	"Bechamel":                    {"gluten", "lacteos"},
+	"Caldos concentrados":         {"apio"},
+	"Mayonesa":                    {"huevos"},
+	"Mayonesa light":              {"huevos"},
+	"Mostaza":                     {"mostaza"},
+	"Salsa de soja":               {"gluten", "soja"},
+	"Vinagres":                    {"sulfitos"},
+}
+
+// categoryDiets are the diets the ingredients of a category are suitable for, unless the ingredient has its own
+var categoryDiets = map[string][]string{
+	"Verduras":          vegan,
+	"Frutas":            vegan,
+	"Pastas y Cereales": vegan,
+	"Legumbres":         vegan,
+	"Salsas":            vegan,
+	"Lácteos":           vegetarian,
+	"Huevos":            vegetarian,
+	"Pescados":          pescatarian,
+	"Carnes":            noDiet,
+}
+
+// ingredientDiets are the diets of the ingredients that are not suitable for the ones of their category
+var ingredientDiets = map[string][]string{
+	"Cereales con chocolate":      vegetarian,
+	"Cereales desayuno, con miel": vegetarian,
+	"Pasta al huevo":              vegetarian,
+	"Bechamel":                    vegetarian,
+	"Mayonesa":                    vegetarian,
+	"Mayonesa light":              vegetarian,
+	"Caldos concentrados":         noDiet,
+}
+
+// AllergenWords are the words, in singular and folded, that reveal the allergens of the ingredients not in
+// the catalog, e.g. "queso rallado" has lacteos
+var AllergenWords = map[string][]string{
+	"gluten":            {"trigo", "pan", "harina", "pasta", "espagueti", "macarron", "fideo", "cuscus", "seitan", "galleta", "bizcocho", "cebada", "centeno", "espelta", "avena"},
+	"crustaceos":        {"gamba", "langostino", "langosta", "cangrejo", "cigala", "bogavante", "carabinero", "necora"},
+	"huevos":            {"huevo", "mayonesa", "clara", "yema"},
+	"pescado":           {"pescado", "atun", "salmon", "merluza", "bacalao", "sardina", "anchoa", "boqueron", "dorada", "lubina", "trucha", "rape"},
+	"cacahuetes":        {"cacahuete", "mani"},
+	"soja":              {"soja", "tofu", "edamame", "tempeh", "miso"},
+	"lacteos":           {"leche", "queso", "nata", "mantequilla", "yogur", "requeson", "kefir"},
+	"frutos_de_cascara": {"nuez", "nueces", "almendra", "avellana", "anacardo", "pistacho", "pinon", "macadamia", "pecana"},
+	"apio":              {"apio"},
+	"mostaza":           {"mostaza"},
+	"sesamo":            {"sesamo", "tahini"},
+	"sulfitos":          {"vino", "vinagre"},
+	"altramuces":        {"altramuz", "altramuces"},
+	"moluscos":          {"almeja", "mejillon", "calamar", "pulpo", "sepia", "chipiron", "ostra", "berberecho", "caracol", "vieira"},
+}
+
+// IngredientAllergens returns the allergens of an ingredient of the catalog in the category indicated
+func IngredientAllergens(category, ingredient string) []string {
+	if allergens, ok := ingredientAllergens[ingredient]; ok {
+		return allergens
+	}
+	return categoryAllergens[category]
+}
+
+// IngredientDiets returns the diets an ingredient of the catalog in the category indicated is suitable for
+func IngredientDiets(category, ingredient string) []string {
+	if diets, ok := ingredientDiets[ingredient]; ok {
+		return diets
+	}
+	return categoryDiets[category]
+}
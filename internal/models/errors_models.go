@@ -0,0 +1,8 @@
+package models
+
+// FieldError is a field of a request body that did not pass the validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// Status of the service and of each check
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthUp       = "up"
+	HealthDown     = "down"
+)
+
+// Health is the state of the service along with the checks done to find it out
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of checking the database or a dependency of the service
+type HealthCheck struct {
+	Status     string  `json:"status"`
+	LatencyMs  float64 `json:"latency_ms"`
+	URL        string  `json:"url,omitempty"`
+	HTTPStatus int     `json:"http_status,omitempty"`
+	Version    int     `json:"version,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
@@ -0,0 +1,59 @@
+package models
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// MealBatch is a list of operations over the meals of a user executed in a single transaction.
+// In atomic mode (default) the first failure rolls back the whole batch, in best_effort mode only the failed operation
+type MealBatch struct {
+	Mode       string               `json:"mode" validate:"omitempty,oneof=atomic best_effort"`
+	Operations []MealBatchOperation `json:"operations" validate:"required,min=1,max=100"`
+}
+
+type MealBatchOperation struct {
+	Op      string `json:"op"`
+	Id      string `json:"id,omitempty"`
+	IfMatch string `json:"if_match,omitempty"`
+	Meal    *Meal  `json:"meal,omitempty"`
+}
+
+type MealBatchResult struct {
+	Mode      string                `json:"mode"`
+	Committed bool                  `json:"committed"`
+	Results   []MealBatchItemResult `json:"results"`
+}
+
+type MealBatchItemResult struct {
+	Index  int        `json:"index"`
+	Op     string     `json:"op"`
+	Id     string     `json:"id,omitempty"`
+	Status int        `json:"status"`
+	ETag   string     `json:"etag,omitempty"`
+	Meal   *Meal      `json:"meal,omitempty"`
+	Error  *ItemError `json:"error,omitempty"`
+	// Warning is the error of the changes made after the operation was committed, e.g. on the calendar
+	Warning *ItemError `json:"warning,omitempty"`
+}
+
+// ItemError is the error of a single item of a bulk operation, with the same status and code as the API errors
+type ItemError struct {
+	Status  int          `json:"status"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Detail  string       `json:"detail,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+
+	// Err is the error reported, kept to report it again in the language of the request
+	Err error `json:"-"`
+}
+
+// Failed reports if the operation did not succeed
+func (r *MealBatchItemResult) Failed() bool {
+	return r.Error != nil
+}
@@ -0,0 +1,23 @@
+package models
+
+const (
+	// MaxImageBytes is the size limit of the uploaded images
+	MaxImageBytes = 5 << 20
+	// MaxImageSide is the largest side of the stored original, larger images are scaled down to it
+	MaxImageSide = 2048
+
+	ImageSizeOriginal = "original"
+)
+
+// ImageSizes are the sides of the squares the thumbnails of the images fit in, by size
+var ImageSizes = map[string]int{
+	"small":  150,
+	"medium": 400,
+	"large":  800,
+}
+
+// MealImage is the image of a meal, with the URLs of the original and of its thumbnails by size
+type MealImage struct {
+	Image      string            `json:"image"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
@@ -1,6 +1,12 @@
 package models
 
-import "strings"
+import (
+	"database/sql"
+	"html"
+	"math"
+	"meals/pkg/text"
+	"strings"
+)
 
 var Vegetables = map[string]int{
 	"Aceitunas negras": 349, "Aceitunas verdes": 132, "Acelgas": 33, "Ajos": 169, "Alcachofas": 64, "Apio": 20, "Berenjena": 29, "Berros": 21, "Brócoli": 31, "Calabacín": 31, "Calabaza": 24, "Cebolla": 47, "Cebolla tierna": 39, "Champiñón y otras setas": 28, "Col": 28, "Col de Bruselas": 54, "Coliflor": 30, "Endibia": 22, "Escarola": 37, "Espárragos": 26, "Espárragos en lata": 24, "Espinaca": 32, "Espinacas congeladas": 25, "Habas tiernas": 64, "Hinojo": 16, "Lechuga": 18, "Nabos": 29, "Pepino": 12, "Perejil": 55, "Pimiento": 22, "Porotos verdes": 21, "Puerros": 42, "Rábanos": 20, "Remolacha": 40, "Repollo": 19, "Rúcula": 37, "Brotes de Soja": 50, "Tomate triturado en conserva": 39, "Tomates": 22, "Trufa": 92, "Zanahoria": 42, "Zumo de tomate": 21,
@@ -42,6 +48,8 @@ var Ingredients = map[string]map[string]int{
 }
 
 type MealDB struct {
+	// SearchId is the key of the meal in the full-text search
+	SearchId    int64  `db:"search_id" json:"-"`
 	Id          string `db:"id" json:"id,omitempty"`
 	UserId      string `db:"user_id" json:"userId"`
 	Name        string `db:"name" json:"name" validate:"required"`
@@ -51,25 +59,121 @@ type MealDB struct {
 	Ingredients string `db:"ingredients" json:"ingredients" validate:"required"`
 	Kcal        int    `db:"kcal" json:"kcal"`
 	Seasons     string `db:"seasons" json:"seasons"`
+	Servings    int    `db:"servings" json:"servings"`
+	PrepTime    int    `db:"prep_time" json:"prep_time"`
+	CookTime    int    `db:"cook_time" json:"cook_time"`
+	Difficulty  string `db:"difficulty" json:"difficulty"`
+	Equipment   string `db:"equipment" json:"equipment"`
+	Version     int    `db:"version" json:"version"`
+	Snippet     string `db:"snippet" json:"-"` // Only selected when searching
+	// Allergens and Diets are NULL until the meal is tagged
+	Allergens sql.NullString `db:"allergens" json:"-"`
+	Diets     sql.NullString `db:"diets" json:"-"`
 }
 
 type Meal struct {
-	Id          string   `json:"id"`
-	UserId      string   `json:"user_id"`
-	Name        string   `json:"name" validate:"required"`
-	Description string   `json:"description"`
-	Image       string   `json:"image"`
-	Type        string   `json:"type" validate:"required,oneof=semanal ocasional normal"`
-	Ingredients []string `json:"ingredients"`
-	Kcal        int      `json:"kcal"`
-	Seasons     []string `json:"seasons" validate:"required,dive,oneof=primavera verano otoño invierno general"`
+	Id          string `json:"id"`
+	UserId      string `json:"user_id"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	Type        string `json:"type" validate:"required,oneof=semanal ocasional normal"`
+	// Ingredients are stored separated by IngredientsSeparator, which they can not have
+	Ingredients []string `json:"ingredients" validate:"dive,excludesall=0x7C"`
+	// Kcal are the kcal of a serving, KcalTotal the ones of all the servings of the recipe
+	Kcal      int      `json:"kcal"`
+	KcalTotal int      `json:"kcal_total"`
+	Seasons   []string `json:"seasons" validate:"required,dive,oneof=primavera verano otoño invierno general"`
+	// Servings of the recipe, 1 when not sent
+	Servings int `json:"servings" validate:"omitempty,min=1,max=100"`
+	// Steps are stored in their own table, in order. The times are in minutes, TotalTime being their sum
+	Steps      []Step   `json:"steps" validate:"max=100,dive"`
+	PrepTime   int      `json:"prep_time" validate:"min=0,max=1440"`
+	CookTime   int      `json:"cook_time" validate:"min=0,max=1440"`
+	TotalTime  int      `json:"total_time"`
+	Difficulty string   `json:"difficulty" validate:"omitempty,oneof=facil media dificil"`
+	Equipment  []string `json:"equipment" validate:"max=50,dive,max=100,excludesall=0x2C"`
+	Version    int      `json:"-"` // Exposed through the ETag header
+	// Allergens and Diets are derived from the ingredients of the catalog of the meal, whatever is sent
+	Allergens []string `json:"allergens"`
+	Diets     []string `json:"diets"`
+	// Snippet is the text that matched the search as HTML, with the words found between <mark> tags
+	Snippet string `json:"snippet,omitempty"`
+	// ProfileConflicts are the reasons the meal does not fit the diet profile of the user, only sent on create
+	ProfileConflicts []string `json:"profile_conflicts,omitempty"`
+}
+
+// SnippetMarkStart and SnippetMarkEnd surround the words found in the snippets of the repository, control
+// characters that can not be in the text of a meal, so it is escaped before they are turned into <mark> tags
+const (
+	SnippetMarkStart = "\x02"
+	SnippetMarkEnd   = "\x03"
+)
+
+// IngredientsSeparator separates the ingredients stored in a meal, as the names of the catalog have commas,
+// e.g. "Cerdo, lomo"
+const IngredientsSeparator = "|"
+
+const (
+	MIMEMergePatch = "application/merge-patch+json" // RFC 7396
+	MIMEJSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// MealPatch is a partial update of a meal, either a JSON Merge Patch or a JSON Patch document
+type MealPatch struct {
+	ContentType string
+	Body        []byte
 }
 
 type MealsFilters struct {
-	Name    *string  `query:"name"`
-	Type    *string  `query:"type"`
-	Healthy *bool    `query:"healthy"`
-	Season  []string `query:"[]season"`
+	// AllergenFree leaves out the meals with any of the allergens, along with the ones not tagged yet
+	AllergenFree []string `query:"allergen_free[]"`
+	Diet         *string  `query:"diet"`
+	// Diets are required along with Diet, taken from the diet profile of the user
+	Diets []string
+	// Q searches the words in the name, description and ingredients, the results sorted by relevance
+	Q            *string  `query:"q"`
+	Name         *string  `query:"name"`
+	Type         *string  `query:"type"`
+	Healthy      *bool    `query:"healthy"`
+	Season       []string `query:"season[]"`
+	LegacySeason []string `query:"[]season"` // Deprecated: use season[]
+	// IngredientsAny, IngredientsAll and IngredientsNone are repeated, matching whole ingredients
+	IngredientsAny  []string `query:"ingredients_any"`
+	IngredientsAll  []string `query:"ingredients_all"`
+	IngredientsNone []string `query:"ingredients_none"`
+	KcalMin         *int     `query:"kcal_min"`
+	KcalMax         *int     `query:"kcal_max"`
+	// MaxTotalTime leaves out the meals that take longer, along with the ones without times
+	MaxTotalTime *int `query:"max_total_time"`
+	ProfileOverride
+}
+
+// Seasons returns the seasons to filter by, sent either as season[] or as the legacy []season
+func (f MealsFilters) Seasons() []string {
+	return append(append([]string{}, f.Season...), f.LegacySeason...)
+}
+
+// ListValues returns the values of a query parameter sent repeated, without the empty ones. They are not split
+// on commas, as the names of the catalog have them
+func ListValues(values []string) []string {
+	var list []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// HasIngredient tells whether the ingredient is a whole one of the ingredients, ignoring the case and the accents
+func HasIngredient(ingredients []string, ingredient string) bool {
+	for _, i := range ingredients {
+		if text.Fold(i) == text.Fold(ingredient) {
+			return true
+		}
+	}
+	return false
 }
 
 func MealToAPI(meal *MealDB) *Meal {
@@ -80,12 +184,30 @@ func MealToAPI(meal *MealDB) *Meal {
 		Description: meal.Description,
 		Image:       meal.Image,
 		Type:        meal.Type,
-		Ingredients: strings.Split(meal.Ingredients, ","),
+		Ingredients: strings.Split(meal.Ingredients, IngredientsSeparator),
 		Kcal:        meal.Kcal,
 		Seasons:     strings.Split(meal.Seasons, ","),
+		Servings:    meal.Servings,
+		KcalTotal:   meal.Kcal * meal.Servings,
+		Steps:       []Step{},
+		PrepTime:    meal.PrepTime,
+		CookTime:    meal.CookTime,
+		TotalTime:   meal.PrepTime + meal.CookTime,
+		Difficulty:  meal.Difficulty,
+		Equipment:   list(meal.Equipment),
+		Version:     meal.Version,
+		Allergens:   tags(meal.Allergens),
+		Diets:       tags(meal.Diets),
+		Snippet:     highlight(meal.Snippet),
 	}
 }
 
+// highlight returns the snippet of a search as HTML, escaping the text of the meal and putting the words
+// found between <mark> tags
+func highlight(snippet string) string {
+	return strings.NewReplacer(SnippetMarkStart, "<mark>", SnippetMarkEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
+
 func MealFromAPI(meal *Meal) *MealDB {
 	return &MealDB{
 		Id:          meal.Id,
@@ -94,12 +216,39 @@ func MealFromAPI(meal *Meal) *MealDB {
 		Description: meal.Description,
 		Image:       meal.Image,
 		Type:        meal.Type,
-		Ingredients: strings.Join(meal.Ingredients, ","),
+		Ingredients: strings.Join(meal.Ingredients, IngredientsSeparator),
 		Kcal:        meal.Kcal,
 		Seasons:     strings.Join(meal.Seasons, ","),
+		Servings:    meal.Servings,
+		PrepTime:    meal.PrepTime,
+		CookTime:    meal.CookTime,
+		Difficulty:  meal.Difficulty,
+		Equipment:   strings.Join(meal.Equipment, ","),
+		Version:     meal.Version,
+		Allergens:   tagsColumn(meal.Allergens),
+		Diets:       tagsColumn(meal.Diets),
 	}
 }
 
+// tags returns the tags stored in a column, nil when the meal is not tagged yet
+func tags(column sql.NullString) []string {
+	if !column.Valid {
+		return nil
+	}
+	if column.String == "" {
+		return []string{}
+	}
+	return strings.Split(column.String, ",")
+}
+
+// tagsColumn returns the column of the tags indicated, NULL when there are not
+func tagsColumn(tags []string) sql.NullString {
+	if tags == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: strings.Join(tags, ","), Valid: true}
+}
+
 type ExternalMeals struct {
 	Hits []Hits `json:"hits"`
 }
@@ -113,15 +262,21 @@ type Recipe struct {
 	Imagen      string   `json:"image"`
 	Description string   `json:"url"`
 	Kcal        float64  `json:"calories"`
+	Yield       float64  `json:"yield"`
 	Ingredients []string `json:"ingredientLines"`
 }
 
 type ExternalMealFilter struct {
 	Q string `query:"q"`
+	// UserId applies the diet profile of the user to the search
+	UserId string `query:"user_id"`
+	ProfileOverride
 }
 
+// FromExternalToInternal returns the meal of an external recipe. The calories of the recipe are the ones of
+// all its yield, which is rounded to the servings
 func FromExternalToInternal(meal Recipe) Meal {
-	return Meal{
+	converted := Meal{
 		Name:        meal.Name,
 		Image:       meal.Imagen,
 		Description: meal.Description,
@@ -129,5 +284,21 @@ func FromExternalToInternal(meal Recipe) Meal {
 		Seasons:     []string{"general"},
 		Type:        "normal",
 		Ingredients: meal.Ingredients,
+		Servings:    1,
+	}
+	if servings := int(math.Round(meal.Yield)); servings > 0 {
+		converted.Servings = servings
+		converted.Kcal = int(math.Round(meal.Kcal / meal.Yield))
 	}
+	converted.KcalTotal = converted.Kcal * converted.Servings
+	return converted
+}
+
+// MaxServings limits the servings of a recipe
+const MaxServings = 100
+
+// MealQuery are the options of the representation of a meal
+type MealQuery struct {
+	// Servings scales the recipe to the servings indicated
+	Servings *int `query:"servings"`
 }
@@ -0,0 +1,58 @@
+package models
+
+const (
+	// PlanDateLayout is the format of the dates of a plan
+	PlanDateLayout = "2006-01-02"
+	// PlanMaxDays limits the days of a plan
+	PlanMaxDays = 31
+
+	PlanDefaultSlotsPerDay  = 2
+	PlanDefaultNoRepeatDays = 3
+)
+
+// PlanDefaultMaxPerWeek is the times a meal of every type can be planned in the same week (Monday to Sunday)
+// when the request does not indicate it. The types not present are not limited
+var PlanDefaultMaxPerWeek = map[string]int{
+	"ocasional": 1,
+	"semanal":   2,
+}
+
+// MealPlanRequest asks for a plan of the meals of a user between two dates, both included. The same seed
+// generates the same plan from the same meals
+type MealPlanRequest struct {
+	From         string         `json:"from" validate:"required,datetime=2006-01-02"`
+	To           string         `json:"to" validate:"required,datetime=2006-01-02"`
+	SlotsPerDay  int            `json:"slots_per_day" validate:"omitempty,min=1,max=6"`
+	DailyKcal    int            `json:"daily_kcal" validate:"omitempty,min=1,max=10000"`
+	NoRepeatDays *int           `json:"no_repeat_days" validate:"omitempty,min=0,max=30"`
+	MaxPerWeek   map[string]int `json:"max_per_week" validate:"omitempty,dive,keys,oneof=semanal ocasional normal,endkeys,min=0"`
+	Seed         *int64         `json:"seed"`
+	// ProfileOverride is sent in the query
+	ProfileOverride `json:"-"`
+}
+
+// MealPlan is the meals planned for every day. The slots that no meal could fill respecting the rules are
+// left without meal and counted as unfilled
+type MealPlan struct {
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	Seed         int64          `json:"seed"`
+	SlotsPerDay  int            `json:"slots_per_day"`
+	DailyKcal    int            `json:"daily_kcal,omitempty"`
+	NoRepeatDays int            `json:"no_repeat_days"`
+	MaxPerWeek   map[string]int `json:"max_per_week"`
+	Unfilled     int            `json:"unfilled"`
+	Days         []MealPlanDay  `json:"days"`
+}
+
+type MealPlanDay struct {
+	Date   string         `json:"date"`
+	Season string         `json:"season"`
+	Kcal   int            `json:"kcal"`
+	Slots  []MealPlanSlot `json:"slots"`
+}
+
+type MealPlanSlot struct {
+	Slot int   `json:"slot"`
+	Meal *Meal `json:"meal,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+const (
+	ImportConflictSkip      = "skip"
+	ImportConflictRename    = "rename"
+	ImportConflictOverwrite = "overwrite"
+
+	ImportStatusCreated = "created"
+	ImportStatusRenamed = "renamed"
+	ImportStatusUpdated = "updated"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
+type MealsExportFilter struct {
+	Format string `query:"format"`
+}
+
+type MealsImportFilter struct {
+	Format     string `query:"format"`
+	OnConflict string `query:"on_conflict"`
+}
+
+// MealImportReport is the result of importing a file of meals, with the outcome of every row
+type MealImportReport struct {
+	Format     string           `json:"format"`
+	OnConflict string           `json:"on_conflict"`
+	Total      int              `json:"total"`
+	Created    int              `json:"created"`
+	Updated    int              `json:"updated"`
+	Skipped    int              `json:"skipped"`
+	Failed     int              `json:"failed"`
+	Items      []MealImportItem `json:"items"`
+}
+
+type MealImportItem struct {
+	Row    int        `json:"row"`
+	Name   string     `json:"name"`
+	Status string     `json:"status"`
+	Id     string     `json:"id,omitempty"`
+	Error  *ItemError `json:"error,omitempty"`
+	// Warning is the error of the changes made after the import was committed, e.g. on the calendar
+	Warning *ItemError `json:"warning,omitempty"`
+}
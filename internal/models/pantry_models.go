@@ -0,0 +1,29 @@
+package models
+
+// PantryItem is an ingredient the user has at home. The ingredient is stored with its name in the catalog
+// when it is in it, so it matches the ingredients of the meals
+type PantryItem struct {
+	Id         string `db:"id" json:"id"`
+	UserId     string `db:"user_id" json:"-"`
+	Ingredient string `db:"ingredient" json:"ingredient" validate:"required,max=100"`
+	Quantity   string `db:"quantity" json:"quantity,omitempty" validate:"max=50"`
+	ExpiresOn  string `db:"expires_on" json:"expires_on,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Category   string `db:"-" json:"category,omitempty"`
+}
+
+type CookableFilter struct {
+	// ExpiringWithin ranks first the meals that use the items expiring within the days indicated
+	ExpiringWithin *int `query:"expiring_within"`
+	// MaxMissing leaves out the meals that miss more ingredients
+	MaxMissing *int `query:"max_missing"`
+}
+
+// CookableMeal is a meal of the user along with the ingredients of it that are in the pantry and the missing ones.
+// Expired items are not available
+type CookableMeal struct {
+	Meal      *Meal    `json:"meal"`
+	Available []string `json:"available"`
+	Missing   []string `json:"missing"`
+	Expiring  []string `json:"expiring,omitempty"`
+	Coverage  float64  `json:"coverage"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// ShoppingCategoryOther groups the ingredients that are not in the catalog
+const ShoppingCategoryOther = "Otros"
+
+// ShoppingListRequest asks for the ingredients of some meals, either indicated by their ids or the ones
+// of the calendar of the user between two dates, both included
+type ShoppingListRequest struct {
+	MealIds []string `json:"meal_ids" validate:"max=100,dive,required"`
+	From    string   `json:"from" validate:"required_with=To,omitempty,datetime=2006-01-02"`
+	To      string   `json:"to" validate:"required_with=From,omitempty,datetime=2006-01-02"`
+}
+
+type ShoppingListFilter struct {
+	Format string `query:"format"`
+}
+
+// ShoppingList is the ingredients of the meals, without repeats and grouped by the categories of the catalog
+type ShoppingList struct {
+	Language   string                 `json:"language"`
+	Meals      []ShoppingListMeal     `json:"meals"`
+	Categories []ShoppingListCategory `json:"categories"`
+}
+
+type ShoppingListMeal struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Times int    `json:"times"`
+}
+
+type ShoppingListCategory struct {
+	Key   string             `json:"key"`
+	Name  string             `json:"name"`
+	Items []ShoppingListItem `json:"items"`
+}
+
+// ShoppingListItem is an ingredient along with the meals that need it. The quantities are summed by
+// unit when the meals carry them, e.g. "200 g de calabacín"
+type ShoppingListItem struct {
+	Key        string             `json:"key"`
+	Name       string             `json:"name"`
+	Times      int                `json:"times"`
+	Quantities []ShoppingQuantity `json:"quantities,omitempty"`
+	Meals      []string           `json:"meals"`
+}
+
+type ShoppingQuantity struct {
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit,omitempty"`
+}
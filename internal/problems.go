@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"meals/internal/i18n"
+	"meals/internal/models"
+	"meals/pkg/logging"
+	"net/http"
+	"strings"
+)
+
+// problemTypePrefix builds the type of the problems from their code, e.g. urn:amc:meals:MEAL_NOT_FOUND
+const problemTypePrefix = "urn:amc:meals:"
+
+// Keys of the details of the errors, the messages are in the details group of the bundles
+const (
+	DetailCause                 = "cause"
+	DetailMeal                  = "meal"
+	DetailCalendar              = "calendar"
+	DetailOnConflictNotValid    = "on_conflict_not_valid"
+	DetailPlanToBeforeFrom      = "plan_to_before_from"
+	DetailPlanTooLong           = "plan_too_long"
+	DetailShoppingListRange     = "shopping_list_range"
+	DetailPantryFiltersNegative = "pantry_filters_negative"
+	DetailKcalNegative          = "kcal_negative"
+	DetailKcalRange             = "kcal_range"
+	DetailMaxTotalTimeNegative  = "max_total_time_negative"
+	DetailAllergenNotValid      = "allergen_not_valid"
+	DetailDietNotValid          = "diet_not_valid"
+	DetailServingsRange         = "servings_range"
+	DetailStepEmpty             = "step_empty"
+	DetailStepTimerTooLong      = "step_timer_too_long"
+	DetailImageTooManyPixels    = "image_too_many_pixels"
+	DetailImageNotReadable      = "image_not_readable"
+	DetailImageSizeNotSupported = "image_size_not_supported"
+)
+
+// ErrorResponse is an error of the API as an RFC 7807 problem details document
+type ErrorResponse struct {
+	Type          string              `json:"type"`
+	Title         string              `json:"title"`
+	Status        int                 `json:"status"`
+	Code          string              `json:"code"`
+	Detail        string              `json:"detail,omitempty"`
+	Instance      string              `json:"instance,omitempty"`
+	CorrelationID string              `json:"correlation_id,omitempty"`
+	Errors        []models.FieldError `json:"errors,omitempty"`
+}
+
+func (e *ErrorResponse) Error() string {
+	return fmt.Sprintf("Error %d %s: %s", e.Status, e.Code, e.Detail)
+}
+
+// APIError is how the API reports an error
+type APIError struct {
+	Err    error
+	Status int
+	Code   string
+}
+
+// Message returns the message of the error in the language indicated
+func (e APIError) Message(lang string) string {
+	if message, ok := i18n.Lookup(lang, i18n.GroupErrors, e.Code); ok {
+		return message
+	}
+	return e.Err.Error()
+}
+
+// ErrorFor returns how the API reports the error indicated. Errors are matched by identity, so wrapped
+// errors keep their status and code. Unknown errors are reported as ErrSomethingWentWrong
+func ErrorFor(err error) APIError {
+	for _, apiErr := range apiErrors {
+		if errors.Is(err, apiErr.Err) {
+			return apiErr
+		}
+	}
+	return ErrorFor(ErrSomethingWentWrong)
+}
+
+// detailedError adds the details of an occurrence to one of the errors of the API, as the key of a
+// message of the details group and its arguments
+type detailedError struct {
+	err  error
+	key  string
+	args []any
+}
+
+func (e *detailedError) Error() string {
+	return e.err.Error() + ": " + e.detail(i18n.Default)
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.err
+}
+
+func (e *detailedError) detail(lang string) string {
+	return i18n.Message(lang, i18n.GroupDetails, e.key, e.args...)
+}
+
+// WithDetail returns err with the explanation of this occurrence, reported as the detail of the problem
+// in the language of the request. The key is the one of the message in the details group, formatted with args
+func WithDetail(err error, key string, args ...any) error {
+	return &detailedError{err: err, key: key, args: args}
+}
+
+// WithCause returns err with the message of cause as its detail, for the causes that come from a library
+// and are not translated, e.g. the errors of the binder
+func WithCause(err error, cause error) error {
+	return WithDetail(err, DetailCause, cause.Error())
+}
+
+// WrongBody wraps the errors of the validator in ErrWrongBody, so every failed field is reported
+func WrongBody(err error) error {
+	return fmt.Errorf("%w: %w", ErrWrongBody, err)
+}
+
+// Detail returns the explanation of an occurrence of an error, by default the message of the error of the API
+func Detail(lang string, err error) string {
+	var detailed *detailedError
+	if errors.As(err, &detailed) {
+		return detailed.detail(lang)
+	}
+	return ErrorFor(err).Message(lang)
+}
+
+// FieldErrors returns the fields that did not pass the validation when err wraps validator errors
+func FieldErrors(lang string, err error) []models.FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+	fields := make([]models.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		field := fe.Namespace()
+		// The namespace starts with the name of the struct validated
+		if i := strings.IndexByte(field, '.'); i >= 0 {
+			field = field[i+1:]
+		}
+		fields = append(fields, models.FieldError{Field: field, Code: fe.Tag(), Message: fieldMessage(lang, fe)})
+	}
+	return fields
+}
+
+func fieldMessage(lang string, fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return i18n.Message(lang, i18n.GroupFields, fe.Tag())
+	case "oneof":
+		// The values are the canonical keys, which are the ones accepted whatever the language
+		return i18n.Message(lang, i18n.GroupFields, fe.Tag(), strings.Join(strings.Fields(fe.Param()), ", "))
+	case "min", "max":
+		return i18n.Message(lang, i18n.GroupFields, fe.Tag(), fe.Param())
+	case "datetime":
+		return i18n.Message(lang, i18n.GroupFields, fe.Tag())
+	default:
+		return i18n.Message(lang, i18n.GroupFields, "default")
+	}
+}
+
+// NewItemError returns the error of an item of a bulk operation as the API would report it in the language indicated
+func NewItemError(lang string, err error) *models.ItemError {
+	apiErr := ErrorFor(err)
+	itemErr := &models.ItemError{
+		Status:  apiErr.Status,
+		Code:    apiErr.Code,
+		Message: apiErr.Message(lang),
+		Errors:  FieldErrors(lang, err),
+		Err:     err,
+	}
+	if detail := Detail(lang, err); detail != itemErr.Message {
+		itemErr.Detail = detail
+	}
+	return itemErr
+}
+
+// LocalizeItemError reports the error of an item of a bulk operation in the language of the request
+func LocalizeItemError(c echo.Context, itemErr *models.ItemError) *models.ItemError {
+	if itemErr == nil || itemErr.Err == nil {
+		return itemErr
+	}
+	return NewItemError(Language(c), itemErr.Err)
+}
+
+// CorrelationID returns the id of the request, the X-Request-ID sent by the client or a new one.
+// It is also returned in the X-Request-ID header of the response
+func CorrelationID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	if id := logging.RequestID(c.Request().Context()); id != "" {
+		c.Response().Header().Set(echo.HeaderXRequestID, id)
+		return id
+	}
+	id := c.Request().Header.Get(echo.HeaderXRequestID)
+	if id == "" {
+		id = ulid.Make().String()
+	}
+	c.Response().Header().Set(echo.HeaderXRequestID, id)
+	return id
+}
+
+// HTTPErrorHandler responds with the problem+json document of the errors not handled by the handlers, as
+// the routes or methods not found, the binding errors and the panics recovered
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.Code {
+		case http.StatusNotFound:
+			err = ErrRouteNotFound
+		case http.StatusMethodNotAllowed:
+			err = ErrMethodNotAllowed
+		case http.StatusBadRequest:
+			err = WithCause(ErrWrongBody, httpErr)
+		case http.StatusRequestEntityTooLarge:
+			err = ErrFileTooLarge
+		}
+	}
+	_ = NewErrorResponse(c, err)
+}
+
+// NewErrorResponse responds with the problem+json document of the error
+func NewErrorResponse(c echo.Context, err error) error {
+	apiErr, lang := ErrorFor(err), Language(c)
+	problem := &ErrorResponse{
+		Type:          problemTypePrefix + apiErr.Code,
+		Title:         apiErr.Message(lang),
+		Status:        apiErr.Status,
+		Code:          apiErr.Code,
+		Detail:        Detail(lang, err),
+		Instance:      c.Request().URL.Path,
+		CorrelationID: CorrelationID(c),
+		Errors:        FieldErrors(lang, err),
+	}
+	if apiErr.Err == ErrSomethingWentWrong && err != ErrSomethingWentWrong {
+		// Unknown errors are logged, but not shown to the client
+		logging.FromContext(c.Request().Context()).Error("unexpected error", "error", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	if err := c.JSON(problem.Status, problem); err != nil {
+		return err
+	}
+	return problem
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/database"
+	"meals/pkg/logging"
+)
+
+const (
+	getDietProfile = "SELECT * FROM diet_profiles WHERE user_id = ?"
+	putDietProfile = `INSERT INTO diet_profiles(user_id,excluded_allergens,diets,disliked_ingredients,daily_kcal) VALUES (?,?,?,?,?)
+		ON CONFLICT(user_id) DO UPDATE SET excluded_allergens = excluded.excluded_allergens, diets = excluded.diets,
+		disliked_ingredients = excluded.disliked_ingredients, daily_kcal = excluded.daily_kcal`
+	deleteDietProfile = "DELETE FROM diet_profiles WHERE user_id = ?"
+)
+
+type DietProfileRepository interface {
+	GetDietProfile(ctx context.Context, userID string) (profile *models.DietProfile, err error)
+	PutDietProfile(ctx context.Context, userID string, profile models.DietProfile) (*models.DietProfile, error)
+	DeleteDietProfile(ctx context.Context, userID string) error
+}
+
+type SQLiteDietProfileRepository struct {
+	db *database.Database
+}
+
+func NewSQLiteDietProfileRepository(db *database.Database) *SQLiteDietProfileRepository {
+	return &SQLiteDietProfileRepository{
+		db: db,
+	}
+}
+
+func (r *SQLiteDietProfileRepository) GetDietProfile(ctx context.Context, userID string) (*models.DietProfile, error) {
+	ctx, end := observeRepository(ctx, "SQLiteDietProfileRepository", "GetDietProfile")
+	defer end()
+	var profiles []*models.DietProfileDB
+	if err := sqlx.SelectContext(ctx, r.db.Conn, &profiles, getDietProfile, userID); err != nil {
+		logging.FromContext(ctx).Error("getting the diet profile", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	if len(profiles) == 0 {
+		return nil, internal.ErrDietProfileNotFound
+	}
+	return models.DietProfileToAPI(profiles[0]), nil
+}
+
+// PutDietProfile creates the diet profile of the user or replaces the one it has
+func (r *SQLiteDietProfileRepository) PutDietProfile(ctx context.Context, userID string, profile models.DietProfile) (*models.DietProfile, error) {
+	ctx, end := observeRepository(ctx, "SQLiteDietProfileRepository", "PutDietProfile")
+	defer end()
+	row := models.DietProfileFromAPI(userID, &profile)
+	_, err := r.db.Conn.ExecContext(ctx, putDietProfile, row.UserId, row.ExcludedAllergens, row.Diets, row.DislikedIngredients, row.DailyKcal)
+	if err != nil {
+		logging.FromContext(ctx).Error("saving the diet profile", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	return models.DietProfileToAPI(row), nil
+}
+
+func (r *SQLiteDietProfileRepository) DeleteDietProfile(ctx context.Context, userID string) error {
+	ctx, end := observeRepository(ctx, "SQLiteDietProfileRepository", "DeleteDietProfile")
+	defer end()
+	result, err := r.db.Conn.ExecContext(ctx, deleteDietProfile, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("deleting the diet profile", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return internal.ErrDietProfileNotFound
+	}
+	return nil
+}
@@ -1,35 +1,74 @@
 package repositories
 
 import (
-	"fmt"
-	"github.com/labstack/gommon/log"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/jmoiron/sqlx"
 	"github.com/oklog/ulid/v2"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"math/rand"
 	"meals/internal"
+	"meals/internal/metrics"
 	"meals/internal/models"
 	"meals/pkg/database"
+	"meals/pkg/logging"
+	"meals/pkg/tracing"
+	"modernc.org/sqlite"
+	"strings"
 	"time"
+	"unicode"
 )
 
 const (
 	getMeal       = "SELECT * FROM meals WHERE user_id = ? AND id = ?"
 	getMealByName = "SELECT * FROM meals WHERE user_id = ? AND lower(name) = lower(?)"
 	listMeals     = "SELECT * FROM meals WHERE user_id = ? "
-	updateMeal    = "UPDATE meals SET name = ?, description = ?, image = ?, type = ?, ingredients = ?, kcal = ?, seasons = ? WHERE user_id = ? AND id = ?"
-	CreateMeal    = "INSERT INTO meals(id,user_id,name,description,image,type,ingredients,kcal,seasons) VALUES (?,?,?,?,?,?,?,?,?)"
-	deleteMeal    = "DELETE FROM meals WHERE user_id = ? AND id = ?"
+	// searchMeals lists the meals along with the snippet of the text that matched the search, the words found
+	// between the control characters of models.SnippetMarkStart and models.SnippetMarkEnd
+	searchMeals = "SELECT meals.*, snippet(meals_fts, -1, char(2), char(3), '…', 12) AS snippet FROM meals JOIN meals_fts ON meals_fts.rowid = meals.search_id WHERE meals.user_id = ? "
+	updateMeal  = "UPDATE meals SET name = ?, description = ?, image = ?, type = ?, ingredients = ?, kcal = ?, seasons = ?, servings = ?, prep_time = ?, cook_time = ?, difficulty = ?, equipment = ?, allergens = ?, diets = ?, version = version + 1 WHERE user_id = ? AND id = ? AND version = ?"
+	// CreateMeal inserts a meal not tagged yet, createMeal along with its tags
+	CreateMeal = "INSERT INTO meals(id,user_id,name,description,image,type,ingredients,kcal,seasons) VALUES (?,?,?,?,?,?,?,?,?)"
+	createMeal = "INSERT INTO meals(id,user_id,name,description,image,type,ingredients,kcal,seasons,servings,prep_time,cook_time,difficulty,equipment,allergens,diets) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	// untaggedMeals and tagMeal tag the meals created before the tags, a new version as their representation changes
+	untaggedMeals = "SELECT * FROM meals WHERE allergens IS NULL OR diets IS NULL"
+	tagMeal       = "UPDATE meals SET allergens = ?, diets = ?, version = version + 1 WHERE user_id = ? AND id = ? AND version = ?"
+	deleteMeal    = "DELETE FROM meals WHERE user_id = ? AND id = ? AND version = ?"
+	// The steps of a meal are replaced as a whole, in the order they are sent
+	listSteps   = "SELECT meal_id, position, text, timer_minutes FROM meal_steps WHERE user_id = ? ORDER BY meal_id, position"
+	getSteps    = "SELECT meal_id, position, text, timer_minutes FROM meal_steps WHERE user_id = ? AND meal_id = ? ORDER BY position"
+	createStep  = "INSERT INTO meal_steps(user_id,meal_id,position,text,timer_minutes) VALUES (?,?,?,?,?)"
+	deleteSteps = "DELETE FROM meal_steps WHERE user_id = ? AND meal_id = ?"
+	// hasIngredient matches a whole ingredient of the meal, as models.HasIngredient does
+	hasIngredient = "has_ingredient(meals.ingredients, ?)"
 )
 
+func init() {
+	// has_ingredient(ingredients, ingredient) is 1 when the ingredient is one of the stored ones, ignoring the case
+	// and the accents
+	sqlite.MustRegisterDeterministicScalarFunction("has_ingredient", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		ingredients, _ := args[0].(string)
+		ingredient, _ := args[1].(string)
+		if models.HasIngredient(strings.Split(ingredients, models.IngredientsSeparator), ingredient) {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	})
+}
+
 type MealRepository interface {
-	GetMeal(userID, mealID string) (meal *models.Meal, err error)
-	ListMeals(userID string, filters models.MealsFilters) (meals []*models.Meal, err error)
-	UpdateMeal(userID string, mealID string, mealPut models.Meal) (meal *models.Meal, err error)
-	CreateMeal(userID string, mealPost models.Meal) (meal *models.Meal, err error)
-	DeleteMeal(userID, mealID string) (err error)
+	GetMeal(ctx context.Context, userID, mealID string) (meal *models.Meal, err error)
+	ListMeals(ctx context.Context, userID string, filters models.MealsFilters) (meals []*models.Meal, err error)
+	UpdateMeal(ctx context.Context, userID string, mealID string, mealPut models.Meal) (meal *models.Meal, err error)
+	CreateMeal(ctx context.Context, userID string, mealPost models.Meal) (meal *models.Meal, err error)
+	DeleteMeal(ctx context.Context, userID, mealID string, version int) (err error)
 }
 
 type SQLiteMealRepository struct {
 	db *database.Database
+	tx *sqlx.Tx
 }
 
 func NewSQLiteMealRepository(db *database.Database) *SQLiteMealRepository {
@@ -38,25 +77,86 @@ func NewSQLiteMealRepository(db *database.Database) *SQLiteMealRepository {
 	}
 }
 
-func (r *SQLiteMealRepository) GetMeal(userId, mealId string) (*models.Meal, error) {
+// conn returns the transaction the repository is bound to, or the database connection otherwise
+func (r *SQLiteMealRepository) conn() sqlx.ExtContext {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db.Conn
+}
+
+// Transaction runs fn with a repository bound to a new transaction, which is committed
+// when fn succeeds and rolled back otherwise
+func (r *SQLiteMealRepository) Transaction(ctx context.Context, fn func(tx *SQLiteMealRepository) error) error {
+	tx, err := r.db.Conn.BeginTxx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Error("beginning the transaction", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	if err = fn(&SQLiteMealRepository{db: r.db, tx: tx}); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			logging.FromContext(ctx).Error("rolling back the transaction", "error", errRollback)
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		logging.FromContext(ctx).Error("committing the transaction", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	return nil
+}
+
+// Savepoint runs fn inside a savepoint of the current transaction, so when fn fails only
+// its changes are rolled back
+func (r *SQLiteMealRepository) Savepoint(ctx context.Context, name string, fn func() error) error {
+	if r.tx == nil {
+		return fn()
+	}
+	if _, err := r.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		logging.FromContext(ctx).Error("creating the savepoint", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	fnErr := fn()
+	if fnErr != nil {
+		if _, err := r.tx.ExecContext(ctx, "ROLLBACK TO "+name); err != nil {
+			logging.FromContext(ctx).Error("rolling back to the savepoint", "error", err)
+			return internal.ErrSomethingWentWrong
+		}
+	}
+	if _, err := r.tx.ExecContext(ctx, "RELEASE "+name); err != nil {
+		logging.FromContext(ctx).Error("releasing the savepoint", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	return fnErr
+}
+
+func (r *SQLiteMealRepository) GetMeal(ctx context.Context, userId, mealId string) (*models.Meal, error) {
+	ctx, end := observe(ctx, "GetMeal")
+	defer end()
 	var mealsAux []models.MealDB
-	err := r.db.Conn.Select(&mealsAux, getMeal, userId, mealId)
+	err := sqlx.SelectContext(ctx, r.conn(), &mealsAux, getMeal, userId, mealId)
 	if err != nil {
-		log.Error(err)
+		logging.FromContext(ctx).Error("getting the meal", "error", err)
 		return nil, internal.ErrSomethingWentWrong
 	}
 	if len(mealsAux) == 0 {
 		return nil, internal.ErrMealNotFound
 	}
-	return models.MealToAPI(&mealsAux[0]), nil
+	meal := models.MealToAPI(&mealsAux[0])
+	if err = r.loadSteps(ctx, userId, meal); err != nil {
+		return nil, err
+	}
+	return meal, nil
 
 }
 
-func (r *SQLiteMealRepository) GetMealByName(userId, mealName string) (*models.Meal, error) {
+func (r *SQLiteMealRepository) GetMealByName(ctx context.Context, userId, mealName string) (*models.Meal, error) {
+	ctx, end := observe(ctx, "GetMealByName")
+	defer end()
 	var mealsAux []models.MealDB
-	err := r.db.Conn.Select(&mealsAux, getMealByName, userId, mealName)
+	err := sqlx.SelectContext(ctx, r.conn(), &mealsAux, getMealByName, userId, mealName)
 	if err != nil {
-		log.Error(err)
+		logging.FromContext(ctx).Error("getting the meal by name", "error", err)
 		return nil, internal.ErrSomethingWentWrong
 	}
 	if len(mealsAux) != 0 {
@@ -64,11 +164,76 @@ func (r *SQLiteMealRepository) GetMealByName(userId, mealName string) (*models.M
 	}
 	return nil, nil
 }
-func (r *SQLiteMealRepository) ListMeals(userId string, filters models.MealsFilters) (meals []*models.Meal, err error) {
+
+// FindMealByName returns the meal of the user with the name indicated (case insensitive), or nil if there is none
+func (r *SQLiteMealRepository) FindMealByName(ctx context.Context, userId, mealName string) (*models.Meal, error) {
+	ctx, end := observe(ctx, "FindMealByName")
+	defer end()
+	var mealsAux []models.MealDB
+	err := sqlx.SelectContext(ctx, r.conn(), &mealsAux, getMealByName, userId, mealName)
+	if err != nil {
+		logging.FromContext(ctx).Error("finding the meal by name", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	if len(mealsAux) == 0 {
+		return nil, nil
+	}
+	meal := models.MealToAPI(&mealsAux[0])
+	if err = r.loadSteps(ctx, userId, meal); err != nil {
+		return nil, err
+	}
+	return meal, nil
+}
+
+// EachMeal calls fn with every meal of the user ordered by name, reading them one by one
+func (r *SQLiteMealRepository) EachMeal(ctx context.Context, userId string, fn func(meal *models.Meal) error) error {
+	ctx, end := observe(ctx, "EachMeal")
+	defer end()
+	// The steps are read before the meals, so no other query runs while the rows are being read
+	steps, err := r.steps(ctx, listSteps, userId)
+	if err != nil {
+		return err
+	}
+	rows, err := r.conn().QueryxContext(ctx, listMeals+"ORDER BY name", userId)
+	if err != nil {
+		logging.FromContext(ctx).Error("listing the meals", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mealDB models.MealDB
+		if err = rows.StructScan(&mealDB); err != nil {
+			logging.FromContext(ctx).Error("reading a meal", "error", err)
+			return internal.ErrSomethingWentWrong
+		}
+		meal := models.MealToAPI(&mealDB)
+		if mealSteps, ok := steps[meal.Id]; ok {
+			meal.Steps = mealSteps
+		}
+		if err = fn(meal); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Error("listing the meals", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	return nil
+}
+
+func (r *SQLiteMealRepository) ListMeals(ctx context.Context, userId string, filters models.MealsFilters) (meals []*models.Meal, err error) {
+	ctx, end := observe(ctx, "ListMeals")
+	defer end()
 	var mealsDB []models.MealDB
-	err = r.db.Conn.Select(&mealsDB, listMeals+applyFilters(filters), userId)
+	query, args := applyFilters(filters)
+	if searchQuery(filters) != "" {
+		query = searchMeals + query
+	} else {
+		query = listMeals + query
+	}
+	err = sqlx.SelectContext(ctx, r.conn(), &mealsDB, query, append([]interface{}{userId}, args...)...)
 	if err != nil {
-		log.Error(err)
+		logging.FromContext(ctx).Error("listing the meals", "error", err)
 		return nil, internal.ErrSomethingWentWrong
 	}
 	if len(mealsDB) == 0 {
@@ -77,59 +242,285 @@ func (r *SQLiteMealRepository) ListMeals(userId string, filters models.MealsFilt
 	for _, m := range mealsDB {
 		meals = append(meals, models.MealToAPI(&m))
 	}
+	if err = r.loadSteps(ctx, userId, meals...); err != nil {
+		return nil, err
+	}
 	return
 }
 
-func (r *SQLiteMealRepository) CreateMeal(userID string, mealPost models.Meal) (*models.Meal, error) {
+func (r *SQLiteMealRepository) CreateMeal(ctx context.Context, userID string, mealPost models.Meal) (*models.Meal, error) {
+	ctx, end := observe(ctx, "CreateMeal")
+	defer end()
 	id, _ := ulid.New(ulid.Now(), ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0))
 	mealPost.Id = id.String()
+	mealPost.Version = 1
 	mealDB := models.MealFromAPI(&mealPost)
-	_, err := r.db.Conn.Exec(CreateMeal, mealDB.Id, userID, mealDB.Name, mealDB.Description, mealDB.Image, mealDB.Type, mealDB.Ingredients, mealDB.Kcal, mealDB.Seasons)
+	err := r.atomic(ctx, func(repo *SQLiteMealRepository) error {
+		_, err := repo.conn().ExecContext(ctx, createMeal, mealDB.Id, userID, mealDB.Name, mealDB.Description, mealDB.Image, mealDB.Type, mealDB.Ingredients, mealDB.Kcal, mealDB.Seasons, mealDB.Servings, mealDB.PrepTime, mealDB.CookTime, mealDB.Difficulty, mealDB.Equipment, mealDB.Allergens, mealDB.Diets)
+		if err != nil {
+			logging.FromContext(ctx).Error("creating the meal", "error", err)
+			return internal.ErrSomethingWentWrong
+		}
+		return repo.saveSteps(ctx, userID, mealPost.Id, mealPost.Steps)
+	})
 	if err != nil {
-		log.Error(err)
-		return nil, internal.ErrSomethingWentWrong
+		return nil, err
 	}
 
 	return &mealPost, nil
 }
 
-func (r *SQLiteMealRepository) UpdateMeal(userID string, mealID string, mealUpdate models.Meal) (meal *models.Meal, err error) {
+// UpdateMeal updates the meal only if its stored version is still mealUpdate.Version,
+// returning the meal with the new version
+func (r *SQLiteMealRepository) UpdateMeal(ctx context.Context, userID string, mealID string, mealUpdate models.Meal) (meal *models.Meal, err error) {
+	ctx, end := observe(ctx, "UpdateMeal")
+	defer end()
 	mealDB := models.MealFromAPI(&mealUpdate)
-	_, err = r.db.Conn.Exec(updateMeal, mealDB.Name, mealDB.Description, mealDB.Image, mealDB.Type, mealDB.Ingredients, mealDB.Kcal, mealDB.Seasons, userID, mealID)
+	err = r.atomic(ctx, func(repo *SQLiteMealRepository) error {
+		result, err := repo.conn().ExecContext(ctx, updateMeal, mealDB.Name, mealDB.Description, mealDB.Image, mealDB.Type, mealDB.Ingredients, mealDB.Kcal, mealDB.Seasons, mealDB.Servings, mealDB.PrepTime, mealDB.CookTime, mealDB.Difficulty, mealDB.Equipment, mealDB.Allergens, mealDB.Diets, userID, mealID, mealDB.Version)
+		if err != nil {
+			logging.FromContext(ctx).Error("updating the meal", "error", err)
+			return internal.ErrSomethingWentWrong
+		}
+		if err = checkAffected(ctx, result); err != nil {
+			return err
+		}
+		return repo.saveSteps(ctx, userID, mealID, mealUpdate.Steps)
+	})
 	if err != nil {
-		log.Error(err)
-		return nil, internal.ErrSomethingWentWrong
+		return nil, err
 	}
+	mealUpdate.Version++
 	return &mealUpdate, nil
 }
 
-func (r *SQLiteMealRepository) DeleteMeal(userID, mealID string) (err error) {
-	_, err = r.db.Conn.Exec(deleteMeal, userID, mealID)
+// UntaggedMeals returns the meals of every user created before the allergens and the diets were tagged
+func (r *SQLiteMealRepository) UntaggedMeals(ctx context.Context) (meals []*models.Meal, err error) {
+	ctx, end := observe(ctx, "UntaggedMeals")
+	defer end()
+	var mealsDB []models.MealDB
+	if err = sqlx.SelectContext(ctx, r.conn(), &mealsDB, untaggedMeals); err != nil {
+		logging.FromContext(ctx).Error("listing the meals not tagged", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	for _, m := range mealsDB {
+		meals = append(meals, models.MealToAPI(&m))
+	}
+	return
+}
+
+// TagMeal stores the allergens and the diets of the meal, which are derived from its ingredients, only if its
+// stored version is still the one indicated. It returns false when the meal changed since it was read, so the
+// tags derived from its old ingredients are not stored
+func (r *SQLiteMealRepository) TagMeal(ctx context.Context, meal models.Meal) (bool, error) {
+	ctx, end := observe(ctx, "TagMeal")
+	defer end()
+	mealDB := models.MealFromAPI(&meal)
+	result, err := r.conn().ExecContext(ctx, tagMeal, mealDB.Allergens, mealDB.Diets, mealDB.UserId, mealDB.Id, mealDB.Version)
 	if err != nil {
-		log.Error(err)
+		logging.FromContext(ctx).Error("tagging the meal", "error", err)
+		return false, internal.ErrSomethingWentWrong
+	}
+	if err = checkAffected(ctx, result); errors.Is(err, internal.ErrMealVersionMismatch) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeleteMeal deletes the meal only if its stored version is still the one indicated
+func (r *SQLiteMealRepository) DeleteMeal(ctx context.Context, userID, mealID string, version int) (err error) {
+	ctx, end := observe(ctx, "DeleteMeal")
+	defer end()
+	return r.atomic(ctx, func(repo *SQLiteMealRepository) error {
+		result, err := repo.conn().ExecContext(ctx, deleteMeal, userID, mealID, version)
+		if err != nil {
+			logging.FromContext(ctx).Error("deleting the meal", "error", err)
+			return internal.ErrSomethingWentWrong
+		}
+		if err = checkAffected(ctx, result); err != nil {
+			return err
+		}
+		return repo.saveSteps(ctx, userID, mealID, nil)
+	})
+}
+
+// atomic runs fn in the transaction the repository is bound to, or in a new one otherwise
+func (r *SQLiteMealRepository) atomic(ctx context.Context, fn func(repo *SQLiteMealRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	return r.Transaction(ctx, fn)
+}
+
+// saveSteps replaces the steps of the meal with the ones indicated
+func (r *SQLiteMealRepository) saveSteps(ctx context.Context, userID, mealID string, steps []models.Step) error {
+	if _, err := r.conn().ExecContext(ctx, deleteSteps, userID, mealID); err != nil {
+		logging.FromContext(ctx).Error("deleting the steps", "error", err)
 		return internal.ErrSomethingWentWrong
 	}
-	return
+	for i, step := range steps {
+		if _, err := r.conn().ExecContext(ctx, createStep, userID, mealID, i+1, step.Text, step.TimerMinutes); err != nil {
+			logging.FromContext(ctx).Error("creating the step", "error", err)
+			return internal.ErrSomethingWentWrong
+		}
+	}
+	return nil
+}
+
+// loadSteps sets the steps of the meals of the user, reading the ones of every meal at once
+func (r *SQLiteMealRepository) loadSteps(ctx context.Context, userID string, meals ...*models.Meal) error {
+	if len(meals) == 0 {
+		return nil
+	}
+	query, args := listSteps, []interface{}{userID}
+	if len(meals) == 1 {
+		query, args = getSteps, append(args, meals[0].Id)
+	}
+	steps, err := r.steps(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	for _, meal := range meals {
+		if mealSteps, ok := steps[meal.Id]; ok {
+			meal.Steps = mealSteps
+		}
+	}
+	return nil
+}
+
+// steps returns the steps read with the query indicated by the meal they belong to
+func (r *SQLiteMealRepository) steps(ctx context.Context, query string, args ...interface{}) (map[string][]models.Step, error) {
+	var stepsDB []models.StepDB
+	if err := sqlx.SelectContext(ctx, r.conn(), &stepsDB, query, args...); err != nil {
+		logging.FromContext(ctx).Error("listing the steps", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	steps := map[string][]models.Step{}
+	for _, step := range stepsDB {
+		steps[step.MealId] = append(steps[step.MealId], models.StepToAPI(step))
+	}
+	return steps, nil
+}
+
+// observe starts the span of a query of a method of the meals repository, the returned func ends it and records its latency
+func observe(ctx context.Context, method string) (context.Context, func()) {
+	return observeRepository(ctx, "SQLiteMealRepository", method)
 }
 
-func applyFilters(filters models.MealsFilters) (query string) {
+func observeRepository(ctx context.Context, repository, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, repository+"."+method, semconv.DBSystemSqlite, semconv.DBOperationName(method))
+	return ctx, func() {
+		span.End()
+		metrics.ObserveQuery(method, start)
+	}
+}
+
+// checkAffected returns ErrMealVersionMismatch when a conditional write did not touch any row
+func checkAffected(ctx context.Context, result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logging.FromContext(ctx).Error("checking the rows affected", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	if affected == 0 {
+		return internal.ErrMealVersionMismatch
+	}
+	return nil
+}
+
+// applyFilters returns the conditions and the order of the filters, to append to listMeals or searchMeals, along
+// with their arguments. The ingredients must match a whole ingredient of the meal, ignoring the case. The results
+// of a search are sorted by relevance
+func applyFilters(filters models.MealsFilters) (query string, args []interface{}) {
+	var conditions []string
+	search := searchQuery(filters)
+	if search != "" {
+		conditions = append(conditions, "meals_fts MATCH ?")
+		args = append(args, search)
+	}
 	if filters.Name != nil {
-		*filters.Name = "%" + *filters.Name + "%"
-		query += fmt.Sprintf("AND name LIKE '%s'", *filters.Name)
+		conditions = append(conditions, "meals.name LIKE ?")
+		args = append(args, "%"+*filters.Name+"%")
 	}
 	if filters.Type != nil {
-		query += fmt.Sprintf("AND type = '%s'", *filters.Type)
+		conditions = append(conditions, "meals.type = ?")
+		args = append(args, *filters.Type)
 	}
-	if filters.Healthy != nil && *filters.Healthy {
-		query += "ORDER BY kcal ASC"
+	if seasons := filters.Seasons(); len(seasons) > 0 {
+		var matches []string
+		for _, season := range seasons {
+			matches = append(matches, "meals.seasons LIKE ?")
+			args = append(args, "%"+season+"%")
+		}
+		conditions = append(conditions, "(("+strings.Join(matches, " AND ")+") OR meals.seasons LIKE '%general%')")
 	}
-	if len(filters.Season) > 0 {
-		for _, s := range filters.Season {
-			season := "%" + s + "%"
-			query += fmt.Sprintf("AND seasons LIKE '%s'", season)
+	if anyOf := models.ListValues(filters.IngredientsAny); len(anyOf) > 0 {
+		var matches []string
+		for _, ingredient := range anyOf {
+			matches = append(matches, hasIngredient)
+			args = append(args, ingredient)
 		}
-		general := "%general%"
-		query += fmt.Sprintf("OR seasons LIKE '%s'", general)
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	for _, ingredient := range models.ListValues(filters.IngredientsAll) {
+		conditions = append(conditions, hasIngredient)
+		args = append(args, ingredient)
+	}
+	for _, ingredient := range models.ListValues(filters.IngredientsNone) {
+		conditions = append(conditions, "NOT "+hasIngredient)
+		args = append(args, ingredient)
+	}
+	for _, allergen := range filters.AllergenFree {
+		conditions = append(conditions, "meals.allergens IS NOT NULL AND instr(',' || meals.allergens || ',', ?) = 0")
+		args = append(args, ","+allergen+",")
+	}
+	diets := filters.Diets
+	if filters.Diet != nil {
+		diets = append(append([]string{}, diets...), *filters.Diet)
+	}
+	for _, diet := range diets {
+		conditions = append(conditions, "instr(',' || meals.diets || ',', ?) > 0")
+		args = append(args, ","+diet+",")
+	}
+	if filters.KcalMin != nil {
+		conditions = append(conditions, "meals.kcal >= ?")
+		args = append(args, *filters.KcalMin)
+	}
+	if filters.KcalMax != nil {
+		conditions = append(conditions, "meals.kcal <= ?")
+		args = append(args, *filters.KcalMax)
+	}
+	if filters.MaxTotalTime != nil {
+		conditions = append(conditions, "meals.prep_time + meals.cook_time BETWEEN 1 AND ?")
+		args = append(args, *filters.MaxTotalTime)
+	}
+
+	for _, condition := range conditions {
+		query += "AND " + condition + " "
+	}
+	var order []string
+	if filters.Healthy != nil && *filters.Healthy {
+		order = append(order, "meals.kcal ASC")
+	}
+	if search != "" {
+		order = append(order, "rank")
+	}
+	if len(order) > 0 {
+		query += "ORDER BY " + strings.Join(order, ", ")
 	}
 	return
 }
+
+// searchQuery returns the FTS5 query of the q filter, with every word of it as a prefix, so the words can
+// be in any order and partial. The words are quoted, as the syntax of FTS5 is not exposed
+func searchQuery(filters models.MealsFilters) string {
+	if filters.Q == nil {
+		return ""
+	}
+	words := strings.FieldsFunc(*filters.Q, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+	for i, word := range words {
+		words[i] = `"` + word + `"*`
+	}
+	return strings.Join(words, " ")
+}
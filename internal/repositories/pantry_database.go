@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/oklog/ulid/v2"
+	"meals/internal"
+	"meals/internal/models"
+	"meals/pkg/database"
+	"meals/pkg/logging"
+	"strings"
+)
+
+const (
+	getPantryItem    = "SELECT * FROM pantry WHERE user_id = ? AND id = ?"
+	listPantry       = "SELECT * FROM pantry WHERE user_id = ? ORDER BY expires_on = '', expires_on, ingredient"
+	createPantryItem = "INSERT INTO pantry(id,user_id,ingredient,quantity,expires_on) VALUES (?,?,?,?,?)"
+	updatePantryItem = "UPDATE pantry SET ingredient = ?, quantity = ?, expires_on = ? WHERE user_id = ? AND id = ?"
+	deletePantryItem = "DELETE FROM pantry WHERE user_id = ? AND id = ?"
+)
+
+type PantryRepository interface {
+	GetPantryItem(ctx context.Context, userID, itemID string) (item *models.PantryItem, err error)
+	ListPantry(ctx context.Context, userID string) (items []*models.PantryItem, err error)
+	CreatePantryItem(ctx context.Context, userID string, item models.PantryItem) (*models.PantryItem, error)
+	UpdatePantryItem(ctx context.Context, userID, itemID string, item models.PantryItem) (*models.PantryItem, error)
+	DeletePantryItem(ctx context.Context, userID, itemID string) error
+}
+
+type SQLitePantryRepository struct {
+	db *database.Database
+}
+
+func NewSQLitePantryRepository(db *database.Database) *SQLitePantryRepository {
+	return &SQLitePantryRepository{
+		db: db,
+	}
+}
+
+func (r *SQLitePantryRepository) GetPantryItem(ctx context.Context, userID, itemID string) (*models.PantryItem, error) {
+	ctx, end := observeRepository(ctx, "SQLitePantryRepository", "GetPantryItem")
+	defer end()
+	var items []*models.PantryItem
+	if err := sqlx.SelectContext(ctx, r.db.Conn, &items, getPantryItem, userID, itemID); err != nil {
+		logging.FromContext(ctx).Error("getting the pantry item", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	if len(items) == 0 {
+		return nil, internal.ErrPantryItemNotFound
+	}
+	return items[0], nil
+}
+
+// ListPantry returns the items of the pantry of the user, the ones expiring sooner first
+func (r *SQLitePantryRepository) ListPantry(ctx context.Context, userID string) ([]*models.PantryItem, error) {
+	ctx, end := observeRepository(ctx, "SQLitePantryRepository", "ListPantry")
+	defer end()
+	items := []*models.PantryItem{}
+	if err := sqlx.SelectContext(ctx, r.db.Conn, &items, listPantry, userID); err != nil {
+		logging.FromContext(ctx).Error("listing the pantry", "error", err)
+		return nil, internal.ErrSomethingWentWrong
+	}
+	return items, nil
+}
+
+func (r *SQLitePantryRepository) CreatePantryItem(ctx context.Context, userID string, item models.PantryItem) (*models.PantryItem, error) {
+	ctx, end := observeRepository(ctx, "SQLitePantryRepository", "CreatePantryItem")
+	defer end()
+	item.Id = ulid.Make().String()
+	item.UserId = userID
+	_, err := r.db.Conn.ExecContext(ctx, createPantryItem, item.Id, userID, item.Ingredient, item.Quantity, item.ExpiresOn)
+	if err != nil {
+		return nil, pantryWriteError(ctx, "creating the pantry item", err)
+	}
+	return &item, nil
+}
+
+func (r *SQLitePantryRepository) UpdatePantryItem(ctx context.Context, userID, itemID string, item models.PantryItem) (*models.PantryItem, error) {
+	ctx, end := observeRepository(ctx, "SQLitePantryRepository", "UpdatePantryItem")
+	defer end()
+	result, err := r.db.Conn.ExecContext(ctx, updatePantryItem, item.Ingredient, item.Quantity, item.ExpiresOn, userID, itemID)
+	if err != nil {
+		return nil, pantryWriteError(ctx, "updating the pantry item", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, internal.ErrPantryItemNotFound
+	}
+	item.Id, item.UserId = itemID, userID
+	return &item, nil
+}
+
+func (r *SQLitePantryRepository) DeletePantryItem(ctx context.Context, userID, itemID string) error {
+	ctx, end := observeRepository(ctx, "SQLitePantryRepository", "DeletePantryItem")
+	defer end()
+	result, err := r.db.Conn.ExecContext(ctx, deletePantryItem, userID, itemID)
+	if err != nil {
+		logging.FromContext(ctx).Error("deleting the pantry item", "error", err)
+		return internal.ErrSomethingWentWrong
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return internal.ErrPantryItemNotFound
+	}
+	return nil
+}
+
+// pantryWriteError reports the ingredients already in the pantry, which are unique per user
+func pantryWriteError(ctx context.Context, msg string, err error) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return internal.ErrPantryItemAlreadyExist
+	}
+	logging.FromContext(ctx).Error(msg, "error", err)
+	return internal.ErrSomethingWentWrong
+}
@@ -2,70 +2,112 @@ package internal
 
 import (
 	"errors"
-	"fmt"
-	"github.com/labstack/echo/v4"
 	"net/http"
 )
 
 const (
 	RouteMeal          = "/user/:user_id/meal"
 	RouteMealID        = "/user/:user_id/meal/:id"
+	RouteMealBatch     = "/user/:user_id/meal/batch"
+	RouteMealExport    = "/user/:user_id/meal/export"
+	RouteMealImport    = "/user/:user_id/meal/import"
 	RouteExternalMeals = "/meals"
+	RoutePlanGenerate  = "/user/:user_id/plan/generate"
+	RouteShoppingList  = "/user/:user_id/shopping-list"
+	RoutePantry        = "/user/:user_id/pantry"
+	RoutePantryItem    = "/user/:user_id/pantry/:id"
+	RouteMealCookable  = "/user/:user_id/meal/cookable"
+	RouteDietProfile   = "/user/:user_id/profile/diet"
+	RouteMealImage     = "/user/:user_id/meal/:id/image"
+	RouteMealImageSize = "/user/:user_id/meal/:id/image/:size"
 
 	RouteIngredients = "/ingredients"
+	RouteCatalog     = "/catalog"
+
+	RouteOpenAPIYAML = "/openapi.yaml"
+	RouteOpenAPIJSON = "/openapi.json"
+	RouteDocs        = "/docs"
+	RouteMetrics     = "/metrics"
+	RouteHealthz     = "/healthz"
+	RouteReadyz      = "/readyz"
+	RouteStatus      = "/status"
 
 	ParamUserID = "user_id"
 	ParamMealID = "id"
-)
+	ParamItemID = "id"
+	ParamSize   = "size"
 
-type ErrorResponse struct {
-	Err ErrorBody `json:"error"`
-}
+	MIMEApplicationLDJSON      = "application/ld+json"
+	MIMEApplicationProblemJSON = "application/problem+json"
 
-func (e *ErrorResponse) Error() string {
-	return fmt.Sprintf("Error %d: %s", e.Err.Status, e.Err.Message)
-}
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
 
-type ErrorBody struct {
-	Status  int    `json:"status"`
-	Message string `json:"message"`
-}
+	HeaderAcceptLanguage  = "Accept-Language"
+	HeaderContentLanguage = "Content-Language"
+)
 
-func NewErrorResponse(c echo.Context, err error) error {
-	errResponse := &ErrorResponse{Err: errorsMap[err.Error()]}
-	if errResponse.Err.Status == 0 {
-		if err := c.JSON(http.StatusInternalServerError, err); err != nil {
-			return err
-		}
-		return err
-	}
-	if err := c.JSON(errResponse.Err.Status, errResponse); err != nil {
-		return err
-	}
-	return errResponse
+// apiErrors are the errors reported by the API, with their status and stable machine code
+var apiErrors = []APIError{
+	{Err: ErrUserIDNotPresent, Status: http.StatusBadRequest, Code: "USER_ID_NOT_PRESENT"},
+	{Err: ErrMealIDNotPresent, Status: http.StatusBadRequest, Code: "MEAL_ID_NOT_PRESENT"},
+	{Err: ErrMealTypeNotPresent, Status: http.StatusBadRequest, Code: "MEAL_TYPE_NOT_PRESENT"},
+	{Err: ErrWrongBody, Status: http.StatusBadRequest, Code: "WRONG_BODY"},
+	{Err: ErrSomethingWentWrong, Status: http.StatusInternalServerError, Code: "INTERNAL_ERROR"},
+	{Err: ErrorWithExternalAPI, Status: http.StatusInternalServerError, Code: "EXTERNAL_API_ERROR"},
+	{Err: ErrMealNotFound, Status: http.StatusNotFound, Code: "MEAL_NOT_FOUND"},
+	{Err: ErrMealsNotFound, Status: http.StatusNotFound, Code: "MEALS_NOT_FOUND"},
+	{Err: ErrUserNotFound, Status: http.StatusNotFound, Code: "USER_NOT_FOUND"},
+	{Err: ErrMealAlreadyExist, Status: http.StatusConflict, Code: "MEAL_ALREADY_EXISTS"},
+	{Err: ErrMealVersionMismatch, Status: http.StatusPreconditionFailed, Code: "MEAL_VERSION_MISMATCH"},
+	{Err: ErrIfMatchNotPresent, Status: http.StatusPreconditionRequired, Code: "IF_MATCH_REQUIRED"},
+	{Err: ErrPatchNotSupported, Status: http.StatusUnsupportedMediaType, Code: "PATCH_FORMAT_NOT_SUPPORTED"},
+	{Err: ErrBatchOpNotSupported, Status: http.StatusBadRequest, Code: "BATCH_OPERATION_NOT_SUPPORTED"},
+	{Err: ErrBatchAborted, Status: http.StatusFailedDependency, Code: "BATCH_ABORTED"},
+	{Err: ErrFormatNotSupported, Status: http.StatusBadRequest, Code: "FORMAT_NOT_SUPPORTED"},
+	{Err: ErrFileTooLarge, Status: http.StatusRequestEntityTooLarge, Code: "FILE_TOO_LARGE"},
+	{Err: ErrRequestNotValid, Status: http.StatusBadRequest, Code: "REQUEST_NOT_VALID"},
+	{Err: ErrRouteNotFound, Status: http.StatusNotFound, Code: "ROUTE_NOT_FOUND"},
+	{Err: ErrMethodNotAllowed, Status: http.StatusMethodNotAllowed, Code: "METHOD_NOT_ALLOWED"},
+	{Err: ErrPantryItemIDNotPresent, Status: http.StatusBadRequest, Code: "PANTRY_ITEM_ID_NOT_PRESENT"},
+	{Err: ErrPantryItemNotFound, Status: http.StatusNotFound, Code: "PANTRY_ITEM_NOT_FOUND"},
+	{Err: ErrPantryItemAlreadyExist, Status: http.StatusConflict, Code: "PANTRY_ITEM_ALREADY_EXISTS"},
+	{Err: ErrDietProfileNotFound, Status: http.StatusNotFound, Code: "DIET_PROFILE_NOT_FOUND"},
+	{Err: ErrImageNotFound, Status: http.StatusNotFound, Code: "IMAGE_NOT_FOUND"},
+	{Err: ErrImageTooLarge, Status: http.StatusRequestEntityTooLarge, Code: "IMAGE_TOO_LARGE"},
+	{Err: ErrImageNotSupported, Status: http.StatusUnsupportedMediaType, Code: "IMAGE_TYPE_NOT_SUPPORTED"},
 }
 
-var errorsMap = map[string]ErrorBody{
-	ErrUserIDNotPresent.Error():   {Status: http.StatusBadRequest, Message: ErrUserIDNotPresent.Error()},
-	ErrMealIDNotPresent.Error():   {Status: http.StatusBadRequest, Message: ErrMealIDNotPresent.Error()},
-	ErrMealTypeNotPresent.Error(): {Status: http.StatusBadRequest, Message: ErrMealTypeNotPresent.Error()},
-	ErrWrongBody.Error():          {Status: http.StatusBadRequest, Message: ErrWrongBody.Error()},
-	ErrSomethingWentWrong.Error(): {Status: http.StatusInternalServerError, Message: ErrSomethingWentWrong.Error()},
-	ErrorWithExternalAPI.Error():  {Status: http.StatusInternalServerError, Message: ErrorWithExternalAPI.Error()},
-	ErrMealNotFound.Error():       {Status: http.StatusNotFound, Message: ErrMealNotFound.Error()},
-	ErrMealsNotFound.Error():      {Status: http.StatusNotFound, Message: ErrMealsNotFound.Error()},
-	ErrUserNotFound.Error():       {Status: http.StatusNotFound, Message: ErrUserNotFound.Error()},
-	ErrMealAlreadyExist.Error():   {Status: http.StatusConflict, Message: ErrMealAlreadyExist.Error()},
-}
 var (
-	ErrUserIDNotPresent   = errors.New("error con el ID de usuario indicado")
-	ErrMealIDNotPresent   = errors.New("error con el ID de comida indicado")
-	ErrMealTypeNotPresent = errors.New("error con el tipo de comida indicado")
-	ErrSomethingWentWrong = errors.New("error inesperado")
-	ErrWrongBody          = errors.New("el cuerpo enviado es erróneo")
-	ErrMealNotFound       = errors.New("comida no encontrada")
-	ErrUserNotFound       = errors.New("usuario no encontrado")
-	ErrMealsNotFound      = errors.New("comidas no encontradas")
-	ErrorWithExternalAPI  = errors.New("error inesperado con la API externa")
-	ErrMealAlreadyExist   = errors.New("ya existe una comida con este nombre")
+	ErrUserIDNotPresent    = errors.New("error con el ID de usuario indicado")
+	ErrMealIDNotPresent    = errors.New("error con el ID de comida indicado")
+	ErrMealTypeNotPresent  = errors.New("error con el tipo de comida indicado")
+	ErrSomethingWentWrong  = errors.New("error inesperado")
+	ErrWrongBody           = errors.New("el cuerpo enviado es erróneo")
+	ErrMealNotFound        = errors.New("comida no encontrada")
+	ErrUserNotFound        = errors.New("usuario no encontrado")
+	ErrMealsNotFound       = errors.New("comidas no encontradas")
+	ErrorWithExternalAPI   = errors.New("error inesperado con la API externa")
+	ErrMealAlreadyExist    = errors.New("ya existe una comida con este nombre")
+	ErrMealVersionMismatch = errors.New("la comida ha sido modificada por otra petición")
+	ErrIfMatchNotPresent   = errors.New("falta la cabecera If-Match con la versión de la comida")
+	ErrPatchNotSupported   = errors.New("formato de parche no soportado")
+	ErrBatchOpNotSupported = errors.New("operación no soportada en el lote")
+	ErrBatchAborted        = errors.New("operación revertida por un error en otra operación del lote")
+	ErrFormatNotSupported  = errors.New("formato de fichero no soportado")
+	ErrFileTooLarge        = errors.New("el fichero supera el tamaño máximo")
+	ErrRequestNotValid     = errors.New("la petición no cumple la especificación de la API")
+	ErrRouteNotFound       = errors.New("ruta no encontrada")
+	ErrMethodNotAllowed    = errors.New("método no permitido en esta ruta")
+
+	ErrPantryItemIDNotPresent = errors.New("error con el ID de ingrediente de la despensa indicado")
+	ErrPantryItemNotFound     = errors.New("ingrediente de la despensa no encontrado")
+	ErrPantryItemAlreadyExist = errors.New("el ingrediente ya está en la despensa")
+
+	ErrDietProfileNotFound = errors.New("perfil de dieta no encontrado")
+
+	ErrImageNotFound     = errors.New("imagen no encontrada")
+	ErrImageTooLarge     = errors.New("la imagen supera el tamaño máximo")
+	ErrImageNotSupported = errors.New("tipo de imagen no soportado, se admiten JPEG, PNG y WebP")
 )
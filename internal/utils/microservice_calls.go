@@ -2,10 +2,16 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"io"
 	"meals/internal"
 	"meals/internal/config"
+	"meals/internal/metrics"
 	"meals/internal/models"
+	"meals/pkg/logging"
+	"meals/pkg/tracing"
 	"net/http"
 )
 
@@ -13,33 +19,56 @@ type Endpoints struct {
 }
 
 type EndpointsI interface {
-	GetCalendar(userId string, meal models.Meal, delete bool) (err error)
+	GetCalendar(ctx context.Context, userId string, meal models.Meal, delete bool) (err error)
+	ListCalendar(ctx context.Context, userId string) (calendar []models.Calendar, err error)
 }
 
-var httpClient = &http.Client{}
+var httpClient = &http.Client{Transport: tracing.Transport(metrics.ServiceCalendars, metrics.Transport(metrics.ServiceCalendars, nil))}
 
-func (e *Endpoints) GetCalendar(userId string, meal models.Meal, delete bool) (err error) {
-	var calendar []models.Calendar
-	request, err := http.NewRequest(http.MethodGet, config.Config.CalendarsURL+"user/"+userId+"/calendar", nil)
+// newRequest returns a request to another service bound to ctx, propagating the id of the request being served
+func newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		request.Header.Set(echo.HeaderXRequestID, id)
+	}
+	return request, nil
+}
+
+// ListCalendar returns the meals planned in the calendar of the user, none when the user has no calendar
+func (e *Endpoints) ListCalendar(ctx context.Context, userId string) (calendar []models.Calendar, err error) {
+	request, err := newRequest(ctx, http.MethodGet, config.Config.CalendarsURL+"user/"+userId+"/calendar", nil)
 	if err != nil {
 		return
 	}
 	response, err := httpClient.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer response.Body.Close()
 	if response.StatusCode == 404 {
 		return
 	}
 	if response.StatusCode > 299 {
 		newError := new(internal.ErrorResponse)
 		err = json.NewDecoder(response.Body).Decode(&newError)
-		return newError
+		return nil, newError
 	}
 	err = json.NewDecoder(response.Body).Decode(&calendar)
+	return
+}
+
+func (e *Endpoints) GetCalendar(ctx context.Context, userId string, meal models.Meal, delete bool) (err error) {
+	calendar, err := e.ListCalendar(ctx, userId)
 	if err != nil {
 		return err
 	}
+	var (
+		request  *http.Request
+		response *http.Response
+	)
 	for _, c := range calendar {
 		if c.MealId != meal.Id {
 			continue
@@ -47,11 +76,11 @@ func (e *Endpoints) GetCalendar(userId string, meal models.Meal, delete bool) (e
 		weekCalendar := models.UpdateWeekCalendar{From: c.Date, To: c.Date}
 		if delete {
 			content, _ := json.Marshal(weekCalendar)
-			request, err = http.NewRequest(http.MethodPut, config.Config.CalendarsURL+"user/"+userId+"/redoweek", bytes.NewBuffer(content))
-			request.Header.Set("Content-Type", "application/json;charset=UTF-8")
+			request, err = newRequest(ctx, http.MethodPut, config.Config.CalendarsURL+"user/"+userId+"/redoweek", bytes.NewBuffer(content))
 			if err != nil {
 				return
 			}
+			request.Header.Set("Content-Type", "application/json;charset=UTF-8")
 			response, err = httpClient.Do(request)
 			if err != nil {
 				return err
@@ -64,7 +93,7 @@ func (e *Endpoints) GetCalendar(userId string, meal models.Meal, delete bool) (e
 		} else {
 			data := models.Calendar{UserId: userId, MealId: meal.Id, Name: meal.Name, Date: c.Date}
 			content, _ := json.Marshal(data)
-			request, err = http.NewRequest(http.MethodPut, config.Config.CalendarsURL+"user/"+userId+"/calendar", bytes.NewBuffer(content))
+			request, err = newRequest(ctx, http.MethodPut, config.Config.CalendarsURL+"user/"+userId+"/calendar", bytes.NewBuffer(content))
 			if err != nil {
 				return
 			}
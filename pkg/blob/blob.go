@@ -0,0 +1,25 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when there is no blob with the key indicated
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is a stored file along with its content type
+type Blob struct {
+	Content     []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// Store keeps the blobs by their key, a slash separated path like "users/1/meals/2/image.jpg"
+type Store interface {
+	Put(ctx context.Context, key string, blob Blob) error
+	Get(ctx context.Context, key string) (*Blob, error)
+	// DeleteAll deletes the blobs under the directory indicated, e.g. "users/1/meals/2/"
+	DeleteAll(ctx context.Context, dir string) error
+}
@@ -0,0 +1,86 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Local stores the blobs as files under a directory. Their content type is the one of their extension,
+// or the one detected from their content when they have none
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+// Put writes the blob to a temporary file renamed once written, so it is never read half written
+func (l *Local) Put(_ context.Context, key string, blob Blob) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(blob.Content); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+func (l *Local) Get(_ context.Context, key string) (*Blob, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return &Blob{Content: content, ContentType: contentType, ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) DeleteAll(_ context.Context, dir string) error {
+	name, err := l.path(dir)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(name)
+}
+
+// path returns the file of the key, which can not be outside the directory of the store
+func (l *Local) path(key string) (string, error) {
+	name := filepath.FromSlash(path.Clean("/" + key))[1:]
+	if name == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("blob key %q not valid", key)
+	}
+	return filepath.Join(l.dir, name), nil
+}
@@ -0,0 +1,216 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config is the bucket of an S3 compatible store, e.g. AWS S3 or MinIO
+type S3Config struct {
+	// Endpoint is the base URL of the store, the bucket being addressed in the path
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3 stores the blobs as the objects of a bucket of an S3 compatible store, signing the requests with
+// the AWS Signature Version 4
+type S3 struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3(config S3Config, client *http.Client) *S3 {
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	return &S3{config: config, client: client, now: time.Now}
+}
+
+func (s *S3) Put(ctx context.Context, key string, blob Blob) error {
+	header := http.Header{}
+	header.Set("Content-Type", blob.ContentType)
+	resp, err := s.do(ctx, http.MethodPut, key, nil, header, blob.Content)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (*Blob, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &Blob{Content: content, ContentType: resp.Header.Get("Content-Type"), ModTime: modTime}, nil
+}
+
+// listObjects is the response of ListObjectsV2
+type listObjects struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// DeleteAll lists the objects under the directory, page by page, and deletes them one by one
+func (s *S3) DeleteAll(ctx context.Context, dir string) error {
+	query := url.Values{"list-type": {"2"}, "prefix": {strings.TrimSuffix(dir, "/") + "/"}}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return err
+		}
+		list := new(listObjects)
+		err = xml.NewDecoder(resp.Body).Decode(list)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		for _, object := range list.Contents {
+			resp, err = s.do(ctx, http.MethodDelete, object.Key, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+		}
+		if !list.IsTruncated {
+			return nil
+		}
+		query.Set("continuation-token", list.NextContinuationToken)
+	}
+}
+
+// do sends the signed request of the key, or of the bucket when there is no key. Missing objects are
+// reported as ErrNotFound and the rest of errors of the store with their status
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	target := s.config.Endpoint + "/" + s.config.Bucket + "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, message)
+	}
+	return resp, nil
+}
+
+// sign adds the Authorization header of the AWS Signature Version 4 to the request
+// (https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html)
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	date, amzDate := now.Format("20060102"), now.Format("20060102T150405Z")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + s.config.SecretKey)
+	for _, part := range []string{date, s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the parameters sorted by name, with their names and values encoded
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes every byte but the unreserved characters of RFC 3986, along with the slashes of
+// the paths when the value is not a parameter
+func uriEncode(value string, parameter bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !parameter:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
@@ -1,67 +1,117 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
-	"github.com/labstack/gommon/log"
+	"log/slog"
 	_ "modernc.org/sqlite"
 	"os"
 	"path/filepath"
 	"strconv"
 )
 
+var (
+	ErrNotConnected    = errors.New("database not connected")
+	ErrVersionNotFound = errors.New("database version not found")
+	ErrVersionOutdated = errors.New("database version outdated")
+)
+
 type Database struct {
 	Conn *sqlx.DB
 }
 
-func InitDB(bbddName string) *Database {
-	var err error
-	db := &Database{}
+// InitDB opens the database and runs the scripts not executed yet. The service can not work without
+// it, so the error must stop the startup
+func InitDB(bbddName string) (*Database, error) {
+	conn, err := SqlLiteConnect(bbddName)
+	if err != nil {
+		if conn != nil {
+			_ = conn.Close()
+		}
+		return nil, fmt.Errorf("connecting database %s: %w", bbddName, err)
+	}
+	slog.Info("Database connected")
+	return &Database{Conn: conn}, nil
+}
 
-	db.Conn, err = SqlLiteConnect(bbddName)
+// Ping checks the database can still be reached
+func (db *Database) Ping(ctx context.Context) error {
+	if db == nil || db.Conn == nil {
+		return ErrNotConnected
+	}
+	return db.Conn.PingContext(ctx)
+}
 
-	if err != nil {
-		log.Error("Error connecting database: ", err)
-	} else {
-		log.Info("Database connected")
+// CheckVersion returns the version of the database, failing when some script has not been executed
+func (db *Database) CheckVersion(ctx context.Context) (int, error) {
+	if db == nil || db.Conn == nil {
+		return 0, ErrNotConnected
 	}
+	var versions []int
+	if err := db.Conn.SelectContext(ctx, &versions, "SELECT version FROM db_version"); err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, ErrVersionNotFound
+	}
+	// The stored version is the last script executed
+	if expected := len(scripts) - 1; versions[0] != expected {
+		return versions[0], fmt.Errorf("%w: %d, expected %d", ErrVersionOutdated, versions[0], expected)
+	}
+	return versions[0], nil
+}
 
-	return db
+// Close closes the connection once the queries in progress have finished
+func (db *Database) Close() error {
+	if db == nil || db.Conn == nil {
+		return ErrNotConnected
+	}
+	return db.Conn.Close()
 }
 
 func SqlLiteConnect(bbddName string) (*sqlx.DB, error) {
 	dir, _ := os.Getwd()
 	db, err := sqlx.Connect("sqlite", filepath.Dir(dir)+bbddName)
+	if err != nil {
+		return nil, err
+	}
 
 	numbSc, err := GetDBVersion(db)
-	if numbSc < len(scripts)-1 {
+	if err == nil {
+		// The stored version is the last script executed, so we start with the next one
+		numbSc++
+	}
+	if numbSc < len(scripts) {
 		err = CreateScripts(db, numbSc)
 		if err != nil {
 			return db, err
 		}
 	}
-	log.Info("Database version :", len(scripts))
+	slog.Info("Database version", "version", len(scripts))
 
 	db.SetMaxOpenConns(3)
 
-	return db, err
+	return db, nil
 }
 
 func CreateScripts(db *sqlx.DB, numbSc int) error {
 
 	for i := numbSc; i < len(scripts); i++ {
-		log.Info("Executing script - ", i+1)
+		slog.Info("Executing script", "script", i+1)
 		_, err := db.Exec(scripts[i].Script)
 		if err != nil {
-			log.Error(err)
+			slog.Error("Executing script", "script", i+1, "error", err)
 			return err
 		}
 		err = UpdateVersion(db, i)
 		if err != nil {
-			log.Error(err)
+			slog.Error("Executing script", "script", i+1, "error", err)
 			return err
 		}
 	}
-	log.Info("Scrips executed successfully - ", len(scripts))
+	slog.Info("Scripts executed successfully", "scripts", len(scripts))
 
 	return nil
 }
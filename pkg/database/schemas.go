@@ -27,6 +27,42 @@ var scripts = []DbScript{
 		Script:      addNameToCalendars,
 		Description: "add name column to calendar",
 	},
+	{
+		Script:      addVersionToMeals,
+		Description: "add version column to meals",
+	},
+	{
+		Script:      pantry,
+		Description: "pantry table",
+	},
+	{
+		Script:      mealsSearch,
+		Description: "full-text search of meals",
+	},
+	{
+		Script:      addTagsToMeals,
+		Description: "add allergens and diets columns to meals",
+	},
+	{
+		Script:      dietProfiles,
+		Description: "diet_profiles table",
+	},
+	{
+		Script:      addServingsToMeals,
+		Description: "add servings column to meals",
+	},
+	{
+		Script:      mealSteps,
+		Description: "meal_steps table and cooking columns of meals",
+	},
+	{
+		Script:      separateIngredients,
+		Description: "separate the ingredients of meals with |",
+	},
+	{
+		Script:      addSearchIdToMeals,
+		Description: "search_id key of meals for the full-text search",
+	},
 }
 var version = `
 CREATE TABLE IF NOT EXISTS db_version (
@@ -69,3 +105,146 @@ CREATE TABLE IF NOT EXISTS calendar (
 var addNameToCalendars = `
 ALTER TABLE calendar ADD name text NOT NULL;
 `
+
+var addVersionToMeals = `
+ALTER TABLE meals ADD version integer NOT NULL DEFAULT 1;
+`
+
+var pantry = `
+CREATE TABLE IF NOT EXISTS pantry (
+	id			text	NOT NULL,
+	user_id		text	NOT NULL,
+	ingredient	text	NOT NULL,
+	quantity	text	NOT NULL DEFAULT '',
+	expires_on	text	NOT NULL DEFAULT '',
+	PRIMARY KEY (id,user_id),
+	UNIQUE (user_id,ingredient)
+);`
+
+// mealsSearch indexes the name, description and ingredients of the meals, kept in sync by the triggers.
+// The tokenizer folds the accents, so "platano" finds "plátano"
+var mealsSearch = `
+CREATE VIRTUAL TABLE IF NOT EXISTS meals_fts USING fts5(
+	name,
+	description,
+	ingredients,
+	content = 'meals',
+	content_rowid = 'rowid',
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS meals_fts_insert AFTER INSERT ON meals BEGIN
+	INSERT INTO meals_fts(rowid, name, description, ingredients) VALUES (new.rowid, new.name, new.description, new.ingredients);
+END;
+
+CREATE TRIGGER IF NOT EXISTS meals_fts_delete AFTER DELETE ON meals BEGIN
+	INSERT INTO meals_fts(meals_fts, rowid, name, description, ingredients) VALUES ('delete', old.rowid, old.name, old.description, old.ingredients);
+END;
+
+CREATE TRIGGER IF NOT EXISTS meals_fts_update AFTER UPDATE ON meals BEGIN
+	INSERT INTO meals_fts(meals_fts, rowid, name, description, ingredients) VALUES ('delete', old.rowid, old.name, old.description, old.ingredients);
+	INSERT INTO meals_fts(rowid, name, description, ingredients) VALUES (new.rowid, new.name, new.description, new.ingredients);
+END;
+
+INSERT INTO meals_fts(meals_fts) VALUES ('rebuild');
+`
+
+// addTagsToMeals adds the allergens and the diets derived from the ingredients, NULL until the meal is tagged
+var addTagsToMeals = `
+ALTER TABLE meals ADD allergens text;
+ALTER TABLE meals ADD diets text;
+`
+
+var dietProfiles = `
+CREATE TABLE IF NOT EXISTS diet_profiles (
+	user_id					text	PRIMARY KEY,
+	excluded_allergens		text	NOT NULL DEFAULT '',
+	diets					text	NOT NULL DEFAULT '',
+	disliked_ingredients	text	NOT NULL DEFAULT '',
+	daily_kcal				integer	NOT NULL DEFAULT 0
+);`
+
+// addServingsToMeals adds the servings of the recipes, the kcal of the meals being the ones of a serving
+var addServingsToMeals = `
+ALTER TABLE meals ADD servings integer NOT NULL DEFAULT 1;
+`
+
+// mealSteps keeps the cooking steps of the meals in order, the rest of the cooking details being columns of meals
+var mealSteps = `
+CREATE TABLE IF NOT EXISTS meal_steps (
+	user_id			text	NOT NULL,
+	meal_id			text	NOT NULL,
+	position		integer	NOT NULL,
+	text			text	NOT NULL,
+	timer_minutes	integer	NOT NULL DEFAULT 0,
+	PRIMARY KEY (user_id,meal_id,position)
+);
+
+ALTER TABLE meals ADD prep_time integer NOT NULL DEFAULT 0;
+ALTER TABLE meals ADD cook_time integer NOT NULL DEFAULT 0;
+ALTER TABLE meals ADD difficulty text NOT NULL DEFAULT '';
+ALTER TABLE meals ADD equipment text NOT NULL DEFAULT '';
+`
+
+// separateIngredients separates the ingredients of the meals with "|", as the names of the catalog have commas
+var separateIngredients = `
+UPDATE meals SET ingredients = replace(ingredients, ',', '|');
+`
+
+// addSearchIdToMeals gives the meals an integer key for the full-text search. Their rowid can not be used, as
+// a VACUUM may renumber it when the primary key is not an integer one
+var addSearchIdToMeals = `
+DROP TRIGGER IF EXISTS meals_fts_insert;
+DROP TRIGGER IF EXISTS meals_fts_delete;
+DROP TRIGGER IF EXISTS meals_fts_update;
+DROP TABLE IF EXISTS meals_fts;
+
+CREATE TABLE meals_search (
+	search_id	 integer PRIMARY KEY,
+	id 		     text	 NOT NULL,
+	user_id	     text 	 NOT NULL,
+	name	 	 text	 NOT NULL,
+	description  text,
+	image		 text,
+	kcal         integer NOT NULL,
+	type		 text	 NOT NULL,
+	ingredients	 text	 NOT NULL,
+	seasons      text    NOT NULL,
+	version		 integer NOT NULL DEFAULT 1,
+	allergens	 text,
+	diets		 text,
+	servings	 integer NOT NULL DEFAULT 1,
+	prep_time	 integer NOT NULL DEFAULT 0,
+	cook_time	 integer NOT NULL DEFAULT 0,
+	difficulty	 text	 NOT NULL DEFAULT '',
+	equipment	 text	 NOT NULL DEFAULT '',
+	UNIQUE (id,user_id)
+);
+INSERT INTO meals_search(id,user_id,name,description,image,kcal,type,ingredients,seasons,version,allergens,diets,servings,prep_time,cook_time,difficulty,equipment) SELECT id,user_id,name,description,image,kcal,type,ingredients,seasons,version,allergens,diets,servings,prep_time,cook_time,difficulty,equipment FROM meals;
+DROP TABLE meals;
+ALTER TABLE meals_search RENAME TO meals;
+
+CREATE VIRTUAL TABLE meals_fts USING fts5(
+	name,
+	description,
+	ingredients,
+	content = 'meals',
+	content_rowid = 'search_id',
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER meals_fts_insert AFTER INSERT ON meals BEGIN
+	INSERT INTO meals_fts(rowid, name, description, ingredients) VALUES (new.search_id, new.name, new.description, new.ingredients);
+END;
+
+CREATE TRIGGER meals_fts_delete AFTER DELETE ON meals BEGIN
+	INSERT INTO meals_fts(meals_fts, rowid, name, description, ingredients) VALUES ('delete', old.search_id, old.name, old.description, old.ingredients);
+END;
+
+CREATE TRIGGER meals_fts_update AFTER UPDATE ON meals BEGIN
+	INSERT INTO meals_fts(meals_fts, rowid, name, description, ingredients) VALUES ('delete', old.search_id, old.name, old.description, old.ingredients);
+	INSERT INTO meals_fts(rowid, name, description, ingredients) VALUES (new.search_id, new.name, new.description, new.ingredients);
+END;
+
+INSERT INTO meals_fts(meals_fts) VALUES ('rebuild');
+`
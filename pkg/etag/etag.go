@@ -0,0 +1,63 @@
+package etag
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Version formats the version of a resource as a strong entity tag
+func Version(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Variant returns the entity tag of another representation of the resource of the tag, e.g. "1-jsonld",
+// so the representations of the same version are told apart by the caches
+func Variant(tag, variant string) string {
+	return strings.TrimSuffix(tag, `"`) + "-" + variant + `"`
+}
+
+// Weak builds a weak entity tag from the given parts (used for collections)
+func Weak(parts ...string) string {
+	hash := sha1.New()
+	for _, p := range parts {
+		hash.Write([]byte(p))
+		hash.Write([]byte{0})
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// Content builds a strong entity tag from the hash of the content of a resource (used for files)
+func Content(content []byte) string {
+	hash := sha1.Sum(content)
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+// Match reports if the value of an If-Match / If-None-Match header matches the entity tag.
+// If-Match uses the strong comparison (weak = false) and If-None-Match the weak one (weak = true)
+func Match(header, tag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(tag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
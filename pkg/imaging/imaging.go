@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// maxPixels limits the size of the images decoded, so a small file can not take all the memory
+const maxPixels = 40_000_000
+
+var (
+	ErrFormatNotSupported = errors.New("image format not supported")
+	ErrTooManyPixels      = errors.New("image with too many pixels")
+)
+
+// ContentTypes are the content types of the images that can be decoded
+var ContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+// Decode returns the image of the content, a JPEG, PNG or WebP detected by its signature whatever its
+// name says. JPEG images are turned upright as their EXIF orientation indicates, as the EXIF is lost
+// with the rest of the metadata when the image is encoded again
+func Decode(content []byte) (image.Image, error) {
+	contentType := http.DetectContentType(content)
+	supported := false
+	for _, t := range ContentTypes {
+		supported = supported || t == contentType
+	}
+	if !supported {
+		return nil, ErrFormatNotSupported
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(content))
+	}
+	return img, nil
+}
+
+// Fit returns the image scaled down to fit in a square of the side indicated, keeping its aspect ratio.
+// Smaller images are returned as they are
+func Fit(img image.Image, side int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= side && height <= side {
+		return img
+	}
+	if width >= height {
+		width, height = side, max(1, height*side/width)
+	} else {
+		width, height = max(1, width*side/height), side
+	}
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+	return scaled
+}
+
+// Encode returns the image as a JPEG, or as a PNG when it has transparent pixels, along with its content type
+func Encode(img image.Image) ([]byte, string, error) {
+	var content bytes.Buffer
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+		err := png.Encode(&content, img)
+		return content.Bytes(), "image/png", err
+	}
+	err := jpeg.Encode(&content, img, &jpeg.Options{Quality: 85})
+	return content.Bytes(), "image/jpeg", err
+}
@@ -0,0 +1,109 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const (
+	exifHeader     = "Exif\x00\x00"
+	tagOrientation = 0x0112
+)
+
+// jpegOrientation returns the orientation of the EXIF of a JPEG, from 1 (upright) to 8, 1 when it has none
+func jpegOrientation(content []byte) int {
+	if len(content) < 2 || content[0] != 0xFF || content[1] != 0xD8 {
+		return 1
+	}
+	segments := content[2:]
+	for len(segments) >= 4 && segments[0] == 0xFF {
+		marker := segments[1]
+		if marker == 0xDA || marker == 0xD9 {
+			// The image data starts, the metadata is before it
+			break
+		}
+		length := int(binary.BigEndian.Uint16(segments[2:4]))
+		if length < 2 || 2+length > len(segments) {
+			break
+		}
+		segment := segments[4 : 2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte(exifHeader)) {
+			return tiffOrientation(segment[len(exifHeader):])
+		}
+		segments = segments[2+length:]
+	}
+	return 1
+}
+
+// tiffOrientation returns the orientation tag of the first directory of the TIFF structure of the EXIF
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:entry+2]) == tagOrientation {
+			if orientation := int(order.Uint16(tiff[entry+8 : entry+10])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// orient returns the image turned upright from the EXIF orientation indicated, which tells where the
+// first row and column of the stored image are
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	size := image.Rect(0, 0, width, height)
+	if orientation >= 5 {
+		size = image.Rect(0, 0, height, width)
+	}
+	upright := image.NewNRGBA(size)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored
+				dx, dy = width-1-x, y
+			case 3: // Rotated 180°
+				dx, dy = width-1-x, height-1-y
+			case 4: // Mirrored upside down
+				dx, dy = x, height-1-y
+			case 5: // Mirrored and rotated 90° counterclockwise
+				dx, dy = y, x
+			case 6: // Rotated 90° counterclockwise
+				dx, dy = height-1-y, x
+			case 7: // Mirrored and rotated 90° clockwise
+				dx, dy = height-1-y, width-1-x
+			case 8: // Rotated 90° clockwise
+				dx, dy = y, width-1-x
+			}
+			upright.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return upright
+}
@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Manager runs the background workers of the service and stops them, along with the resources registered,
+// when the service shuts down
+type Manager struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel}
+}
+
+// Go runs a background worker until the service shuts down, when its context is cancelled. The worker
+// must return once its context is done
+func (m *Manager) Go(name string, worker func(ctx context.Context) error) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		if err := worker(m.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("Background worker stopped", "worker", name, "error", err)
+		}
+	}()
+}
+
+// OnStop registers a function that releases a resource once the workers have stopped. The functions
+// run in the reverse order they were registered, so a resource is released before the ones it depends on
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Shutdown cancels the workers and waits for them until ctx is done, then runs the stop functions.
+// Every stop function runs even when a previous one fails or the deadline is exceeded
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	var errs []error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for the background workers: %w", ctx.Err()))
+	}
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", hooks[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
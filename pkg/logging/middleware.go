@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/oklog/ulid/v2"
+	"log/slog"
+	"time"
+)
+
+// maxRequestIDLength limits the ids accepted from the clients, so they can not flood the logs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware accepts the X-Request-ID sent by the client or generates a new one. The id is returned in
+// the response and the request context carries it along with a logger that includes it in every line
+func RequestIDMiddleware(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = ulid.Make().String()
+			}
+			req.Header.Set(echo.HeaderXRequestID, id)
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+
+			ctx := WithRequestID(req.Context(), id)
+			ctx = WithLogger(ctx, logger.With(slog.String("request_id", id)))
+			c.SetRequest(req.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// AccessLogMiddleware logs every request once it has been served, with its route, user and latency
+func AccessLogMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Lets the error handler write the response, so its status is logged
+				c.Error(err)
+			}
+
+			req, res := c.Request(), c.Response()
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+				slog.String("uri", req.RequestURI),
+				slog.Int("status", res.Status),
+				slog.Int64("bytes_out", res.Size),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_ip", c.RealIP()),
+			}
+			if userID := c.Param("user_id"); userID != "" {
+				attrs = append(attrs, slog.String("user_id", userID))
+			}
+			level := slog.LevelInfo
+			switch {
+			case res.Status >= 500:
+				level = slog.LevelError
+			case res.Status >= 400:
+				level = slog.LevelWarn
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			FromContext(req.Context()).LogAttrs(req.Context(), level, "request", attrs...)
+			return nil
+		}
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
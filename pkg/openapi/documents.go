@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
+)
+
+const MIMEApplicationYAML = "application/yaml"
+
+// Documents is a spec ready to be served, in YAML and in JSON
+type Documents struct {
+	YAML []byte
+	JSON []byte
+}
+
+// NewDocuments returns the spec with its servers replaced by the ones indicated. The YAML document
+// keeps the order and comments of the original one
+func NewDocuments(spec []byte, servers openapi3.Servers) (*Documents, error) {
+	doc, err := Load(spec)
+	if err != nil {
+		return nil, err
+	}
+	doc.Servers = servers
+	if err = doc.Servers.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	jsonSpec, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err = yaml.Unmarshal(spec, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("openapi: the spec is not a YAML mapping")
+	}
+	var serversNode yaml.Node
+	if err = serversNode.Encode(servers); err != nil {
+		return nil, err
+	}
+	setKey(root.Content[0], "servers", &serversNode)
+
+	var yamlSpec bytes.Buffer
+	encoder := yaml.NewEncoder(&yamlSpec)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return &Documents{YAML: yamlSpec.Bytes(), JSON: jsonSpec}, nil
+}
+
+// setKey replaces the value of a key of a YAML mapping, adding it after the info when missing
+func setKey(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	at := len(mapping.Content)
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == "info" {
+			at = i + 2
+		}
+	}
+	content := append([]*yaml.Node{}, mapping.Content[:at]...)
+	content = append(content, keyNode, value)
+	mapping.Content = append(content, mapping.Content[at:]...)
+}
@@ -0,0 +1,279 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/labstack/echo/v4"
+	"meals/pkg/logging"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	ModeOff     = "off"     // Nothing is validated
+	ModeLog     = "log"     // Requests and responses that do not match the spec are logged
+	ModeEnforce = "enforce" // Requests that do not match the spec are rejected and so are the responses
+)
+
+// maxLoggedBody is the biggest response body kept to be validated in log mode, bigger ones are only
+// validated by status and headers
+const maxLoggedBody = 1 << 20
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/ld+json", openapi3filter.JSONBodyDecoder)
+}
+
+// ErrorHandler writes the response of a request, or of a handler response, that does not match the spec
+type ErrorHandler func(c echo.Context, err error) error
+
+type Config struct {
+	// Mode is one of off, log or enforce. Default log
+	Mode string
+	// RequestError responds to the requests rejected in enforce mode
+	RequestError ErrorHandler
+	// ResponseError responds instead of a handler whose response is rejected in enforce mode
+	ResponseError ErrorHandler
+}
+
+// Validator checks the requests and responses of the service against its OpenAPI spec
+type Validator struct {
+	Doc    *openapi3.T
+	router routers.Router
+	config Config
+}
+
+// Load parses and validates an OpenAPI spec
+func Load(spec []byte) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func NewValidator(spec []byte, config Config) (*Validator, error) {
+	switch config.Mode {
+	case "":
+		config.Mode = ModeLog
+	case ModeOff, ModeLog, ModeEnforce:
+	default:
+		return nil, fmt.Errorf("openapi: unknown validation mode %q", config.Mode)
+	}
+	if config.Mode == ModeEnforce && (config.RequestError == nil || config.ResponseError == nil) {
+		return nil, fmt.Errorf("openapi: enforce mode needs both error handlers")
+	}
+
+	doc, err := Load(spec)
+	if err != nil {
+		return nil, err
+	}
+	// Routes are matched on the path only, whatever host the service is reached through
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{Doc: doc, router: router, config: config}, nil
+}
+
+// Middleware validates every request described by the spec and the response of its handler
+func (v *Validator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if v.config.Mode == ModeOff {
+			return next
+		}
+		return func(c echo.Context) error {
+			req := c.Request()
+			route, pathParams, err := v.router.FindRoute(req)
+			if err != nil {
+				// Not part of the API, e.g. a 404
+				return next(c)
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options: &openapi3filter.Options{
+					ExcludeRequestBody:  binaryBody(route, req),
+					SkipSettingDefaults: true,
+					AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+				},
+			}
+			if err = openapi3filter.ValidateRequest(req.Context(), input); err != nil {
+				logging.FromContext(req.Context()).Warn("openapi: request does not match the spec",
+					"method", req.Method, "path", req.URL.Path, "error", err.Error())
+				if v.config.Mode == ModeEnforce {
+					return v.config.RequestError(c, err)
+				}
+			}
+
+			res := c.Response()
+			rec := &recorder{ResponseWriter: res.Writer, header: http.Header{}, hold: v.config.Mode == ModeEnforce}
+			res.Writer = rec
+			err = next(c)
+			res.Writer = rec.ResponseWriter
+			if rec.status == 0 {
+				// Nothing written, the error handler of echo responds
+				return err
+			}
+
+			respErr := validateResponse(input, rec)
+			if respErr != nil {
+				logging.FromContext(req.Context()).Warn("openapi: response does not match the spec",
+					"status", rec.status, "method", req.Method, "path", req.URL.Path, "error", respErr.Error())
+			}
+			if !rec.held() {
+				return err
+			}
+			if respErr != nil {
+				c.SetResponse(echo.NewResponse(rec.ResponseWriter, c.Echo()))
+				return v.config.ResponseError(c, respErr)
+			}
+			rec.writeHeader()
+			if _, writeErr := rec.ResponseWriter.Write(rec.body.Bytes()); writeErr != nil {
+				return writeErr
+			}
+			return err
+		}
+	}
+}
+
+func validateResponse(input *openapi3filter.RequestValidationInput, rec *recorder) error {
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 rec.status,
+		Header:                 rec.header,
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			ExcludeResponseBody:   !rec.capture || rec.truncated,
+		},
+	}
+	responseInput.SetBodyBytes(rec.body.Bytes())
+	if err := openapi3filter.ValidateResponse(input.Request.Context(), responseInput); err != nil {
+		return err
+	}
+	if rec.capture {
+		return nil
+	}
+	// Only JSON bodies are validated, the rest just need a documented content type
+	response := input.Route.Operation.Responses.Status(rec.status)
+	if response == nil || response.Value == nil || len(response.Value.Content) == 0 {
+		return nil
+	}
+	contentType := rec.header.Get(echo.HeaderContentType)
+	if response.Value.Content.Get(contentType) == nil {
+		return fmt.Errorf("content type %q not documented for status %d", contentType, rec.status)
+	}
+	return nil
+}
+
+// binaryBody reports if the request body is a file, which is handed to the handler without validation
+func binaryBody(route *routers.Route, req *http.Request) bool {
+	body := route.Operation.RequestBody
+	if body == nil || body.Value == nil {
+		return false
+	}
+	media := body.Value.Content.Get(req.Header.Get(echo.HeaderContentType))
+	if media == nil || media.Schema == nil || media.Schema.Value == nil {
+		return false
+	}
+	return isBinary(media.Schema.Value)
+}
+
+func isBinary(schema *openapi3.Schema) bool {
+	if schema.Type.Is(openapi3.TypeString) {
+		return schema.Format == "binary"
+	}
+	if !schema.Type.Is(openapi3.TypeObject) || len(schema.Properties) == 0 {
+		return false
+	}
+	for _, property := range schema.Properties {
+		if property.Value == nil || !isBinary(property.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// recorder keeps a copy of JSON responses to validate them. In enforce mode the response is held back
+// until it is validated, the rest of responses are streamed as they are written
+type recorder struct {
+	http.ResponseWriter
+	header    http.Header
+	hold      bool
+	status    int
+	capture   bool
+	truncated bool
+	body      bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.capture = isJSON(r.header.Get(echo.HeaderContentType))
+	if !r.held() {
+		r.writeHeader()
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.capture {
+		if r.hold || r.body.Len()+len(b) <= maxLoggedBody {
+			r.body.Write(b)
+		} else {
+			r.truncated = true
+		}
+	}
+	if r.held() {
+		return len(b), nil
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Flush() {
+	if r.held() {
+		return
+	}
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// held reports if the response is being held back until it is validated
+func (r *recorder) held() bool {
+	return r.hold && r.capture
+}
+
+// writeHeader sends the headers written by the handler and the status
+func (r *recorder) writeHeader() {
+	header := r.ResponseWriter.Header()
+	for key, values := range r.header {
+		header[key] = values
+	}
+	r.ResponseWriter.WriteHeader(r.status)
+}
@@ -0,0 +1,27 @@
+package text
+
+import (
+	"strings"
+	"unicode"
+)
+
+var diacritics = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c",
+)
+
+// Fold returns the text lower-cased and without accents, so "Calabacín" and "calabacin" are equal
+func Fold(s string) string {
+	return diacritics.Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// Words returns the folded words of the text, ignoring numbers and punctuation
+func Words(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"meals/pkg/logging"
+	"net/http"
+	"net/url"
+)
+
+// Middleware starts a server span for every request, continuing the trace sent in the traceparent header.
+// The logger of the request includes the trace and span ids
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			route := c.Path()
+			name := req.Method + " " + route
+			if route == "" {
+				name = req.Method
+			}
+			ctx, span := otel.Tracer(instrumentation).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+				))
+			defer span.End()
+			if userID := c.Param("user_id"); userID != "" {
+				span.SetAttributes(semconv.EnduserID(userID))
+			}
+			if spanContext := span.SpanContext(); spanContext.IsValid() {
+				logger := logging.FromContext(ctx).With(
+					slog.String("trace_id", spanContext.TraceID().String()),
+					slog.String("span_id", spanContext.SpanID().String()))
+				ctx = logging.WithLogger(ctx, logger)
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = http.StatusInternalServerError
+				if httpErr, ok := err.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				}
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+				if err != nil {
+					span.RecordError(err)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// Transport starts a client span for every call to the service indicated, propagating the trace context.
+// The values of the secret query parameters (e.g. the keys of an API) are redacted from the URL of the span
+func Transport(service string, base http.RoundTripper, secrets ...string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	traced := otelhttp.NewTransport(&revealer{base: base}, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return service + " " + r.Method
+	}))
+	if len(secrets) == 0 {
+		return traced
+	}
+	return &redactor{next: traced, secrets: secrets}
+}
+
+// redactedSecret replaces the values of the secret query parameters in the spans
+const redactedSecret = "REDACTED"
+
+type secretsKey struct{}
+
+// redactor redacts the secret query parameters before the span records the URL, keeping them in the context
+// of the request for the revealer
+type redactor struct {
+	next    http.RoundTripper
+	secrets []string
+}
+
+func (t *redactor) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	secrets := url.Values{}
+	for _, secret := range t.secrets {
+		if query.Has(secret) {
+			secrets[secret] = query[secret]
+			query.Set(secret, redactedSecret)
+		}
+	}
+	if len(secrets) == 0 {
+		return t.next.RoundTrip(req)
+	}
+	redacted := req.Clone(context.WithValue(req.Context(), secretsKey{}, secrets))
+	redacted.URL.RawQuery = query.Encode()
+	return t.next.RoundTrip(redacted)
+}
+
+// revealer puts back the secret query parameters redacted, once the span has recorded the URL
+type revealer struct {
+	base http.RoundTripper
+}
+
+func (t *revealer) RoundTrip(req *http.Request) (*http.Response, error) {
+	secrets, ok := req.Context().Value(secretsKey{}).(url.Values)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	query := req.URL.Query()
+	for secret, values := range secrets {
+		query[secret] = values
+	}
+	revealed := req.Clone(req.Context())
+	revealed.URL.RawQuery = query.Encode()
+	return t.base.RoundTrip(revealed)
+}
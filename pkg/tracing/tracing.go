@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+)
+
+const (
+	ExporterNone   = "none"   // Spans are not exported
+	ExporterStdout = "stdout" // Spans are written to the standard output as JSON
+	ExporterOTLP   = "otlp"   // Spans are sent to an OTLP collector over HTTP
+)
+
+// instrumentation is the name of the tracer of the service
+const instrumentation = "meals"
+
+// Config of the export of the spans
+type Config struct {
+	ServiceName string
+	Exporter    string
+	// Endpoint of the OTLP collector (host:port). When empty the OTEL_EXPORTER_OTLP_* variables are used
+	Endpoint string
+	Insecure bool
+}
+
+// Setup installs the global tracer provider with the exporter configured and the W3C trace context
+// propagation. The returned function flushes the pending spans and must be called before exiting
+func Setup(ctx context.Context, config Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("tracing: exporter %q not supported, use none, stdout or otlp", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider := NewProvider(config.ServiceName, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider of the service, e.g. with sdktrace.WithSyncer and an in-memory exporter in tests
+func NewProvider(serviceName string, options ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, options...)...)
+}
+
+// Start starts a span of the service as a child of the one carried by ctx
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attributes...))
+}